@@ -4,6 +4,13 @@ import (
 	"log"
 	"oxo-game-api/config"
 	"oxo-game-api/internal/api/handlers"
+	"oxo-game-api/internal/api/middleware"
+	"oxo-game-api/internal/models"
+	"oxo-game-api/internal/presence"
+	"oxo-game-api/internal/notify"
+	"oxo-game-api/internal/outbox"
+	"oxo-game-api/internal/privacy"
+	"oxo-game-api/internal/scheduling"
 	"oxo-game-api/migrations"
 	"oxo-game-api/migrations/seeds"
 	"oxo-game-api/pkg/database"
@@ -36,6 +43,10 @@ type Server struct {
 // @host      localhost:8080
 // @BasePath  /
 
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+
 func main() {
 	
 	cfg, err := config.LoadTestConfig()
@@ -43,6 +54,12 @@ func main() {
 		log.Fatalf("Fail to load config: %v", err)
 	}
 
+	authCfg := config.LoadAuthConfig()
+	retentionCfg := config.LoadRetentionConfig()
+	reservationCfg := config.LoadReservationConfig()
+	presenceCfg := config.LoadPresenceConfig()
+	notifyCfg := config.LoadNotificationConfig()
+
 	db, err := database.InitPostgres(cfg)
 	if err != nil {
 		log.Fatalf("Fail to initalize database: %v", err)
@@ -52,6 +69,10 @@ func main() {
 		log.Fatalf("Fail to migrate database: %v", err)
 	}
 
+	if err := migrations.ApplyNamePolicy(db, retentionCfg.DeletedNamePolicy); err != nil {
+		log.Fatalf("Fail to apply deleted name policy: %v", err)
+	}
+
 	if err := seeds.SeedLevels(db); err != nil {
 		log.Fatalf("Fail to seed levels: %v", err)
 	}
@@ -64,66 +85,195 @@ func main() {
 		log.Fatalf("Fail to seed rooms: %v", err)
 	}
 
-	playerHandler := handlers.NewPlayerHandler(db)
+	if err := seeds.SeedAdmin(db, authCfg); err != nil {
+		log.Fatalf("Fail to seed admin: %v", err)
+	}
+
+	if err := privacy.ResumeErasureJobs(db); err != nil {
+		log.Fatalf("Fail to resume erasure jobs: %v", err)
+	}
+
+	scheduling.StartSweeper(db, reservationCfg.SweepInterval, reservationCfg.NoShowGrace, reservationCfg.WaitlistConfirmWindow, reservationCfg.ReminderLead)
+	dispatcher := notify.NewDispatcher(db, notify.NewChannels(db, notifyCfg)...)
+	outbox.StartRelay(db, outbox.DefaultRelayInterval, dispatcher.Deliver)
+	presenceTracker := presence.NewTracker(db, presence.NewMemoryStore(), presenceCfg.SessionTimeout)
+	if err := presenceTracker.Start(presenceCfg.SweepInterval); err != nil {
+		log.Fatalf("Fail to restore room presence: %v", err)
+	}
+
+	authHandler := handlers.NewAuthHandler(db, authCfg)
+	apiKeyHandler := handlers.NewAPIKeyHandler(db, authCfg)
+	playerHandler := handlers.NewPlayerHandler(db, retentionCfg)
 	levelHandler := handlers.NewLevelHandler(db)
 	roomHandler := handlers.NewRoomHandler(db)
-	reservationHandler := handlers.NewReservationHandler(db)
+	reservationHandler := handlers.NewReservationHandler(db, reservationCfg)
 	challengeHandler := handlers.NewChallengeHandler(db)
-	logHandler := handlers.NewLogHandler(db)
+	logHandler := handlers.NewLogHandler(db, presenceTracker)
 	paymentHandler := handlers.NewPaymentHandler(db)
+	leaderboardHandler := handlers.NewLeaderboardHandler(db)
+	privacyHandler := handlers.NewPrivacyHandler(db)
+	moderationHandler := handlers.NewModerationHandler(db)
+	friendshipHandler := handlers.NewFriendshipHandler(db)
+	profileHandler := handlers.NewProfileHandler(db)
+	notificationHandler := handlers.NewNotificationHandler(db)
+	calendarHandler := handlers.NewCalendarHandler(db)
+	presenceHandler := handlers.NewPresenceHandler(db, presenceTracker)
 
 	r := gin.Default()
 
 	r.GET("swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	players := r.Group("/players")
+	r.POST("/auth/login", authHandler.Login)
+	r.POST("/players", playerHandler.CreatePlayer)
+	r.GET("/rooms/:id/calendar.ics", middleware.AuthenticateFeed(db, models.FeedRoom), calendarHandler.GetRoomCalendar)
+	r.GET("/players/:id/calendar.ics", middleware.AuthenticateFeed(db, models.FeedPlayer), middleware.RequireSelfOrPermission("id", middleware.PermReservationsRead), calendarHandler.GetPlayerCalendar)
+
+	api := r.Group("", middleware.Authenticate(db))
+
+	api.POST("/auth/logout", authHandler.Logout)
+
+	players := api.Group("/players")
 	{
-		players.GET("", playerHandler.GetPlayers)
-		players.POST("", playerHandler.CreatePlayer)
-		players.GET("/:id", playerHandler.GetPlayerByID)
-		players.PUT("/:id", playerHandler.UpdatePlayerByID)
-		players.DELETE("/:id", playerHandler.DeletePlayerByID)
+		players.GET("", middleware.RequirePermission(middleware.PermPlayersRead), playerHandler.GetPlayers)
+		players.GET("/deleted", middleware.RequirePermission(middleware.PermRecordsRecover), playerHandler.GetDeletedPlayers)
+		players.GET("/:id", middleware.RequireSelfOrPermission("id", middleware.PermPlayersRead), playerHandler.GetPlayerByID)
+		players.PUT("/:id", middleware.RequireSelfOrPermission("id", middleware.PermPlayersWrite), playerHandler.UpdatePlayerByID)
+		players.PATCH("/:id", middleware.RequireSelfOrPermission("id", middleware.PermPlayersWrite), playerHandler.UpdatePlayerByID)
+		players.DELETE("/:id", middleware.RequirePermission(middleware.PermPlayersDelete), playerHandler.DeletePlayerByID)
+		players.PUT("/:id/role", middleware.RequirePermission(middleware.PermRolesWrite), playerHandler.UpdatePlayerRole)
+		players.POST("/:id/experience", middleware.RequirePermission(middleware.PermExperienceWrite), playerHandler.AwardExperience)
+		players.GET("/:id/level-history", middleware.RequireSelfOrPermission("id", middleware.PermPlayersRead), playerHandler.GetLevelHistory)
+		players.POST("/:id/restore", middleware.RequirePermission(middleware.PermRecordsRecover), playerHandler.RestorePlayer)
+		players.DELETE("/:id/purge", middleware.RequirePermission(middleware.PermRecordsRecover), playerHandler.PurgePlayer)
+		players.GET("/:id/export", middleware.RequireSelfOrPermission("id", middleware.PermPrivacyManage), privacyHandler.ExportPlayerData)
+		players.POST("/:id/erasure", middleware.RequireSelfOrPermission("id", middleware.PermPrivacyManage), privacyHandler.RequestErasure)
+		players.GET("/:id/sanctions", middleware.RequireSelfOrPermission("id", middleware.PermPlayersModerate), moderationHandler.GetSanctions)
+		players.POST("/:id/sanctions", middleware.RequirePermission(middleware.PermPlayersModerate), moderationHandler.IssueSanction)
+		players.GET("/:id/profile", profileHandler.GetProfile)
+		players.PATCH("/:id/profile", middleware.RequireSelfOrPermission("id", middleware.PermPlayersWrite), profileHandler.UpdateProfile)
+		players.GET("/:id/friends", friendshipHandler.GetFriends)
+		players.GET("/:id/friend-requests", middleware.RequireSelfOrPermission("id", middleware.PermPlayersRead), friendshipHandler.GetFriendRequests)
+		players.POST("/:id/block", friendshipHandler.BlockPlayer)
+		players.GET("/:id/privacy", middleware.RequireSelfOrPermission("id", middleware.PermPlayersRead), friendshipHandler.GetPrivacySettings)
+		players.PUT("/:id/privacy", middleware.RequireSelfOrPermission("id", middleware.PermPlayersWrite), friendshipHandler.UpdatePrivacySettings)
+		players.POST("/:id/calendar-feed", middleware.RequireSelfOrPermission("id", middleware.PermReservationsRead), calendarHandler.CreatePlayerCalendarFeed)
+		players.DELETE("/:id/calendar-feed", middleware.RequireSelfOrPermission("id", middleware.PermReservationsRead), calendarHandler.RevokePlayerCalendarFeed)
+		players.GET("/:id/notifications", middleware.RequireSelfOrPermission("id", middleware.PermPlayersRead), notificationHandler.GetNotifications)
+		players.POST("/:id/notifications/read-all", middleware.RequireSelfOrPermission("id", middleware.PermPlayersWrite), notificationHandler.MarkAllNotificationsRead)
+		players.POST("/:id/notifications/:notification_id/read", middleware.RequireSelfOrPermission("id", middleware.PermPlayersWrite), notificationHandler.MarkNotificationRead)
 	}
 
-	levels := r.Group("/levels")
+	api.POST("/players:action", middleware.RequirePermission(middleware.PermPlayersWrite), handlers.CustomMethod(map[string]gin.HandlerFunc{
+		"bulk": playerHandler.BulkImportPlayers,
+	}))
+	api.GET("/players:action", middleware.RequirePermission(middleware.PermPlayersRead), handlers.CustomMethod(map[string]gin.HandlerFunc{
+		"export": playerHandler.ExportPlayers,
+	}))
+
+	levels := api.Group("/levels")
 	{
 		levels.GET("", levelHandler.GetLevels)
-		levels.POST("", levelHandler.CreateLevel)
+		levels.GET("/:id", levelHandler.GetLevelByID)
+		levels.POST("", middleware.RequirePermission(middleware.PermLevelsWrite), levelHandler.CreateLevel)
+		levels.PUT("/:id", middleware.RequirePermission(middleware.PermLevelsWrite), levelHandler.UpdateLevelByID)
+		levels.PATCH("/:id", middleware.RequirePermission(middleware.PermLevelsWrite), levelHandler.UpdateLevelByID)
+		levels.DELETE("/:id", middleware.RequirePermission(middleware.PermLevelsWrite), levelHandler.DeleteLevelByID)
 	}
 
-	rooms := r.Group("/rooms")
+	rooms := api.Group("/rooms")
 	{
 		rooms.GET("", roomHandler.GetRooms)
-		rooms.POST("", roomHandler.CreateRoom)
+		rooms.GET("/deleted", middleware.RequirePermission(middleware.PermRecordsRecover), roomHandler.GetDeletedRooms)
+		rooms.GET("/availability", roomHandler.GetRoomsAvailability)
+		rooms.POST("", middleware.RequirePermission(middleware.PermRoomsWrite), roomHandler.CreateRoom)
 		rooms.GET("/:id", roomHandler.GetRoomByID)
-		rooms.PUT("/:id", roomHandler.UpdateRoomByID)
-		rooms.DELETE("/:id", roomHandler.DeleteRoomByID)
+		rooms.PUT("/:id", middleware.RequirePermission(middleware.PermRoomsWrite), roomHandler.UpdateRoomByID)
+		rooms.PATCH("/:id", middleware.RequirePermission(middleware.PermRoomsWrite), roomHandler.UpdateRoomByID)
+		rooms.DELETE("/:id", middleware.RequirePermission(middleware.PermRoomsDelete), roomHandler.DeleteRoomByID)
+		rooms.POST("/:id/restore", middleware.RequirePermission(middleware.PermRecordsRecover), roomHandler.RestoreRoom)
+		rooms.DELETE("/:id/purge", middleware.RequirePermission(middleware.PermRecordsRecover), roomHandler.PurgeRoom)
+		rooms.GET("/:id/availability", roomHandler.GetRoomAvailability)
+		rooms.GET("/:id/maintenance", roomHandler.GetMaintenanceWindows)
+		rooms.POST("/:id/maintenance", middleware.RequirePermission(middleware.PermRoomsWrite), roomHandler.CreateMaintenanceWindow)
+		rooms.DELETE("/:id/maintenance/:window_id", middleware.RequirePermission(middleware.PermRoomsWrite), roomHandler.DeleteMaintenanceWindow)
+		rooms.POST("/:id/calendar-feed", calendarHandler.CreateRoomCalendarFeed)
+		rooms.DELETE("/:id/calendar-feed", calendarHandler.RevokeRoomCalendarFeed)
+		rooms.GET("/:id/occupants", presenceHandler.GetRoomOccupants)
+		rooms.POST("/:id/enter", presenceHandler.EnterRoom)
+		rooms.POST("/:id/heartbeat", presenceHandler.RoomHeartbeat)
+		rooms.POST("/:id/leave", presenceHandler.LeaveRoom)
 	}
 
-	reservations := r.Group("/reservations")
+	reservations := api.Group("/reservations")
 	{
 		reservations.GET("", reservationHandler.GetReservations)
 		reservations.POST("", reservationHandler.CreateReservation)
+		reservations.GET("/waitlist", reservationHandler.GetWaitlist)
+		reservations.POST("/waitlist/:id/confirm", reservationHandler.ConfirmWaitlistOffer)
+		reservations.DELETE("/waitlist/:id", reservationHandler.LeaveWaitlist)
+		reservations.GET("/series/:id", reservationHandler.GetReservationSeries)
+		reservations.PATCH("/series/:id", reservationHandler.UpdateReservationSeries)
+		reservations.POST("/series/:id/cancel", reservationHandler.CancelReservationSeries)
+		reservations.GET("/:id", reservationHandler.GetReservationByID)
+		reservations.PATCH("/:id", reservationHandler.UpdateReservationByID)
+		reservations.POST("/:id/invitations", reservationHandler.InviteFriends)
+		reservations.POST("/:id/accept", reservationHandler.AcceptInvitation)
+		reservations.POST("/:id/decline", reservationHandler.DeclineInvitation)
+		reservations.POST("/:id/cancel", reservationHandler.CancelReservation)
+		reservations.POST("/:id/check-in", reservationHandler.CheckInReservation)
+		reservations.GET("/:id/transitions", reservationHandler.GetReservationTransitions)
 	}
 
-	challenges := r.Group("/challenges")
+	challenges := api.Group("/challenges")
 	{
 		challenges.GET("/results", challengeHandler.GetChallengeResults)
 		challenges.POST("", challengeHandler.JoinChallenge)
 	}
 
-	logs := r.Group("/logs")
+	logs := api.Group("/logs")
 	{
 		logs.GET("", logHandler.GetLogs)
 		logs.POST("", logHandler.CreateLog)
 	}
 
-	payments := r.Group("/payments")
+	payments := api.Group("/payments")
 	{
 		payments.GET("/:id", paymentHandler.GetPayment)
 		payments.POST("", paymentHandler.ProcessPayment)
 	}
 
+	leaderboards := api.Group("/leaderboards")
+	{
+		leaderboards.GET("/:kind", leaderboardHandler.GetLeaderboard)
+		leaderboards.GET("/:kind/me", leaderboardHandler.GetMyRank)
+		leaderboards.POST("/rebuild", middleware.RequirePermission(middleware.PermLeaderboardsAdmin), leaderboardHandler.RebuildLeaderboards)
+	}
+
+	apiKeys := api.Group("/api-keys", middleware.RequirePermission(middleware.PermAPIKeysManage))
+	{
+		apiKeys.GET("", apiKeyHandler.GetAPIKeys)
+		apiKeys.POST("", apiKeyHandler.CreateAPIKey)
+		apiKeys.POST("/:id/rotate", apiKeyHandler.RotateAPIKey)
+		apiKeys.DELETE("/:id", apiKeyHandler.RevokeAPIKey)
+	}
+
+	friendships := api.Group("/friendships")
+	{
+		friendships.POST("", friendshipHandler.SendFriendRequest)
+		friendships.POST("/:id/accept", friendshipHandler.AcceptFriendRequest)
+		friendships.POST("/:id/decline", friendshipHandler.DeclineFriendRequest)
+		friendships.DELETE("/:id", friendshipHandler.DeleteFriendship)
+	}
+
+	sanctions := api.Group("/sanctions")
+	{
+		sanctions.POST("/:id/revoke", middleware.RequirePermission(middleware.PermPlayersModerate), moderationHandler.RevokeSanction)
+		sanctions.POST("/:id/appeal", moderationHandler.AppealSanction)
+		sanctions.POST("/:id/appeal/decision", middleware.RequirePermission(middleware.PermPlayersModerate), moderationHandler.DecideAppeal)
+	}
+
+	api.GET("/erasure-jobs/:id", privacyHandler.GetErasureJob)
+
 	if err := r.Run(":8080"); err != nil {
 		log.Fatalf("Fail to run server: %v", err)
 	}
@@ -0,0 +1,72 @@
+// Command integrity reports rows whose foreign keys point at records that do
+// not exist. With -validate the keys without orphans are validated, after
+// which the database checks the existing rows too and not only new ones.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"oxo-game-api/config"
+	"oxo-game-api/migrations"
+	"oxo-game-api/pkg/database"
+)
+
+// maxListed is how many orphan ids are printed per foreign key
+const maxListed = 20
+
+func main() {
+	validate := flag.Bool("validate", false, "validate the foreign keys that have no orphans")
+	flag.Parse()
+
+	cfg, err := config.LoadTestConfig()
+	if err != nil {
+		log.Fatalf("Fail to load config: %v", err)
+	}
+
+	db, err := database.InitPostgres(cfg)
+	if err != nil {
+		log.Fatalf("Fail to initalize database: %v", err)
+	}
+
+	orphans, err := migrations.FindOrphans(db)
+	if err != nil {
+		log.Fatalf("Fail to scan for orphans: %v", err)
+	}
+
+	dirty := make(map[string]bool)
+	for _, o := range orphans {
+		dirty[o.Name] = true
+		listed := o.IDs
+		if len(listed) > maxListed {
+			listed = listed[:maxListed]
+		}
+		fmt.Printf("%s: %d rows of %s.%s reference missing %s (ON DELETE %s), ids %v",
+			o.Name, len(o.IDs), o.Table, o.Column, o.References, o.OnDelete, listed)
+		if len(o.IDs) > len(listed) {
+			fmt.Print(" ...")
+		}
+		fmt.Println()
+	}
+	if len(orphans) == 0 {
+		fmt.Println("No orphans found")
+	}
+
+	if *validate {
+		for _, fk := range migrations.ForeignKeys {
+			if dirty[fk.Name] {
+				continue
+			}
+			if err := migrations.ValidateForeignKey(db, fk); err != nil {
+				log.Fatalf("Fail to validate %s: %v", fk.Name, err)
+			}
+			fmt.Printf("%s: validated\n", fk.Name)
+		}
+	}
+
+	if len(orphans) > 0 {
+		os.Exit(1)
+	}
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -19,6 +20,67 @@ type PostgresConfig struct {
 	DBName   string `mapstructure:"dbname"`
 }
 
+type AuthConfig struct {
+	TokenTTL              time.Duration
+	APIKeyRotationOverlap time.Duration
+	AdminName             string
+	AdminPassword         string
+}
+
+const (
+	// NamePolicyReserve keeps the names of soft-deleted players and rooms
+	// reserved until they are purged
+	NamePolicyReserve = "reserve"
+	// NamePolicyRelease frees the names as soon as a record is soft-deleted
+	NamePolicyRelease = "release"
+)
+
+type RetentionConfig struct {
+	DeletedNamePolicy string
+}
+
+type ReservationConfig struct {
+	// CancellationWindow is how long before the start players can still
+	// cancel or reschedule their own reservations
+	CancellationWindow time.Duration
+	// CheckInOpens is how long before the start check-in opens
+	CheckInOpens time.Duration
+	// NoShowGrace is how long after the start a reservation without check-in
+	// is marked as a no-show
+	NoShowGrace time.Duration
+	// SweepInterval is how often no-shows and finished reservations are swept
+	SweepInterval time.Duration
+	// WaitlistConfirmWindow is how long a player promoted from the waitlist
+	// has to confirm the reservation
+	WaitlistConfirmWindow time.Duration
+	// WaitlistLevelPriority puts players with a higher level first on the
+	// waitlist, otherwise it is first come, first served
+	WaitlistLevelPriority bool
+	// ReminderLead is how long before the start players are reminded of a
+	// reservation
+	ReminderLead time.Duration
+}
+
+type NotificationConfig struct {
+	// WebhookURL receives every notification as JSON when set
+	WebhookURL string
+	// WebhookSecret signs webhook requests with HMAC-SHA256
+	WebhookSecret string
+	// SMTPHost is the mail server, without it emails are only logged
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUser     string
+	SMTPPassword string
+	SMTPFrom     string
+}
+
+type PresenceConfig struct {
+	// SessionTimeout is how long a player stays in a room without a heartbeat
+	SessionTimeout time.Duration
+	// SweepInterval is how often timed out sessions are ended
+	SweepInterval time.Duration
+}
+
 func LoadTestConfig() (*DatabaseConfig, error) {
 	if err := godotenv.Load(".env"); err != nil {
 		return nil, fmt.Errorf("error loading .env file: %w", err)
@@ -36,3 +98,78 @@ func LoadTestConfig() (*DatabaseConfig, error) {
 
 	return cfg, nil
 }
+
+// LoadAuthConfig reads the authentication settings, must be called after the .env file is loaded
+func LoadAuthConfig() *AuthConfig {
+	return &AuthConfig{
+		TokenTTL:              getEnvDuration("AUTH_TOKEN_TTL", 24*time.Hour),
+		APIKeyRotationOverlap: getEnvDuration("API_KEY_ROTATION_OVERLAP", 24*time.Hour),
+		AdminName:             os.Getenv("ADMIN_NAME"),
+		AdminPassword:         os.Getenv("ADMIN_PASSWORD"),
+	}
+}
+
+// LoadRetentionConfig reads how soft-deleted records are handled
+func LoadRetentionConfig() *RetentionConfig {
+	policy := os.Getenv("DELETED_NAME_POLICY")
+	if policy != NamePolicyRelease {
+		policy = NamePolicyReserve
+	}
+	return &RetentionConfig{DeletedNamePolicy: policy}
+}
+
+// LoadReservationConfig reads the reservation lifecycle settings
+func LoadReservationConfig() *ReservationConfig {
+	return &ReservationConfig{
+		CancellationWindow: getEnvDuration("RESERVATION_CANCELLATION_WINDOW", 2*time.Hour),
+		CheckInOpens:       getEnvDuration("RESERVATION_CHECK_IN_OPENS", 15*time.Minute),
+		NoShowGrace:        getEnvDuration("RESERVATION_NO_SHOW_GRACE", 15*time.Minute),
+		SweepInterval:      getEnvDuration("RESERVATION_SWEEP_INTERVAL", time.Minute),
+
+		WaitlistConfirmWindow: getEnvDuration("WAITLIST_CONFIRM_WINDOW", 30*time.Minute),
+		WaitlistLevelPriority: os.Getenv("WAITLIST_LEVEL_PRIORITY") == "true",
+
+		ReminderLead: getEnvDuration("RESERVATION_REMINDER_LEAD", time.Hour),
+	}
+}
+
+// LoadNotificationConfig reads the notification channel settings
+func LoadNotificationConfig() *NotificationConfig {
+	cfg := &NotificationConfig{
+		WebhookURL:    os.Getenv("NOTIFY_WEBHOOK_URL"),
+		WebhookSecret: os.Getenv("NOTIFY_WEBHOOK_SECRET"),
+		SMTPHost:      os.Getenv("SMTP_HOST"),
+		SMTPPort:      os.Getenv("SMTP_PORT"),
+		SMTPUser:      os.Getenv("SMTP_USER"),
+		SMTPPassword:  os.Getenv("SMTP_PASSWORD"),
+		SMTPFrom:      os.Getenv("SMTP_FROM"),
+	}
+	if cfg.SMTPPort == "" {
+		cfg.SMTPPort = "587"
+	}
+	if cfg.SMTPFrom == "" {
+		cfg.SMTPFrom = "no-reply@oxo-game.local"
+	}
+	return cfg
+}
+
+// LoadPresenceConfig reads the room presence settings
+func LoadPresenceConfig() *PresenceConfig {
+	return &PresenceConfig{
+		SessionTimeout: getEnvDuration("PRESENCE_SESSION_TIMEOUT", 5*time.Minute),
+		SweepInterval:  getEnvDuration("PRESENCE_SWEEP_INTERVAL", 30*time.Second),
+	}
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fallback
+	}
+	return d
+}
@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"oxo-game-api/config"
+	"oxo-game-api/internal/api/middleware"
+	"oxo-game-api/internal/models"
+	"oxo-game-api/pkg/utils/response"
+	"oxo-game-api/pkg/utils/validator"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// errKeyNotRotatable is returned for keys that are revoked, rotated or
+// already set to expire, rotating those again would leave two replacements
+// valid during the overlap
+var errKeyNotRotatable = errors.New("API key is revoked or already rotated")
+
+type APIKeyHandler struct {
+	db  *gorm.DB
+	cfg *config.AuthConfig
+}
+
+func NewAPIKeyHandler(db *gorm.DB, cfg *config.AuthConfig) *APIKeyHandler {
+	return &APIKeyHandler{db: db, cfg: cfg}
+}
+
+// GetAPIKeys godoc
+// @Summary List API keys
+// @Description Lists every API key with its scopes and usage, secrets are never returned
+// @Tags api-keys
+// @Produce json
+// @Success 200 {array} models.APIKey
+// @Failure 403 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /api-keys [get]
+func (h *APIKeyHandler) GetAPIKeys(c *gin.Context) {
+	var keys []models.APIKey
+	if err := h.db.Order("id").Find(&keys).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch API keys")
+		return
+	}
+
+	response.Success(c, keys)
+}
+
+// CreateAPIKey godoc
+// @Summary Create an API key
+// @Description Creates a scoped API key for a game server or service client, the key is only shown once
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Param key body validator.APIKeyValidation true "API key information"
+// @Success 200 {object} response.APIKeyCreateResponse
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /api-keys [post]
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	var input validator.APIKeyValidation
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	for _, scope := range input.Scopes {
+		if !middleware.IsKnownPermission(scope) {
+			response.Error(c, http.StatusBadRequest, fmt.Sprintf("Unknown scope: %s", scope))
+			return
+		}
+	}
+
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		response.Error(c, http.StatusBadRequest, "expires_at must be in the future")
+		return
+	}
+
+	key, raw, err := h.issueKey(h.db, input.Name, input.Scopes, input.ExpiresAt, middleware.CurrentPrincipal(c))
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to create API key")
+		return
+	}
+
+	response.Success(c, newAPIKeyCreateResponse(key, raw))
+}
+
+// RotateAPIKey godoc
+// @Summary Rotate an API key
+// @Description Issues a replacement key with the same scopes, the old key keeps working during the overlap window. A key with an expiry is replaced by one with the same lifetime, and the overlap ends at its expiry at the latest. Keys that are revoked or already rotated cannot be rotated and answer 409.
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Param id path int true "API key ID"
+// @Param rotation body validator.APIKeyRotateValidation false "Overlap window as a Go duration, e.g. 1h"
+// @Success 200 {object} response.APIKeyCreateResponse
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /api-keys/{id}/rotate [post]
+func (h *APIKeyHandler) RotateAPIKey(c *gin.Context) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var input validator.APIKeyRotateValidation
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			response.Error(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	overlap := h.cfg.APIKeyRotationOverlap
+	if input.Overlap != "" {
+		overlap, err = time.ParseDuration(input.Overlap)
+		if err != nil || overlap < 0 {
+			response.Error(c, http.StatusBadRequest, "Invalid overlap duration")
+			return
+		}
+	}
+
+	var key *models.APIKey
+	var raw string
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var old models.APIKey
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&old, id).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if !old.Active(now) || old.ReplacedByID != nil {
+			return errKeyNotRotatable
+		}
+
+		graceEnds := now.Add(overlap)
+		var expiresAt *time.Time
+		if old.ExpiresAt != nil {
+			if old.ExpiresAt.Before(graceEnds) {
+				graceEnds = *old.ExpiresAt
+			}
+			renewed := now.Add(old.ExpiresAt.Sub(old.CreatedAt))
+			expiresAt = &renewed
+		}
+
+		var err error
+		key, raw, err = h.issueKey(tx, old.Name, middleware.SplitScopes(old.Scopes), expiresAt, middleware.CurrentPrincipal(c))
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&old).Updates(map[string]interface{}{
+			"expires_at":     graceEnds,
+			"replaced_by_id": key.ID,
+		}).Error
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			response.Error(c, http.StatusNotFound, "API key not found")
+		case errors.Is(err, errKeyNotRotatable):
+			response.Error(c, http.StatusConflict, err.Error())
+		default:
+			response.Error(c, http.StatusInternalServerError, "Fail to rotate API key")
+		}
+		return
+	}
+
+	response.Success(c, newAPIKeyCreateResponse(key, raw))
+}
+
+// RevokeAPIKey godoc
+// @Summary Revoke an API key
+// @Description Immediately revokes an API key
+// @Tags api-keys
+// @Produce json
+// @Param id path int true "API key ID"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /api-keys/{id} [delete]
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result := h.db.Model(&models.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to revoke API key")
+		return
+	}
+	if result.RowsAffected == 0 {
+		response.Error(c, http.StatusNotFound, "API key not found")
+		return
+	}
+
+	response.Success(c, gin.H{"message": fmt.Sprintf("API key %d is revoked successfully", id)})
+}
+
+func (h *APIKeyHandler) issueKey(tx *gorm.DB, name string, scopes []string, expiresAt *time.Time, actor *middleware.Principal) (*models.APIKey, string, error) {
+	prefix, err := generateToken()
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := generateToken()
+	if err != nil {
+		return nil, "", err
+	}
+	raw := middleware.FormatAPIKey(prefix[:12], secret)
+
+	key := &models.APIKey{
+		Name:      name,
+		Prefix:    prefix[:12],
+		KeyHash:   middleware.HashToken(raw),
+		Scopes:    strings.Join(scopes, ","),
+		ExpiresAt: expiresAt,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if actor != nil {
+		key.CreatedBy = actor.PlayerID
+	}
+
+	if err := tx.Create(key).Error; err != nil {
+		return nil, "", err
+	}
+	return key, raw, nil
+}
+
+func newAPIKeyCreateResponse(key *models.APIKey, raw string) response.APIKeyCreateResponse {
+	return response.APIKeyCreateResponse{
+		KeyID:     key.ID,
+		Key:       raw,
+		Prefix:    key.Prefix,
+		Scopes:    middleware.SplitScopes(key.Scopes),
+		ExpiresAt: key.ExpiresAt,
+	}
+}
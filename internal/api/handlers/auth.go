@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"oxo-game-api/config"
+	"oxo-game-api/internal/api/middleware"
+	"oxo-game-api/internal/models"
+	"oxo-game-api/internal/moderation"
+	"oxo-game-api/pkg/utils/response"
+	"oxo-game-api/pkg/utils/validator"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+type AuthHandler struct {
+	db  *gorm.DB
+	cfg *config.AuthConfig
+}
+
+func NewAuthHandler(db *gorm.DB, cfg *config.AuthConfig) *AuthHandler {
+	return &AuthHandler{db: db, cfg: cfg}
+}
+
+// Login godoc
+// @Summary Log in
+// @Description Exchanges a player name and password for a bearer token. Banned or suspended players get 403 with an appeal_session token that can only read and appeal their sanctions.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param credentials body validator.LoginValidation true "Player credentials"
+// @Success 200 {object} response.LoginResponse
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Router /auth/login [post]
+func (h *AuthHandler) Login(c *gin.Context) {
+	var input validator.LoginValidation
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var player models.Player
+	if err := h.db.Where("name = ?", input.Name).First(&player).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			response.Error(c, http.StatusUnauthorized, "Invalid name or password")
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch player")
+		return
+	}
+
+	if player.PasswordHash == "" ||
+		bcrypt.CompareHashAndPassword([]byte(player.PasswordHash), []byte(input.Password)) != nil {
+		response.Error(c, http.StatusUnauthorized, "Invalid name or password")
+		return
+	}
+
+	sanction, err := moderation.ActiveSanction(h.db, player.ID, time.Now())
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to check sanctions")
+		return
+	}
+
+	raw, err := generateToken()
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to issue token")
+		return
+	}
+
+	token := models.AuthToken{
+		PlayerID:   player.ID,
+		TokenHash:  middleware.HashToken(raw),
+		AppealOnly: sanction != nil,
+		ExpiresAt:  time.Now().Add(h.cfg.TokenTTL),
+		CreatedAt:  time.Now(),
+	}
+	if err := h.db.Create(&token).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to issue token")
+		return
+	}
+
+	session := response.LoginResponse{
+		Token:     raw,
+		PlayerID:  player.ID,
+		Role:      player.Role,
+		ExpiresAt: token.ExpiresAt.Format(time.RFC3339),
+	}
+	if sanction != nil {
+		response.SanctionedLogin(c, moderation.Message(sanction), sanction, session)
+		return
+	}
+	response.Success(c, session)
+}
+
+// Logout godoc
+// @Summary Log out
+// @Description Revokes the bearer token used for this request
+// @Tags auth
+// @Produce json
+// @Success 200 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /auth/logout [post]
+func (h *AuthHandler) Logout(c *gin.Context) {
+	raw := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+
+	if err := h.db.Where("token_hash = ?", middleware.HashToken(raw)).Delete(&models.AuthToken{}).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to revoke token")
+		return
+	}
+
+	response.Success(c, gin.H{"message": "Logged out successfully"})
+}
+
+func generateToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
@@ -0,0 +1,314 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"oxo-game-api/internal/api/middleware"
+	"oxo-game-api/internal/models"
+	"oxo-game-api/internal/scheduling"
+	"oxo-game-api/pkg/utils/response"
+	"oxo-game-api/pkg/utils/validator"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// defaultAvailabilityWindow is how far ahead availability looks without a to
+const defaultAvailabilityWindow = 7 * 24 * time.Hour
+
+// GetRoomAvailability godoc
+// @Summary Get the free times of a room
+// @Description Computes when the room can be booked from its opening hours, status, reservations and maintenance windows. With slot, the free time is cut into slots of that many minutes.
+// @Tags rooms
+// @Produce json
+// @Param id path int true "Room ID"
+// @Param from query string false "Start of the range in RFC 3339, defaults to now"
+// @Param to query string false "End of the range in RFC 3339, defaults to 7 days after from"
+// @Param slot query int false "Slot length in minutes"
+// @Success 200 {array} scheduling.Interval
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /rooms/{id}/availability [get]
+func (h *RoomHandler) GetRoomAvailability(c *gin.Context) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	window, slot, ok := availabilityQuery(c)
+	if !ok {
+		return
+	}
+
+	room, err := validator.FindRoomByID(h.db, id)
+	if err != nil {
+		if err.Error() == "room not found" {
+			response.Error(c, http.StatusNotFound, "Room not found")
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch room by ID")
+		return
+	}
+
+	busy, err := scheduling.Busy(h.db, []uint{room.ID}, window)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch availability")
+		return
+	}
+	free, err := scheduling.Free(room, window, busy[room.ID], time.Now())
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch availability")
+		return
+	}
+	if slot > 0 {
+		free = scheduling.Slots(free, slot)
+	}
+
+	response.Success(c, free)
+}
+
+// GetRoomsAvailability godoc
+// @Summary Find free rooms
+// @Description Lists the rooms that have free time in the range, with their free times. Ask for from=20:00, to=21:00 and slot=60 to find rooms free for that hour.
+// @Tags rooms
+// @Produce json
+// @Param from query string false "Start of the range in RFC 3339, defaults to now"
+// @Param to query string false "End of the range in RFC 3339, defaults to 7 days after from"
+// @Param slot query int false "Slot length in minutes"
+// @Success 200 {array} scheduling.RoomAvailability
+// @Failure 400 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /rooms/availability [get]
+func (h *RoomHandler) GetRoomsAvailability(c *gin.Context) {
+	window, slot, ok := availabilityQuery(c)
+	if !ok {
+		return
+	}
+
+	var rooms []models.Room
+	if err := h.db.Order("id").Find(&rooms).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Failed to fetch rooms")
+		return
+	}
+
+	ids := make([]uint, 0, len(rooms))
+	for _, room := range rooms {
+		ids = append(ids, room.ID)
+	}
+	busy, err := scheduling.Busy(h.db, ids, window)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch availability")
+		return
+	}
+
+	now := time.Now()
+	available := []scheduling.RoomAvailability{}
+	for i := range rooms {
+		free, err := scheduling.Free(&rooms[i], window, busy[rooms[i].ID], now)
+		if err != nil {
+			response.Error(c, http.StatusInternalServerError, "Fail to fetch availability")
+			return
+		}
+		if slot > 0 {
+			free = scheduling.Slots(free, slot)
+		}
+		if len(free) == 0 {
+			continue
+		}
+		available = append(available, scheduling.RoomAvailability{RoomID: rooms[i].ID, Name: rooms[i].Name, Free: free})
+	}
+
+	response.Success(c, available)
+}
+
+// GetMaintenanceWindows godoc
+// @Summary List the maintenance windows of a room
+// @Description Lists maintenance windows that have not ended yet
+// @Tags rooms
+// @Produce json
+// @Param id path int true "Room ID"
+// @Success 200 {array} models.MaintenanceWindow
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /rooms/{id}/maintenance [get]
+func (h *RoomHandler) GetMaintenanceWindows(c *gin.Context) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, err := validator.FindRoomByID(h.db, id); err != nil {
+		response.Error(c, http.StatusNotFound, "Room not found")
+		return
+	}
+
+	windows := []models.MaintenanceWindow{}
+	if err := h.db.Where("room_id = ? AND ends_at > ?", id, time.Now()).Order("starts_at").Find(&windows).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch maintenance windows")
+		return
+	}
+	response.Success(c, windows)
+}
+
+// CreateMaintenanceWindow godoc
+// @Summary Block a room for maintenance
+// @Description Blocks the room from being booked between starts_at and ends_at. Reservations already made are kept.
+// @Tags rooms
+// @Accept json
+// @Produce json
+// @Param id path int true "Room ID"
+// @Param window body validator.MaintenanceValidation true "Maintenance window"
+// @Success 200 {object} models.MaintenanceWindow
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /rooms/{id}/maintenance [post]
+func (h *RoomHandler) CreateMaintenanceWindow(c *gin.Context) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var input validator.MaintenanceValidation
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !input.EndsAt.After(input.StartsAt) {
+		response.Error(c, http.StatusBadRequest, "ends_at must be after starts_at")
+		return
+	}
+
+	room, err := validator.FindRoomByID(h.db, id)
+	if err != nil {
+		if err.Error() == "room not found" {
+			response.Error(c, http.StatusNotFound, "Room not found")
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch room by ID")
+		return
+	}
+
+	window := models.MaintenanceWindow{
+		RoomID:    room.ID,
+		StartsAt:  input.StartsAt.UTC(),
+		EndsAt:    input.EndsAt.UTC(),
+		Reason:    input.Reason,
+		CreatedBy: middleware.CurrentPrincipal(c).PlayerID,
+		CreatedAt: time.Now(),
+	}
+	if err := h.db.Create(&window).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to create maintenance window")
+		return
+	}
+	response.Success(c, window)
+}
+
+// DeleteMaintenanceWindow godoc
+// @Summary Remove a maintenance window
+// @Tags rooms
+// @Produce json
+// @Param id path int true "Room ID"
+// @Param window_id path int true "Maintenance window ID"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /rooms/{id}/maintenance/{window_id} [delete]
+func (h *RoomHandler) DeleteMaintenanceWindow(c *gin.Context) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	windowID, err := strconv.ParseUint(c.Param("window_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid maintenance window ID")
+		return
+	}
+
+	var window models.MaintenanceWindow
+	if err := h.db.Where("room_id = ?", id).First(&window, windowID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Error(c, http.StatusNotFound, "Maintenance window not found")
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch maintenance window")
+		return
+	}
+
+	if err := h.db.Delete(&window).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to delete maintenance window")
+		return
+	}
+	response.Success(c, gin.H{"message": fmt.Sprintf("Maintenance window %d is removed", window.ID)})
+}
+
+// availabilityQuery reads the range and slot length of an availability
+// request. It answers the request itself on failure.
+func availabilityQuery(c *gin.Context) (scheduling.Interval, time.Duration, bool) {
+	validator.CheckQueryParam(c, map[string]bool{"from": true, "to": true, "slot": true})
+	if c.IsAborted() {
+		return scheduling.Interval{}, 0, false
+	}
+
+	window := scheduling.Interval{Start: time.Now()}
+	if from := c.Query("from"); from != "" {
+		start, err := parseQueryTime(from)
+		if err != nil {
+			response.Error(c, http.StatusBadRequest, "Invalid from, use RFC 3339 like 2025-01-06T20:00:00Z")
+			return window, 0, false
+		}
+		window.Start = start
+	}
+	window.End = window.Start.Add(defaultAvailabilityWindow)
+	if to := c.Query("to"); to != "" {
+		end, err := parseQueryTime(to)
+		if err != nil {
+			response.Error(c, http.StatusBadRequest, "Invalid to, use RFC 3339 like 2025-01-06T21:00:00Z")
+			return window, 0, false
+		}
+		window.End = end
+	}
+	if !window.End.After(window.Start) {
+		response.Error(c, http.StatusBadRequest, "to must be after from")
+		return window, 0, false
+	}
+	if window.Duration() > scheduling.MaxWindow {
+		response.Error(c, http.StatusBadRequest, fmt.Sprintf("Availability covers at most %d days", int(scheduling.MaxWindow.Hours()/24)))
+		return window, 0, false
+	}
+
+	var slot time.Duration
+	if value := c.Query("slot"); value != "" {
+		minutes, err := strconv.Atoi(value)
+		if err != nil || minutes < 1 || time.Duration(minutes)*time.Minute > scheduling.MaxDuration {
+			response.Error(c, http.StatusBadRequest, fmt.Sprintf("slot must be a number of minutes between 1 and %d", int(scheduling.MaxDuration.Minutes())))
+			return window, 0, false
+		}
+		slot = time.Duration(minutes) * time.Minute
+	}
+	return window, slot, true
+}
+
+// parseQueryTime parses an RFC 3339 query value, a "+" offset left unescaped
+// in the URL arrives as a space
+func parseQueryTime(value string) (time.Time, error) {
+	return time.Parse(time.RFC3339, strings.Replace(value, " ", "+", 1))
+}
@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"oxo-game-api/internal/api/middleware"
+	"oxo-game-api/internal/models"
+	"oxo-game-api/pkg/utils/ical"
+	"oxo-game-api/pkg/utils/response"
+	"oxo-game-api/pkg/utils/validator"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	calendarProdID = "-//OXO Game API//Reservations//EN"
+	calendarDomain = "oxo-game-api"
+	// calendarHistory is how far back feeds list reservations that have ended
+	calendarHistory = 30 * 24 * time.Hour
+)
+
+type CalendarHandler struct {
+	db *gorm.DB
+}
+
+func NewCalendarHandler(db *gorm.DB) *CalendarHandler {
+	return &CalendarHandler{db: db}
+}
+
+// GetRoomCalendar godoc
+// @Summary Get the calendar feed of a room
+// @Description Returns the reservations of the room as an iCalendar feed, from 30 days ago on. Cancelled reservations stay in the feed as cancelled events so subscribed calendars drop them. Calendar apps can pass the token of a feed URL instead of logging in.
+// @Tags calendars
+// @Produce text/calendar
+// @Param id path int true "Room ID"
+// @Param token query string false "Calendar feed token"
+// @Success 200 {string} string "iCalendar feed"
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /rooms/{id}/calendar.ics [get]
+func (h *CalendarHandler) GetRoomCalendar(c *gin.Context) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	room, err := validator.FindRoomByID(h.db, id)
+	if err != nil {
+		if err.Error() == "room not found" {
+			response.Error(c, http.StatusNotFound, "Room not found")
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch room by ID")
+		return
+	}
+
+	var reservations []models.Reservation
+	if err := h.db.Where("room_id = ? AND ends_at >= ?", room.ID, time.Now().Add(-calendarHistory)).
+		Order("starts_at").Find(&reservations).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch reservations")
+		return
+	}
+
+	cal := ical.Calendar{ProdID: calendarProdID, Name: room.Name}
+	for _, reservation := range reservations {
+		reservation.Room = room
+		// the room feed does not say who booked the room
+		cal.Events = append(cal.Events, reservationEvent(reservation, "Reserved: "+room.Name))
+	}
+	c.Data(http.StatusOK, ical.ContentType, cal.Encode())
+}
+
+// GetPlayerCalendar godoc
+// @Summary Get the calendar feed of a player
+// @Description Returns the reservations the player hosts or takes part in as an iCalendar feed, from 30 days ago on. Cancelled reservations and declined invitations stay in the feed as cancelled events so subscribed calendars drop them. Calendar apps can pass the token of a feed URL instead of logging in.
+// @Tags calendars
+// @Produce text/calendar
+// @Param id path int true "Player ID"
+// @Param token query string false "Calendar feed token"
+// @Success 200 {string} string "iCalendar feed"
+// @Failure 400 {object} response.Response
+// @Failure 401 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /players/{id}/calendar.ics [get]
+func (h *CalendarHandler) GetPlayerCalendar(c *gin.Context) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	player, err := validator.FindPlayerByID(h.db, id)
+	if err != nil {
+		if err.Error() == "player not found" {
+			response.Error(c, http.StatusNotFound, "Player not found")
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch player by ID")
+		return
+	}
+
+	var participations []models.ReservationParticipant
+	if err := h.db.Where("player_id = ?", player.ID).Find(&participations).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch reservations")
+		return
+	}
+	declined := map[uint]bool{}
+	ids := []uint{}
+	for _, participation := range participations {
+		ids = append(ids, participation.ReservationID)
+		declined[participation.ReservationID] = participation.Status == models.InvitationDeclined
+	}
+
+	var reservations []models.Reservation
+	if err := h.db.Preload("Room", func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped()
+	}).Where("(player_id = ? OR id IN ?) AND ends_at >= ?", player.ID, ids, time.Now().Add(-calendarHistory)).
+		Order("starts_at").Find(&reservations).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch reservations")
+		return
+	}
+
+	cal := ical.Calendar{ProdID: calendarProdID, Name: player.Name}
+	for _, reservation := range reservations {
+		summary := "Reservation"
+		if reservation.Room != nil {
+			summary = reservation.Room.Name
+		}
+		event := reservationEvent(reservation, summary)
+		if declined[reservation.ID] {
+			event.Status = ical.StatusCancelled
+		}
+		cal.Events = append(cal.Events, event)
+	}
+	c.Data(http.StatusOK, ical.ContentType, cal.Encode())
+}
+
+// CreateRoomCalendarFeed godoc
+// @Summary Create a subscription URL for a room calendar
+// @Description Returns a URL with a secret token that calendar apps can subscribe to without logging in. It replaces the caller's previous URL for the room, which stops working.
+// @Tags calendars
+// @Produce json
+// @Param id path int true "Room ID"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /rooms/{id}/calendar-feed [post]
+func (h *CalendarHandler) CreateRoomCalendarFeed(c *gin.Context) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, err := validator.FindRoomByID(h.db, id); err != nil {
+		if err.Error() == "room not found" {
+			response.Error(c, http.StatusNotFound, "Room not found")
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch room by ID")
+		return
+	}
+	h.createFeed(c, models.FeedRoom, uint(id), fmt.Sprintf("/rooms/%d/calendar.ics", id))
+}
+
+// CreatePlayerCalendarFeed godoc
+// @Summary Create a subscription URL for a player calendar
+// @Description Returns a URL with a secret token that calendar apps can subscribe to without logging in. It replaces the caller's previous URL for the player, which stops working.
+// @Tags calendars
+// @Produce json
+// @Param id path int true "Player ID"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /players/{id}/calendar-feed [post]
+func (h *CalendarHandler) CreatePlayerCalendarFeed(c *gin.Context) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, err := validator.FindPlayerByID(h.db, id); err != nil {
+		if err.Error() == "player not found" {
+			response.Error(c, http.StatusNotFound, "Player not found")
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch player by ID")
+		return
+	}
+	h.createFeed(c, models.FeedPlayer, uint(id), fmt.Sprintf("/players/%d/calendar.ics", id))
+}
+
+// RevokeRoomCalendarFeed godoc
+// @Summary Revoke the subscription URL for a room calendar
+// @Tags calendars
+// @Produce json
+// @Param id path int true "Room ID"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /rooms/{id}/calendar-feed [delete]
+func (h *CalendarHandler) RevokeRoomCalendarFeed(c *gin.Context) {
+	h.revokeFeed(c, models.FeedRoom)
+}
+
+// RevokePlayerCalendarFeed godoc
+// @Summary Revoke the subscription URL for a player calendar
+// @Tags calendars
+// @Produce json
+// @Param id path int true "Player ID"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /players/{id}/calendar-feed [delete]
+func (h *CalendarHandler) RevokePlayerCalendarFeed(c *gin.Context) {
+	h.revokeFeed(c, models.FeedPlayer)
+}
+
+// createFeed issues a new token for the caller, revoking their previous one
+// for the same calendar
+func (h *CalendarHandler) createFeed(c *gin.Context, ownerType string, ownerID uint, path string) {
+	// the feed acts as its creator, so it needs a player behind it
+	creatorID, ok := actingPlayer(c, 0)
+	if !ok {
+		return
+	}
+
+	raw, err := generateToken()
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to generate token")
+		return
+	}
+
+	feed := models.CalendarFeed{
+		OwnerType: ownerType,
+		OwnerID:   ownerID,
+		TokenHash: middleware.HashToken(raw),
+		CreatedBy: creatorID,
+		CreatedAt: time.Now(),
+	}
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := revokeFeeds(tx, ownerType, ownerID, creatorID); err != nil {
+			return err
+		}
+		return tx.Create(&feed).Error
+	})
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to create calendar feed")
+		return
+	}
+
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	response.Success(c, gin.H{
+		"url":        fmt.Sprintf("%s://%s%s?token=%s", scheme, c.Request.Host, path, raw),
+		"token":      raw,
+		"created_at": feed.CreatedAt,
+	})
+}
+
+func (h *CalendarHandler) revokeFeed(c *gin.Context, ownerType string) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var feed models.CalendarFeed
+	err = h.db.Where("owner_type = ? AND owner_id = ? AND created_by = ? AND revoked_at IS NULL",
+		ownerType, id, middleware.CurrentPrincipal(c).PlayerID).First(&feed).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		response.Error(c, http.StatusNotFound, "calendar feed not found")
+		return
+	}
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch calendar feed")
+		return
+	}
+
+	if err := revokeFeeds(h.db, ownerType, uint(id), feed.CreatedBy); err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to revoke calendar feed")
+		return
+	}
+	response.Success(c, gin.H{"message": "Calendar feed revoked"})
+}
+
+func revokeFeeds(tx *gorm.DB, ownerType string, ownerID, createdBy uint) error {
+	return tx.Model(&models.CalendarFeed{}).
+		Where("owner_type = ? AND owner_id = ? AND created_by = ? AND revoked_at IS NULL", ownerType, ownerID, createdBy).
+		Update("revoked_at", time.Now()).Error
+}
+
+// reservationEvent turns a reservation into a calendar event. The UID stays
+// the same for the life of the reservation and the sequence grows when it is
+// rescheduled or cancelled.
+func reservationEvent(reservation models.Reservation, summary string) ical.Event {
+	event := ical.Event{
+		UID:          fmt.Sprintf("reservation-%d@%s", reservation.ID, calendarDomain),
+		Sequence:     reservation.Sequence,
+		Status:       ical.StatusConfirmed,
+		Start:        reservation.StartsAt,
+		End:          reservation.EndsAt,
+		Summary:      summary,
+		Created:      reservation.CreatedAt,
+		LastModified: reservation.UpdatedAt,
+	}
+	if reservation.Room != nil {
+		event.Location = reservation.Room.Name
+	}
+	if reservation.Status == models.ReservationCancelled {
+		event.Status = ical.StatusCancelled
+	}
+	return event
+}
@@ -1,11 +1,18 @@
 package handlers
 
 import (
+	"errors"
+	"log"
 	"math/rand"
 	"net/http"
 	"time"
 
+	"oxo-game-api/internal/api/middleware"
+	"oxo-game-api/internal/leaderboard"
 	"oxo-game-api/internal/models"
+	"oxo-game-api/internal/notify"
+	"oxo-game-api/internal/outbox"
+	"oxo-game-api/internal/progression"
 	"oxo-game-api/pkg/utils/response"
 	"oxo-game-api/pkg/utils/validator"
 
@@ -13,6 +20,16 @@ import (
 	"gorm.io/gorm"
 )
 
+// challengeEntryFee is what joining a challenge costs, the fees collected
+// since the last win make up the prize pool
+const challengeEntryFee = 20.01
+
+// settlementLock is the advisory lock key serializing challenge settlements,
+// so two winners cannot both be paid the same pool
+const settlementLock = 3001
+
+var errInsufficientBalance = errors.New("insufficient balance")
+
 type ChallengeHandler struct {
 	db *gorm.DB
 }
@@ -25,7 +42,7 @@ func NewChallengeHandler(db *gorm.DB) *ChallengeHandler {
 
 // JoinChallenge godoc
 // @Summary Join a challenge
-// @Description Player joins a challenge by POST, returns status
+// @Description Player joins a challenge by POST, returns status. The entry fee is always 20.01, an amount sent in the body is ignored.
 // @Tags challenges
 // @Accept json
 // @Produce json
@@ -34,7 +51,9 @@ func NewChallengeHandler(db *gorm.DB) *ChallengeHandler {
 // @Failure 400 {object} response.Response
 // @Failure 404 {object} response.Response
 // @Failure 403 {object} response.Response
+// @Failure 422 {object} response.Response
 // @Failure 500 {object} response.Response
+// @Security BearerAuth
 // @Router /challenges [post]
 func (h *ChallengeHandler) JoinChallenge(c *gin.Context) {
 	var challenge models.Challenge
@@ -43,6 +62,19 @@ func (h *ChallengeHandler) JoinChallenge(c *gin.Context) {
 		return
 	}
 
+	principal := middleware.CurrentPrincipal(c)
+	var acting bool
+	if challenge.PlayerID, acting = actingPlayer(c, challenge.PlayerID); !acting {
+		return
+	}
+	if !principal.CanActFor(challenge.PlayerID, middleware.PermChallengesWrite) {
+		response.PermissionDenied(c, middleware.PermChallengesWrite)
+		return
+	}
+	if rejectSanctioned(c, h.db, challenge.PlayerID) {
+		return
+	}
+
 	var lastChallenge models.Challenge
 	if err := h.db.Where("player_id = ?", challenge.PlayerID).Order("created_at desc").First(&lastChallenge).Error; err == nil {
 		if time.Since(lastChallenge.CreatedAt) < time.Minute {
@@ -51,56 +83,59 @@ func (h *ChallengeHandler) JoinChallenge(c *gin.Context) {
 		}
 	}
 
-	player, err := validator.FindPlayerByID(h.db, uint64(challenge.PlayerID))
-	if err != nil {
+	if _, err := validator.FindPlayerByID(h.db, uint64(challenge.PlayerID)); err != nil {
 		response.Error(c, http.StatusNotFound, err.Error())
 		return
 	}
 
-	if player.Balance < 20.01 {
+	challenge.Amount = challengeEntryFee
+	challenge.CreatedAt = time.Now()
+	challenge.UpdatedAt = time.Now()
+
+	// the fee is taken in the same transaction as the entry, a player is never
+	// charged for a challenge that was not created
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Player{}).Where("id = ? AND balance >= ?", challenge.PlayerID, challengeEntryFee).
+			UpdateColumn("balance", gorm.Expr("balance - ?", challengeEntryFee))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errInsufficientBalance
+		}
+		return tx.Create(&challenge).Error
+	})
+	if errors.Is(err, errInsufficientBalance) {
 		//response.Error(c, http.StatusPaymentRequired, "Insufficient balance to join the challenge.")
 		responseData := response.JoinResponse{
 			Success:     false,
 			ChallengeID: challenge.ID,
 			Message:     "Fail to join the challenge due to insufficient balance.",
 		}
-	
-		response.Success(c, responseData)
-		return
-	}
 
-	player.Balance -= 20.01
-	if err := h.db.Save(&player).Error; err != nil {
-		response.Error(c, http.StatusInternalServerError, "Fail to update player's balance")
+		response.Success(c, responseData)
 		return
 	}
-
-	challenge.CreatedAt = time.Now()
-	challenge.UpdatedAt = time.Now()
-
-	if err := h.db.Create(&challenge).Error; err != nil {
+	if err != nil {
+		if validator.IsForeignKeyViolation(err) {
+			response.Error(c, http.StatusUnprocessableEntity, "Challenge references a player that does not exist")
+			return
+		}
 		response.Error(c, http.StatusInternalServerError, "Fail to join the challenge")
 		return
 	}
 
-	go func(challengID uint, playerID uint) {
+	go func(challenge models.Challenge) {
 		time.Sleep(30 * time.Second)
-		// 1% chance to win lgoic
-		rng := rand.New(rand.NewSource(time.Now().UnixNano()))
-		won := rng.Intn(100) < 1
-
-		result := models.ChallengeResult{
-			ChallengeID: challenge.ID,
-			PlayerID:    challenge.PlayerID,
-			Won:         won,
-			CreatedAt:   time.Now(),
-		}
+		h.settleChallenge(challenge)
+	}(challenge)
 
-		if err := h.db.Create(&result).Error; err != nil {
-			response.Error(c, http.StatusInternalServerError, "Failed to record challenge result")
-			return
-		}
-	}(challenge.ID, challenge.PlayerID)
+	if _, err := progression.AwardExperience(h.db, challenge.PlayerID, progression.ExpChallengeJoin, "challenge joined"); err != nil {
+		log.Printf("Error awarding experience to player %d: %v", challenge.PlayerID, err)
+	}
+	if err := leaderboard.Increment(h.db, leaderboard.KindEntries, challenge.PlayerID, 1, challenge.CreatedAt); err != nil {
+		log.Printf("Error updating entries leaderboard for player %d: %v", challenge.PlayerID, err)
+	}
 	
 	responseData := response.JoinResponse{
         Success:     true,
@@ -117,6 +152,78 @@ func (h *ChallengeHandler) JoinChallenge(c *gin.Context) {
 	//})
 }
 
+// settleChallenge draws the result of a finished challenge, a win pays out the
+// whole prize pool collected since the previous win
+func (h *ChallengeHandler) settleChallenge(challenge models.Challenge) {
+	// 1% chance to win lgoic
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	won := rng.Intn(100) < 1
+
+	result := models.ChallengeResult{
+		ChallengeID: challenge.ID,
+		PlayerID:    challenge.PlayerID,
+		Won:         won,
+		CreatedAt:   time.Now(),
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", settlementLock).Error; err != nil {
+			return err
+		}
+		if won {
+			prize, err := prizePool(tx, challenge.ID)
+			if err != nil {
+				return err
+			}
+			result.Prize = prize
+
+			if err := tx.Model(&models.Player{}).Where("id = ?", challenge.PlayerID).
+				UpdateColumn("balance", gorm.Expr("balance + ?", prize)).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Create(&result).Error; err != nil {
+			return err
+		}
+		if err := outbox.Publish(tx, notify.EventChallengeSettled, challenge.PlayerID, map[string]interface{}{
+			"challenge_id": challenge.ID,
+			"won":          result.Won,
+			"prize":        result.Prize,
+		}); err != nil {
+			return err
+		}
+
+		if won {
+			if err := leaderboard.Increment(tx, leaderboard.KindWins, challenge.PlayerID, 1, result.CreatedAt); err != nil {
+				return err
+			}
+			return leaderboard.Increment(tx, leaderboard.KindWinnings, challenge.PlayerID, result.Prize, result.CreatedAt)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error recording result of challenge %d: %v", challenge.ID, err)
+		return
+	}
+
+	if won {
+		if _, err := progression.AwardExperience(h.db, challenge.PlayerID, progression.ExpChallengeWin, "challenge won"); err != nil {
+			log.Printf("Error awarding experience to player %d: %v", challenge.PlayerID, err)
+		}
+	}
+}
+
+// prizePool sums every entry fee paid since the last winning challenge up to
+// and including challengeID
+func prizePool(tx *gorm.DB, challengeID uint) (float64, error) {
+	var pool float64
+	err := tx.Raw(`SELECT COALESCE(SUM(amount), 0) FROM challenges
+		WHERE id <= ? AND id > (SELECT COALESCE(MAX(challenge_id), 0) FROM challenge_results WHERE won)`,
+		challengeID).Scan(&pool).Error
+	return pool, err
+}
+
 // GetChallengeResults godoc
 // @Summary Get challenge results
 // @Description Fetches all challenge results
@@ -124,6 +231,7 @@ func (h *ChallengeHandler) JoinChallenge(c *gin.Context) {
 // @Produce json
 // @Success 200 {array} models.ChallengeResult
 // @Failure 500 {object} response.Response
+// @Security BearerAuth
 // @Router /challenges/results [get]
 func (h *ChallengeHandler) GetChallengeResults(c *gin.Context) {
 	var results []models.ChallengeResult
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"time"
+
+	"oxo-game-api/pkg/utils/etag"
+	"oxo-game-api/pkg/utils/response"
+	"oxo-game-api/pkg/utils/validator"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const staleMessage = "Resource has been modified, fetch it again"
+
+var errStale = errors.New("resource has been modified")
+
+// bindUpdate fills input from the request body. PUT replaces input with the
+// body, PATCH merges the body onto input, which must hold the current state.
+func bindUpdate(c *gin.Context, input interface{}) error {
+	if c.Request.Method == http.MethodPatch {
+		return validator.BindMergePatch(c, input)
+	}
+
+	value := reflect.ValueOf(input).Elem()
+	value.Set(reflect.Zero(value.Type()))
+	return c.ShouldBindJSON(input)
+}
+
+// bindError answers a failed bindUpdate, message replaces the validation
+// error when set
+func bindError(c *gin.Context, err error, message string) {
+	if errors.Is(err, validator.ErrUnsupportedPatchType) {
+		response.Error(c, http.StatusUnsupportedMediaType, err.Error())
+		return
+	}
+	if message == "" {
+		message = err.Error()
+	}
+	response.Error(c, http.StatusBadRequest, message)
+}
+
+// ifMatch answers 412 and reports false when the If-Match header does not
+// match the version of the record the request is about to change
+func ifMatch(c *gin.Context, id uint, updatedAt time.Time) bool {
+	if etag.Match(c, etag.Of(id, updatedAt)) {
+		return true
+	}
+	response.Error(c, http.StatusPreconditionFailed, staleMessage)
+	return false
+}
+
+// lockVersion locks the row of model and fails with errStale when it changed
+// since it was read at updatedAt, so an update never overwrites a concurrent
+// one it has not seen
+func lockVersion(tx *gorm.DB, model interface{}, id uint, updatedAt time.Time) error {
+	var current struct {
+		UpdatedAt time.Time
+	}
+	if err := tx.Model(model).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("updated_at").Where("id = ?", id).Take(&current).Error; err != nil {
+		return err
+	}
+	if !current.UpdatedAt.Equal(updatedAt) {
+		return errStale
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"oxo-game-api/pkg/utils/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CustomMethod dispatches collection actions written as "/players:bulk".
+// Gin matches such paths with a route like "/players:action" whose parameter
+// holds ":bulk", methods maps the action name to its handler.
+func CustomMethod(methods map[string]gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		action := strings.TrimPrefix(c.Param("action"), ":")
+		handler, ok := methods[action]
+		if !ok {
+			response.Error(c, http.StatusNotFound, "Not found")
+			return
+		}
+		handler(c)
+	}
+}
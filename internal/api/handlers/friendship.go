@@ -0,0 +1,373 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"oxo-game-api/internal/api/middleware"
+	"oxo-game-api/internal/models"
+	"oxo-game-api/internal/social"
+	"oxo-game-api/pkg/utils/response"
+	"oxo-game-api/pkg/utils/validator"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type FriendshipHandler struct {
+	db *gorm.DB
+}
+
+func NewFriendshipHandler(db *gorm.DB) *FriendshipHandler {
+	return &FriendshipHandler{db: db}
+}
+
+// GetFriends godoc
+// @Summary Get a player's friends
+// @Description Lists a player's friends, subject to the player's friend list visibility. Friends sharing their presence show the room they are in.
+// @Tags friends
+// @Produce json
+// @Param id path int true "Player ID"
+// @Success 200 {array} social.Friend
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /players/{id}/friends [get]
+func (h *FriendshipHandler) GetFriends(c *gin.Context) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if _, err := validator.FindPlayerByID(h.db, id); err != nil {
+		response.Error(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	principal := middleware.CurrentPrincipal(c)
+	if !principal.Can(middleware.PermPlayersRead) {
+		settings, err := social.Settings(h.db, uint(id))
+		if err != nil {
+			response.Error(c, http.StatusInternalServerError, "Fail to fetch privacy settings")
+			return
+		}
+		visible, err := social.CanSee(h.db, settings.FriendListVisibility, principal.PlayerID, uint(id))
+		if err != nil {
+			response.Error(c, http.StatusInternalServerError, "Fail to check friendship")
+			return
+		}
+		if !visible {
+			response.Error(c, http.StatusForbidden, "This player's friend list is private")
+			return
+		}
+	}
+
+	friends, err := social.FriendList(h.db, uint(id), principal.PlayerID)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch friends")
+		return
+	}
+
+	response.Success(c, friends)
+}
+
+// GetFriendRequests godoc
+// @Summary Get a player's pending friend requests
+// @Description Lists the pending requests sent and received by a player
+// @Tags friends
+// @Produce json
+// @Param id path int true "Player ID"
+// @Success 200 {array} models.Friendship
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /players/{id}/friend-requests [get]
+func (h *FriendshipHandler) GetFriendRequests(c *gin.Context) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var requests []models.Friendship
+	if err := h.db.Where("(requester_id = ? OR addressee_id = ?) AND status = ?", id, id, models.FriendshipPending).
+		Order("created_at desc, id desc").Find(&requests).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch friend requests")
+		return
+	}
+
+	response.Success(c, requests)
+}
+
+// SendFriendRequest godoc
+// @Summary Send a friend request
+// @Description Asks another player to become friends. Answering a pending request from that player accepts it.
+// @Tags friends
+// @Accept json
+// @Produce json
+// @Param request body validator.FriendRequestValidation true "Friend request"
+// @Success 200 {object} models.Friendship
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /friendships [post]
+func (h *FriendshipHandler) SendFriendRequest(c *gin.Context) {
+	var input validator.FriendRequestValidation
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	requesterID, ok := actingPlayer(c, 0)
+	if !ok {
+		return
+	}
+	if _, err := validator.FindPlayerByID(h.db, uint64(input.PlayerID)); err != nil {
+		response.Error(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	friendship, err := social.SendRequest(h.db, requesterID, input.PlayerID)
+	if err != nil {
+		friendshipError(c, err, "Fail to send friend request")
+		return
+	}
+
+	response.Success(c, friendship)
+}
+
+// AcceptFriendRequest godoc
+// @Summary Accept a friend request
+// @Tags friends
+// @Produce json
+// @Param id path int true "Friendship ID"
+// @Success 200 {object} models.Friendship
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /friendships/{id}/accept [post]
+func (h *FriendshipHandler) AcceptFriendRequest(c *gin.Context) {
+	h.respond(c, true)
+}
+
+// DeclineFriendRequest godoc
+// @Summary Decline a friend request
+// @Tags friends
+// @Produce json
+// @Param id path int true "Friendship ID"
+// @Success 200 {object} models.Friendship
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /friendships/{id}/decline [post]
+func (h *FriendshipHandler) DeclineFriendRequest(c *gin.Context) {
+	h.respond(c, false)
+}
+
+func (h *FriendshipHandler) respond(c *gin.Context, accept bool) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	playerID, ok := actingPlayer(c, 0)
+	if !ok {
+		return
+	}
+
+	friendship, err := social.Respond(h.db, uint(id), playerID, accept)
+	if err != nil {
+		friendshipError(c, err, "Fail to answer friend request")
+		return
+	}
+
+	response.Success(c, friendship)
+}
+
+// DeleteFriendship godoc
+// @Summary Remove a friendship
+// @Description Unfriends, withdraws a request or lifts a block set by the caller
+// @Tags friends
+// @Produce json
+// @Param id path int true "Friendship ID"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /friendships/{id} [delete]
+func (h *FriendshipHandler) DeleteFriendship(c *gin.Context) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	playerID, ok := actingPlayer(c, 0)
+	if !ok {
+		return
+	}
+
+	if err := social.Remove(h.db, uint(id), playerID); err != nil {
+		friendshipError(c, err, "Fail to remove friendship")
+		return
+	}
+
+	response.Success(c, gin.H{"message": "Friendship removed"})
+}
+
+// BlockPlayer godoc
+// @Summary Block a player
+// @Description Blocks all friend requests and invitations between the caller and a player
+// @Tags friends
+// @Produce json
+// @Param id path int true "Player ID"
+// @Success 200 {object} models.Friendship
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /players/{id}/block [post]
+func (h *FriendshipHandler) BlockPlayer(c *gin.Context) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	blockerID, ok := actingPlayer(c, 0)
+	if !ok {
+		return
+	}
+	if _, err := validator.FindPlayerByID(h.db, id); err != nil {
+		response.Error(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	friendship, err := social.Block(h.db, blockerID, uint(id))
+	if err != nil {
+		friendshipError(c, err, "Fail to block player")
+		return
+	}
+
+	response.Success(c, friendship)
+}
+
+// GetPrivacySettings godoc
+// @Summary Get a player's privacy settings
+// @Tags friends
+// @Produce json
+// @Param id path int true "Player ID"
+// @Success 200 {object} models.PrivacySettings
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /players/{id}/privacy [get]
+func (h *FriendshipHandler) GetPrivacySettings(c *gin.Context) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	settings, err := social.Settings(h.db, uint(id))
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch privacy settings")
+		return
+	}
+
+	response.Success(c, settings)
+}
+
+// UpdatePrivacySettings godoc
+// @Summary Update a player's privacy settings
+// @Description Changes who can send friend requests and who can see the friend list and room presence. Omitted fields are left unchanged.
+// @Tags friends
+// @Accept json
+// @Produce json
+// @Param id path int true "Player ID"
+// @Param settings body validator.PrivacyValidation true "Privacy settings"
+// @Success 200 {object} models.PrivacySettings
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /players/{id}/privacy [put]
+func (h *FriendshipHandler) UpdatePrivacySettings(c *gin.Context) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var input validator.PrivacyValidation
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if _, err := validator.FindPlayerByID(h.db, id); err != nil {
+		response.Error(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	settings, err := social.Settings(h.db, uint(id))
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch privacy settings")
+		return
+	}
+	if input.FriendRequests != "" {
+		settings.FriendRequests = input.FriendRequests
+	}
+	if input.FriendListVisibility != "" {
+		settings.FriendListVisibility = input.FriendListVisibility
+	}
+	if input.PresenceVisibility != "" {
+		settings.PresenceVisibility = input.PresenceVisibility
+	}
+	settings.UpdatedAt = time.Now()
+
+	if err := h.db.Save(&settings).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to update privacy settings")
+		return
+	}
+
+	response.Success(c, settings)
+}
+
+func friendshipError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, social.ErrFriendshipNotFound):
+		response.Error(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, social.ErrSelfRequest):
+		response.Error(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, social.ErrBlocked),
+		errors.Is(err, social.ErrRequestsClosed),
+		errors.Is(err, social.ErrNotAddressee):
+		response.Error(c, http.StatusForbidden, err.Error())
+	case errors.Is(err, social.ErrAlreadyFriends),
+		errors.Is(err, social.ErrRequestPending),
+		errors.Is(err, social.ErrNotPending):
+		response.Error(c, http.StatusConflict, err.Error())
+	default:
+		response.Error(c, http.StatusInternalServerError, fallback)
+	}
+}
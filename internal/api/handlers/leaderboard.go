@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"oxo-game-api/internal/api/middleware"
+	"oxo-game-api/internal/leaderboard"
+	"oxo-game-api/pkg/utils/response"
+	"oxo-game-api/pkg/utils/validator"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type LeaderboardHandler struct {
+	db *gorm.DB
+}
+
+func NewLeaderboardHandler(db *gorm.DB) *LeaderboardHandler {
+	return &LeaderboardHandler{db: db}
+}
+
+// GetLeaderboard godoc
+// @Summary Get a leaderboard
+// @Description Ranks players by experience, challenge wins, total winnings or challenge entries
+// @Tags leaderboards
+// @Produce json
+// @Param kind path string true "Leaderboard kind" Enums(experience, wins, winnings, entries)
+// @Param window query string false "Time window, defaults to all_time" Enums(daily, weekly, all_time)
+// @Param limit query int false "Page size, 1 to 100, defaults to 20"
+// @Param offset query int false "Number of entries to skip"
+// @Success 200 {object} response.Page{items=[]leaderboard.Entry}
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /leaderboards/{kind} [get]
+func (h *LeaderboardHandler) GetLeaderboard(c *gin.Context) {
+	allowedParams := map[string]bool{
+		"window": true,
+		"limit":  true,
+		"offset": true,
+	}
+
+	validator.CheckQueryParam(c, allowedParams)
+
+	if c.IsAborted() {
+		return
+	}
+
+	kind, window, ok := leaderboardParams(c)
+	if !ok {
+		return
+	}
+
+	limit, offset, err := validator.GetPagination(c, 20, 100)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	entries, total, err := leaderboard.Top(h.db, kind, window, time.Now(), limit, offset)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch leaderboard")
+		return
+	}
+
+	response.Success(c, response.Page{
+		Items:  entries,
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	})
+}
+
+// GetMyRank godoc
+// @Summary Get my rank
+// @Description Returns the caller's rank and score on a leaderboard
+// @Tags leaderboards
+// @Produce json
+// @Param kind path string true "Leaderboard kind" Enums(experience, wins, winnings, entries)
+// @Param window query string false "Time window, defaults to all_time" Enums(daily, weekly, all_time)
+// @Success 200 {object} leaderboard.Entry
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /leaderboards/{kind}/me [get]
+func (h *LeaderboardHandler) GetMyRank(c *gin.Context) {
+	allowedParams := map[string]bool{
+		"window": true,
+	}
+
+	validator.CheckQueryParam(c, allowedParams)
+
+	if c.IsAborted() {
+		return
+	}
+
+	kind, window, ok := leaderboardParams(c)
+	if !ok {
+		return
+	}
+
+	principal := middleware.CurrentPrincipal(c)
+	if principal.PlayerID == 0 {
+		response.Error(c, http.StatusBadRequest, "Only players have a rank")
+		return
+	}
+
+	entry, err := leaderboard.Rank(h.db, kind, window, time.Now(), principal.PlayerID)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch rank")
+		return
+	}
+	if entry == nil {
+		response.Error(c, http.StatusNotFound, "You are not ranked on this leaderboard yet")
+		return
+	}
+
+	response.Success(c, entry)
+}
+
+// RebuildLeaderboards godoc
+// @Summary Rebuild leaderboards
+// @Description Recomputes the current challenge leaderboards and the all-time experience board from the source tables
+// @Tags leaderboards
+// @Produce json
+// @Success 200 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /leaderboards/rebuild [post]
+func (h *LeaderboardHandler) RebuildLeaderboards(c *gin.Context) {
+	if err := leaderboard.Rebuild(h.db, time.Now()); err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to rebuild leaderboards")
+		return
+	}
+
+	response.Success(c, gin.H{"message": "Leaderboards are rebuilt successfully"})
+}
+
+func leaderboardParams(c *gin.Context) (string, string, bool) {
+	kind := c.Param("kind")
+	if !leaderboard.Kinds[kind] {
+		response.Error(c, http.StatusNotFound, fmt.Sprintf("Unknown leaderboard: %s", kind))
+		return "", "", false
+	}
+
+	window := c.DefaultQuery("window", leaderboard.WindowAllTime)
+	if !leaderboard.IsValidWindow(window) {
+		response.Error(c, http.StatusBadRequest, fmt.Sprintf("Invalid window: %s", window))
+		return "", "", false
+	}
+
+	return kind, window, true
+}
@@ -1,15 +1,21 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"oxo-game-api/internal/models"
+	"oxo-game-api/internal/progression"
+	"oxo-game-api/pkg/utils/etag"
 	"oxo-game-api/pkg/utils/response"
 	"oxo-game-api/pkg/utils/validator"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type LevelHandler struct {
@@ -29,10 +35,11 @@ func NewLevelHandler(db *gorm.DB) *LevelHandler {
 // @Param level body models.Level true "Level information"
 // @Success 200 {object} models.Level
 // @Failure 500 {object} response.Response
+// @Security BearerAuth
 // @Router /levels [get]
 func (h *LevelHandler) GetLevels(c *gin.Context) {
 	var levels []models.Level
-	if err := h.db.Find(&levels).Error; err != nil {
+	if err := h.db.Order("min_exp").Find(&levels).Error; err != nil {
 		response.Error(c, http.StatusInternalServerError, "Fail to fetch levels")
 		return
 	}
@@ -42,14 +49,18 @@ func (h *LevelHandler) GetLevels(c *gin.Context) {
 
 // CreateLevel godoc
 // @Summary Create a new level
-// @Description Create a new level with the specified name
+// @Description Create a new level, its range must extend the existing ladder without overlaps or gaps
 // @Tags levels
 // @Accept json
 // @Produce json
 // @Param level body models.Level true "Level information"
 // @Success 200 {object} response.LevelCreateResponse
 // @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 422 {object} response.Response
 // @Failure 500 {object} response.Response
+// @Security BearerAuth
 // @Router /levels [post]
 func (h *LevelHandler) CreateLevel(c *gin.Context) {
 	var input validator.LevelValidation
@@ -61,12 +72,27 @@ func (h *LevelHandler) CreateLevel(c *gin.Context) {
 	level := models.Level{
 		Name:        input.Name,
 		Description: input.Description,
-		MinExp:      uint(input.MinExp),
-		MaxExp:      uint(input.MaxExp),
+		MinExp:      uint(*input.MinExp),
+		MaxExp:      uint(*input.MaxExp),
 		CreatedAt:   time.Now(),
 	}
 
-	if err := h.db.Create(&level).Error; err != nil {
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		levels, err := lockLadder(tx)
+		if err != nil {
+			return err
+		}
+		if err := progression.ValidateLadder(append(levels, level)); err != nil {
+			return err
+		}
+		return tx.Create(&level).Error
+	})
+	if err != nil {
+		var ladderErr *progression.LadderError
+		if errors.As(err, &ladderErr) {
+			response.Error(c, http.StatusUnprocessableEntity, ladderErr.Error())
+			return
+		}
 
 		if strings.Contains(err.Error(), "unique constraint") {
 			response.Error(c, http.StatusConflict, "Level name already exists")
@@ -81,3 +107,272 @@ func (h *LevelHandler) CreateLevel(c *gin.Context) {
 		"level_id": level.ID,
 	})
 }
+
+// GetLevelByID godoc
+// @Summary Get a level by ID
+// @Tags levels
+// @Produce json
+// @Param id path int true "Level ID"
+// @Success 200 {object} models.Level
+// @Header 200 {string} ETag "Version of the level, for If-Match"
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /levels/{id} [get]
+func (h *LevelHandler) GetLevelByID(c *gin.Context) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var level models.Level
+	if err := h.db.First(&level, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Error(c, http.StatusNotFound, "Level not found")
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch level")
+		return
+	}
+
+	etag.Set(c, etag.Of(level.ID, level.UpdatedAt))
+	response.Success(c, level)
+}
+
+// UpdateLevelByID godoc
+// @Summary Update a level by ID
+// @Description Updates a level, the resulting ladder must stay contiguous and non-overlapping. Players move to the level their experience falls in. PUT replaces the level, PATCH applies a JSON merge patch (RFC 7396).
+// @Tags levels
+// @Accept json
+// @Produce json
+// @Param id path int true "Level ID"
+// @Param If-Match header string false "ETag of the version being changed"
+// @Param level body validator.LevelValidation true "Updated level information"
+// @Success 200 {object} models.Level
+// @Header 200 {string} ETag "New version of the level"
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 412 {object} response.Response
+// @Failure 415 {object} response.Response
+// @Failure 422 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /levels/{id} [put]
+// @Router /levels/{id} [patch]
+func (h *LevelHandler) UpdateLevelByID(c *gin.Context) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var level models.Level
+	if err := h.db.First(&level, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Error(c, http.StatusNotFound, "Level not found")
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch level")
+		return
+	}
+	if !ifMatch(c, level.ID, level.UpdatedAt) {
+		return
+	}
+
+	minExp, maxExp := int(level.MinExp), int(level.MaxExp)
+	input := validator.LevelValidation{
+		Name:        level.Name,
+		Description: level.Description,
+		MinExp:      &minExp,
+		MaxExp:      &maxExp,
+	}
+	if err := bindUpdate(c, &input); err != nil {
+		bindError(c, err, "Invalid JSON Format/missing required field")
+		return
+	}
+
+	version := level.UpdatedAt
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		levels, err := lockLadder(tx)
+		if err != nil {
+			return err
+		}
+
+		found := false
+		for i := range levels {
+			if levels[i].ID == uint(id) {
+				if !levels[i].UpdatedAt.Equal(version) {
+					return errStale
+				}
+				levels[i].Name = input.Name
+				levels[i].Description = input.Description
+				levels[i].MinExp = uint(*input.MinExp)
+				levels[i].MaxExp = uint(*input.MaxExp)
+				levels[i].UpdatedAt = time.Now()
+				level = levels[i]
+				found = true
+			}
+		}
+		if !found {
+			return gorm.ErrRecordNotFound
+		}
+
+		if err := progression.ValidateLadder(levels); err != nil {
+			return err
+		}
+		if err := tx.Save(&level).Error; err != nil {
+			return err
+		}
+		_, err = progression.Relevel(tx, levels, "level range changed")
+		return err
+	})
+	if err != nil {
+		var ladderErr *progression.LadderError
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			response.Error(c, http.StatusNotFound, "Level not found")
+		case errors.Is(err, errStale):
+			response.Error(c, http.StatusPreconditionFailed, staleMessage)
+		case errors.As(err, &ladderErr):
+			response.Error(c, http.StatusUnprocessableEntity, ladderErr.Error())
+		case strings.Contains(err.Error(), "unique constraint"):
+			response.Error(c, http.StatusConflict, "Level name already exists")
+		default:
+			response.Error(c, http.StatusInternalServerError, "Fail to update level")
+		}
+		return
+	}
+
+	etag.Set(c, etag.Of(level.ID, level.UpdatedAt))
+	response.Success(c, level)
+}
+
+// DeleteLevelByID godoc
+// @Summary Delete a level by ID
+// @Description Deletes a level, its range is absorbed by the level below (or above for the lowest level). Levels still used by players need reassign=true to confirm the move. Players then take the level their experience falls in, their experience is kept.
+// @Tags levels
+// @Produce json
+// @Param id path int true "Level ID"
+// @Param reassign query bool false "Confirms moving the players of the deleted level to the level their experience falls in"
+// @Param If-Match header string false "ETag of the version being deleted"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 412 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /levels/{id} [delete]
+func (h *LevelHandler) DeleteLevelByID(c *gin.Context) {
+	allowedParams := map[string]bool{
+		"reassign": true,
+	}
+
+	validator.CheckQueryParam(c, allowedParams)
+
+	if c.IsAborted() {
+		return
+	}
+
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	reassign := false
+	if value := c.Query("reassign"); value != "" {
+		reassign, err = strconv.ParseBool(value)
+		if err != nil {
+			response.Error(c, http.StatusBadRequest, "reassign must be true or false")
+			return
+		}
+	}
+
+	var reassigned int
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		levels, err := lockLadder(tx)
+		if err != nil {
+			return err
+		}
+
+		found := false
+		for i := range levels {
+			if levels[i].ID == uint(id) {
+				if !etag.Match(c, etag.Of(levels[i].ID, levels[i].UpdatedAt)) {
+					return errStale
+				}
+				found = true
+			}
+		}
+		if !found {
+			return gorm.ErrRecordNotFound
+		}
+		if len(levels) == 1 {
+			return errLastLevel
+		}
+
+		var players int64
+		if err := tx.Model(&models.Player{}).Where("level_id = ?", id).Count(&players).Error; err != nil {
+			return err
+		}
+		if players > 0 && !reassign {
+			return errLevelInUse
+		}
+
+		ladder := progression.WithoutLevel(levels, uint(id))
+		for _, level := range ladder {
+			if err := tx.Model(&models.Level{}).Where("id = ?", level.ID).
+				Updates(map[string]interface{}{"min_exp": level.MinExp, "max_exp": level.MaxExp}).Error; err != nil {
+				return err
+			}
+		}
+		if reassigned, err = progression.Relevel(tx, ladder, "level deleted"); err != nil {
+			return err
+		}
+		return tx.Delete(&models.Level{}, id).Error
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			response.Error(c, http.StatusNotFound, "Level not found")
+		case errors.Is(err, errStale):
+			response.Error(c, http.StatusPreconditionFailed, staleMessage)
+		case errors.Is(err, errLevelInUse), errors.Is(err, errLastLevel):
+			response.Error(c, http.StatusConflict, err.Error())
+		default:
+			response.Error(c, http.StatusInternalServerError, "Fail to delete level")
+		}
+		return
+	}
+
+	response.Success(c, gin.H{
+		"message":            fmt.Sprintf("Level %d is deleted successfully", id),
+		"reassigned_players": reassigned,
+	})
+}
+
+var (
+	errLevelInUse = errors.New("level is still used by players, pass reassign=true to move them")
+	errLastLevel  = errors.New("cannot delete the last level")
+)
+
+// ladderLock is the advisory lock key serializing changes to the level ladder
+const ladderLock = 3002
+
+// lockLadder takes the ladder lock and loads every level, so concurrent ladder
+// changes are validated one after another. Row locks alone would not stop two
+// new levels from being inserted side by side.
+func lockLadder(tx *gorm.DB) ([]models.Level, error) {
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", ladderLock).Error; err != nil {
+		return nil, err
+	}
+	var levels []models.Level
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Order("min_exp").Find(&levels).Error
+	return levels, err
+}
@@ -5,7 +5,9 @@ import (
 	"strconv"
 	"time"
 
+	"oxo-game-api/internal/api/middleware"
 	"oxo-game-api/internal/models"
+	"oxo-game-api/internal/presence"
 	"oxo-game-api/pkg/utils/response"
 	"oxo-game-api/pkg/utils/validator"
 
@@ -14,11 +16,12 @@ import (
 )
 
 type LogHandler struct {
-	db *gorm.DB
+	db      *gorm.DB
+	tracker *presence.Tracker
 }
 
-func NewLogHandler(db *gorm.DB) *LogHandler {
-	return &LogHandler{db: db}
+func NewLogHandler(db *gorm.DB, tracker *presence.Tracker) *LogHandler {
+	return &LogHandler{db: db, tracker: tracker}
 }
 
 // GetLogs godoc
@@ -33,8 +36,10 @@ func NewLogHandler(db *gorm.DB) *LogHandler {
 // @Param limit query int false "Limit the number of logs"
 // @Success 200 {array} models.GameLog
 // @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
 // @Failure 404 {object} response.Response
 // @Failure 500 {object} response.Response
+// @Security BearerAuth
 // @Router /logs [get]
 func (h *LogHandler) GetLogs(c *gin.Context) {
 	allowedParams := map[string]bool{
@@ -61,6 +66,15 @@ func (h *LogHandler) GetLogs(c *gin.Context) {
 
 	query := h.db.Model(&models.GameLog{})
 
+	principal := middleware.CurrentPrincipal(c)
+	if !principal.Can(middleware.PermLogsRead) {
+		if playerID != "" && playerID != strconv.FormatUint(uint64(principal.PlayerID), 10) {
+			response.PermissionDenied(c, middleware.PermLogsRead)
+			return
+		}
+		playerID = strconv.FormatUint(uint64(principal.PlayerID), 10)
+	}
+
 	if playerID != "" {
 		id, err := strconv.ParseUint(playerID, 10, 64)
 		if err != nil {
@@ -111,15 +125,19 @@ func (h *LogHandler) GetLogs(c *gin.Context) {
 
 // CreateLog godoc
 // @Summary Create a new game log
-// @Description Creates a new game log entry
+// @Description Creates a new game log entry. Entering and leaving a room go through room presence like POST /rooms/{id}/enter and /leave, so the room's occupants stay in line with the logs.
 // @Tags logs
 // @Accept json
 // @Produce json
 // @Param log body models.GameLog true "Game log information"
 // @Success 200 {object} response.LogCreateResponse
 // @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
 // @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 422 {object} response.Response
 // @Failure 500 {object} response.Response
+// @Security BearerAuth
 // @Router /logs [post]
 func (h *LogHandler) CreateLog(c *gin.Context) {
 	var log models.GameLog
@@ -127,6 +145,19 @@ func (h *LogHandler) CreateLog(c *gin.Context) {
 		response.Error(c, http.StatusBadRequest, err.Error())
 		return
 	}
+
+	principal := middleware.CurrentPrincipal(c)
+	var acting bool
+	if log.PlayerID, acting = actingPlayer(c, log.PlayerID); !acting {
+		return
+	}
+	if !principal.CanActFor(log.PlayerID, middleware.PermLogsWrite) {
+		response.PermissionDenied(c, middleware.PermLogsWrite)
+		return
+	}
+	if rejectSanctioned(c, h.db, log.PlayerID) {
+		return
+	}
 	_, err := validator.FindPlayerByID(h.db, uint64(log.PlayerID))
 	if err != nil {
 		response.Error(c, http.StatusNotFound, "Player not found")
@@ -136,9 +167,32 @@ func (h *LogHandler) CreateLog(c *gin.Context) {
 	if c.IsAborted() {
 		return
 	}
+	if log.Action == models.ActionEnterRoom || log.Action == models.ActionLeaveRoom {
+		if log.RoomID == nil {
+			response.Error(c, http.StatusBadRequest, "room_id is required to enter or leave a room")
+			return
+		}
+
+		var session presence.Session
+		if log.Action == models.ActionEnterRoom {
+			session, err = h.tracker.Enter(log.PlayerID, *log.RoomID, log.Details, time.Now())
+		} else {
+			session, err = h.tracker.Leave(log.PlayerID, *log.RoomID, log.Details, time.Now())
+		}
+		if err != nil {
+			presenceError(c, err, "Fail to create game log")
+			return
+		}
+		response.Success(c, gin.H{"log_id": session.LogID})
+		return
+	}
 	log.Timestamp = time.Now()
 
 	if err := h.db.Create(&log).Error; err != nil {
+		if validator.IsForeignKeyViolation(err) {
+			response.Error(c, http.StatusUnprocessableEntity, "Game log references a player or room that does not exist")
+			return
+		}
 		response.Error(c, http.StatusInternalServerError, "Fail to create game log")
 		return
 	}
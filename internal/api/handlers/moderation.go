@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"oxo-game-api/internal/api/middleware"
+	"oxo-game-api/internal/models"
+	"oxo-game-api/internal/moderation"
+	"oxo-game-api/pkg/utils/response"
+	"oxo-game-api/pkg/utils/validator"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ModerationHandler struct {
+	db *gorm.DB
+}
+
+func NewModerationHandler(db *gorm.DB) *ModerationHandler {
+	return &ModerationHandler{db: db}
+}
+
+// GetSanctions godoc
+// @Summary Get a player's sanctions
+// @Description Lists every ban and suspension of a player, newest first
+// @Tags moderation
+// @Produce json
+// @Param id path int true "Player ID"
+// @Success 200 {array} models.Sanction
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /players/{id}/sanctions [get]
+func (h *ModerationHandler) GetSanctions(c *gin.Context) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var sanctions []models.Sanction
+	if err := h.db.Where("player_id = ?", id).Order("created_at desc, id desc").Find(&sanctions).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch sanctions")
+		return
+	}
+
+	response.Success(c, sanctions)
+}
+
+// IssueSanction godoc
+// @Summary Sanction a player
+// @Description Bans or suspends a player and ends their sessions. Suspensions need an expiry, bans without one are permanent.
+// @Tags moderation
+// @Accept json
+// @Produce json
+// @Param id path int true "Player ID"
+// @Param sanction body validator.SanctionValidation true "Sanction"
+// @Success 200 {object} models.Sanction
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /players/{id}/sanctions [post]
+func (h *ModerationHandler) IssueSanction(c *gin.Context) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var input validator.SanctionValidation
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		response.Error(c, http.StatusBadRequest, "expires_at must be in the future")
+		return
+	}
+
+	if _, err := validator.FindPlayerByID(h.db, id); err != nil {
+		response.Error(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	sanction := models.Sanction{
+		PlayerID:  uint(id),
+		Kind:      input.Kind,
+		Reason:    input.Reason,
+		IssuedBy:  middleware.CurrentPrincipal(c).PlayerID,
+		ExpiresAt: input.ExpiresAt,
+	}
+	if err := moderation.Issue(h.db, &sanction); err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to issue sanction")
+		return
+	}
+
+	response.Success(c, sanction)
+}
+
+// RevokeSanction godoc
+// @Summary Revoke a sanction
+// @Description Lifts a ban or suspension before it expires
+// @Tags moderation
+// @Produce json
+// @Param id path int true "Sanction ID"
+// @Success 200 {object} models.Sanction
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /sanctions/{id}/revoke [post]
+func (h *ModerationHandler) RevokeSanction(c *gin.Context) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	sanction, err := moderation.Revoke(h.db, uint(id), middleware.CurrentPrincipal(c).PlayerID)
+	if err != nil {
+		sanctionError(c, err, "Fail to revoke sanction")
+		return
+	}
+
+	response.Success(c, sanction)
+}
+
+// AppealSanction godoc
+// @Summary Appeal a sanction
+// @Description Files an appeal against a sanction. The sanctioned player files it with the appeal_session token from login, moderators can file it on their behalf.
+// @Tags moderation
+// @Accept json
+// @Produce json
+// @Param id path int true "Sanction ID"
+// @Param appeal body validator.AppealValidation true "Appeal"
+// @Success 200 {object} models.Sanction
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /sanctions/{id}/appeal [post]
+func (h *ModerationHandler) AppealSanction(c *gin.Context) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var input validator.AppealValidation
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var existing models.Sanction
+	if err := h.db.First(&existing, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = moderation.ErrSanctionNotFound
+		}
+		sanctionError(c, err, "Fail to fetch sanction")
+		return
+	}
+	if !middleware.CurrentPrincipal(c).CanActFor(existing.PlayerID, middleware.PermPlayersModerate) {
+		response.PermissionDenied(c, middleware.PermPlayersModerate)
+		return
+	}
+
+	sanction, err := moderation.Appeal(h.db, uint(id), input.Note)
+	if err != nil {
+		sanctionError(c, err, "Fail to appeal sanction")
+		return
+	}
+
+	response.Success(c, sanction)
+}
+
+// DecideAppeal godoc
+// @Summary Decide an appeal
+// @Description Accepts or rejects a pending appeal. Accepting it revokes the sanction.
+// @Tags moderation
+// @Accept json
+// @Produce json
+// @Param id path int true "Sanction ID"
+// @Param decision body validator.AppealDecisionValidation true "Decision"
+// @Success 200 {object} models.Sanction
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /sanctions/{id}/appeal/decision [post]
+func (h *ModerationHandler) DecideAppeal(c *gin.Context) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var input validator.AppealDecisionValidation
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	accepted := input.Status == models.AppealAccepted
+	sanction, err := moderation.DecideAppeal(h.db, uint(id), middleware.CurrentPrincipal(c).PlayerID, accepted)
+	if err != nil {
+		sanctionError(c, err, "Fail to decide appeal")
+		return
+	}
+
+	response.Success(c, sanction)
+}
+
+func sanctionError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, moderation.ErrSanctionNotFound):
+		response.Error(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, moderation.ErrAlreadyRevoked),
+		errors.Is(err, moderation.ErrAppealExists),
+		errors.Is(err, moderation.ErrNoPendingAppeal):
+		response.Error(c, http.StatusConflict, err.Error())
+	default:
+		response.Error(c, http.StatusInternalServerError, fallback)
+	}
+}
+
+// rejectSanctioned answers 403 when the player is banned or suspended and
+// reports whether the request was aborted
+func rejectSanctioned(c *gin.Context, db *gorm.DB, playerID uint) bool {
+	sanction, err := moderation.ActiveSanction(db, playerID, time.Now())
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to check sanctions")
+		c.Abort()
+		return true
+	}
+	if sanction == nil {
+		return false
+	}
+	response.Sanctioned(c, moderation.Message(sanction), sanction)
+	return true
+}
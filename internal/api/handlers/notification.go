@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"oxo-game-api/internal/models"
+	"oxo-game-api/pkg/utils/response"
+	"oxo-game-api/pkg/utils/validator"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type NotificationHandler struct {
+	db *gorm.DB
+}
+
+func NewNotificationHandler(db *gorm.DB) *NotificationHandler {
+	return &NotificationHandler{db: db}
+}
+
+// GetNotifications godoc
+// @Summary Get a player's inbox
+// @Description Lists the player's in-app notifications, newest first. Reservation reminders, challenge results and payment updates can be turned off in the profile's notification settings.
+// @Tags players
+// @Produce json
+// @Param id path int true "Player ID"
+// @Param unread query bool false "Set to true to list only unread notifications"
+// @Param limit query int false "Page size, 1 to 100, defaults to 20"
+// @Param offset query int false "Number of notifications to skip"
+// @Success 200 {object} response.Page{items=[]models.Notification}
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /players/{id}/notifications [get]
+func (h *NotificationHandler) GetNotifications(c *gin.Context) {
+	validator.CheckQueryParam(c, map[string]bool{"unread": true, "limit": true, "offset": true})
+	if c.IsAborted() {
+		return
+	}
+
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit, offset, err := validator.GetPagination(c, 20, 100)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query := h.db.Model(&models.Notification{}).Where("player_id = ?", id)
+	if unread := c.Query("unread"); unread != "" {
+		only, err := strconv.ParseBool(unread)
+		if err != nil {
+			response.Error(c, http.StatusBadRequest, "Invalid unread, use true or false")
+			return
+		}
+		if only {
+			query = query.Where("read_at IS NULL")
+		}
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch notifications")
+		log.Printf("Error counting notifications: %v", err)
+		return
+	}
+
+	notifications := []models.Notification{}
+	if err := query.Order("id desc").Limit(limit).Offset(offset).Find(&notifications).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch notifications")
+		log.Printf("Error fetching notifications: %v", err)
+		return
+	}
+
+	response.Success(c, response.Page{
+		Items:  notifications,
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	})
+}
+
+// MarkNotificationRead godoc
+// @Summary Mark a notification as read
+// @Description Marks one notification in the player's inbox as read, marking it again keeps the first read time
+// @Tags players
+// @Produce json
+// @Param id path int true "Player ID"
+// @Param notification_id path int true "Notification ID"
+// @Success 200 {object} models.Notification
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /players/{id}/notifications/{notification_id}/read [post]
+func (h *NotificationHandler) MarkNotificationRead(c *gin.Context) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	notificationID, err := strconv.ParseUint(c.Param("notification_id"), 10, 64)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "invalid notification ID")
+		return
+	}
+
+	var notification models.Notification
+	if err := h.db.Where("player_id = ?", id).First(&notification, notificationID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Error(c, http.StatusNotFound, "Notification not found")
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch notification")
+		return
+	}
+
+	if notification.ReadAt == nil {
+		now := time.Now()
+		if err := h.db.Model(&notification).Update("read_at", now).Error; err != nil {
+			response.Error(c, http.StatusInternalServerError, "Fail to mark notification as read")
+			return
+		}
+		notification.ReadAt = &now
+	}
+
+	response.Success(c, notification)
+}
+
+// MarkAllNotificationsRead godoc
+// @Summary Mark the whole inbox as read
+// @Description Marks every unread notification of the player as read
+// @Tags players
+// @Produce json
+// @Param id path int true "Player ID"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /players/{id}/notifications/read-all [post]
+func (h *NotificationHandler) MarkAllNotificationsRead(c *gin.Context) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result := h.db.Model(&models.Notification{}).
+		Where("player_id = ? AND read_at IS NULL", id).
+		Update("read_at", time.Now())
+	if result.Error != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to mark notifications as read")
+		return
+	}
+
+	response.Success(c, gin.H{
+		"message": fmt.Sprintf("%d notifications are marked as read", result.RowsAffected),
+		"count":   result.RowsAffected,
+	})
+}
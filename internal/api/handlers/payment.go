@@ -4,8 +4,12 @@ import (
 	"net/http"
 	"time"
 
+	"oxo-game-api/internal/api/middleware"
 	"oxo-game-api/internal/models"
+	"oxo-game-api/internal/notify"
+	"oxo-game-api/internal/outbox"
 	"oxo-game-api/pkg/utils/response"
+	"oxo-game-api/pkg/utils/validator"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -29,7 +33,11 @@ func NewPaymentHandler(db *gorm.DB) *PaymentHandler {
 // @Success 200 {object} response.Response
 // @Failure 400 {object} response.Response
 // @Failure 402 {object} response.PaymentError
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 422 {object} response.Response
 // @Failure 500 {object} response.Response
+// @Security BearerAuth
 // @Router /payments [post]
 func (h *PaymentHandler) ProcessPayment(c *gin.Context) {
 	var payment models.Payment
@@ -38,6 +46,24 @@ func (h *PaymentHandler) ProcessPayment(c *gin.Context) {
 		return
 	}
 
+	principal := middleware.CurrentPrincipal(c)
+	var acting bool
+	if payment.PlayerID, acting = actingPlayer(c, payment.PlayerID); !acting {
+		return
+	}
+	if !principal.CanActFor(payment.PlayerID, middleware.PermPaymentsWrite) {
+		response.PermissionDenied(c, middleware.PermPaymentsWrite)
+		return
+	}
+	if _, err := validator.FindPlayerByID(h.db, uint64(payment.PlayerID)); err != nil {
+		if err.Error() == "player not found" {
+			response.Error(c, http.StatusNotFound, "Player not found")
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch player by ID")
+		return
+	}
+
 	var transactionID string
 	var status string
 	var errorMessage string
@@ -68,7 +94,24 @@ func (h *PaymentHandler) ProcessPayment(c *gin.Context) {
 		return
 	}
 
-	if err := h.db.Create(&payment).Error; err != nil {
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&payment).Error; err != nil {
+			return err
+		}
+		return outbox.Publish(tx, notify.EventPaymentStatus, payment.PlayerID, map[string]interface{}{
+			"payment_id":     payment.ID,
+			"transaction_id": payment.TransactionID,
+			"amount":         payment.Amount,
+			"method":         payment.Method,
+			"status":         payment.Status,
+			"error_message":  payment.ErrorMessage,
+		})
+	})
+	if err != nil {
+		if validator.IsForeignKeyViolation(err) {
+			response.Error(c, http.StatusUnprocessableEntity, "Payment references a player that does not exist")
+			return
+		}
 		response.Error(c, http.StatusInternalServerError, "Failed to record payment")
 		return
 	}
@@ -91,8 +134,10 @@ func (h *PaymentHandler) ProcessPayment(c *gin.Context) {
 // @Produce json
 // @Param id path int true "Payment ID"
 // @Success 200 {object} models.Payment
+// @Failure 403 {object} response.Response
 // @Failure 404 {object} response.Response
 // @Failure 500 {object} response.Response
+// @Security BearerAuth
 // @Router /payments/{id} [get]
 func (h *PaymentHandler) GetPayment(c *gin.Context) {
 	id := c.Param("id")
@@ -103,6 +148,11 @@ func (h *PaymentHandler) GetPayment(c *gin.Context) {
 		return
 	}
 
+	if !middleware.CurrentPrincipal(c).CanActFor(payment.PlayerID, middleware.PermPaymentsRead) {
+		response.PermissionDenied(c, middleware.PermPaymentsRead)
+		return
+	}
+
 	response.Success(c, payment)
 }
 
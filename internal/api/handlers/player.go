@@ -1,12 +1,18 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"oxo-game-api/config"
+	"oxo-game-api/internal/api/middleware"
 	"oxo-game-api/internal/models"
+	"oxo-game-api/internal/progression"
+	"oxo-game-api/pkg/utils/etag"
 	"oxo-game-api/pkg/utils/response"
 	"oxo-game-api/pkg/utils/validator"
+	"strconv"
 
 	"time"
 
@@ -15,43 +21,152 @@ import (
 )
 
 type PlayerHandler struct {
-	db *gorm.DB
+	db        *gorm.DB
+	retention *config.RetentionConfig
 }
 
-func NewPlayerHandler(db *gorm.DB) *PlayerHandler {
+func NewPlayerHandler(db *gorm.DB, retention *config.RetentionConfig) *PlayerHandler {
 	return &PlayerHandler{
-		db: db,
+		db:        db,
+		retention: retention,
 	}
 }
 
+// playerSortFields whitelists the columns GET /players can sort on
+var playerSortFields = map[string]string{
+	"id":         "id",
+	"name":       "name",
+	"level_id":   "level_id",
+	"balance":    "balance",
+	"experience": "experience",
+	"created_at": "created_at",
+}
+
 // GetPlayers godoc
-// @Summary Get all players
-// @Description Fetches a list of all players
+// @Summary Get players
+// @Description Searches, filters, sorts and paginates players
 // @Tags players
 // @Produce json
-// @Success 200 {array} models.Player
+// @Param name_prefix query string false "Case-insensitive name prefix"
+// @Param name_contains query string false "Case-insensitive name substring"
+// @Param level_id query int false "Level ID"
+// @Param min_balance query number false "Minimum balance"
+// @Param max_balance query number false "Maximum balance"
+// @Param sort query string false "Sort field, prefix with - for descending: id, name, level_id, balance, experience, created_at"
+// @Param include query string false "Set to level to preload the player's level"
+// @Param limit query int false "Page size, 1 to 100, defaults to 20"
+// @Param offset query int false "Number of players to skip"
+// @Success 200 {object} response.Page{items=[]models.Player}
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
 // @Failure 500 {object} response.Response
+// @Security BearerAuth
 // @Router /players [get]
 func (h *PlayerHandler) GetPlayers(c *gin.Context) {
-	var players []models.Player
-	if err := h.db.Find(&players).Error; err != nil {
+	allowedParams := map[string]bool{
+		"name_prefix":   true,
+		"name_contains": true,
+		"level_id":      true,
+		"min_balance":   true,
+		"max_balance":   true,
+		"sort":          true,
+		"include":       true,
+		"limit":         true,
+		"offset":        true,
+	}
+
+	validator.CheckQueryParam(c, allowedParams)
+
+	if c.IsAborted() {
+		return
+	}
+
+	limit, offset, err := validator.GetPagination(c, 20, 100)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query := h.db.Model(&models.Player{})
+
+	if prefix := c.Query("name_prefix"); prefix != "" {
+		query = query.Where("name ILIKE ?", validator.EscapeLike(prefix)+"%")
+	}
+	if contains := c.Query("name_contains"); contains != "" {
+		query = query.Where("name ILIKE ?", "%"+validator.EscapeLike(contains)+"%")
+	}
+	if levelID := c.Query("level_id"); levelID != "" {
+		id, err := strconv.ParseUint(levelID, 10, 64)
+		if err != nil {
+			response.Error(c, http.StatusBadRequest, "Invalid level ID")
+			return
+		}
+		query = query.Where("level_id = ?", id)
+	}
+	if minBalance := c.Query("min_balance"); minBalance != "" {
+		value, err := strconv.ParseFloat(minBalance, 64)
+		if err != nil {
+			response.Error(c, http.StatusBadRequest, "Invalid min_balance")
+			return
+		}
+		query = query.Where("balance >= ?", value)
+	}
+	if maxBalance := c.Query("max_balance"); maxBalance != "" {
+		value, err := strconv.ParseFloat(maxBalance, 64)
+		if err != nil {
+			response.Error(c, http.StatusBadRequest, "Invalid max_balance")
+			return
+		}
+		query = query.Where("balance <= ?", value)
+	}
+
+	order, err := validator.GetSortOrder(c.DefaultQuery("sort", "id"), playerSortFields)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	switch include := c.Query("include"); include {
+	case "":
+	case "level":
+		query = query.Preload("Level")
+	default:
+		response.Error(c, http.StatusBadRequest, fmt.Sprintf("Invalid include: %s", include))
+		return
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch players")
+		log.Printf("Eror counting players: %v", err)
+		return
+	}
+
+	players := []models.Player{}
+	if err := query.Order(order).Order("id").Limit(limit).Offset(offset).Find(&players).Error; err != nil {
 		response.Error(c, http.StatusInternalServerError, "Fail to fetch players")
 		log.Printf("Eror fetching players: %v", err)
 		return
 	}
 
-	response.Success(c, players)
+	response.Success(c, response.Page{
+		Items:  players,
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	})
 }
 
 // CreatePlayer godoc
 // @Summary Create a new player
-// @Description Creates a new player with the specified details
+// @Description Creates a new player with the specified details. New players start on the level of 0 experience, a level_id in the body is ignored.
 // @Tags players
 // @Accept json
 // @Produce json
 // @Param player body models.Player true "Player information"
 // @Success 200 {object} response.PlayerCreateResponse
 // @Failure 400 {object} response.Response
+// @Failure 409 {object} response.Response
 // @Failure 500 {object} response.Response
 // @Router /players [post]
 func (h *PlayerHandler) CreatePlayer(c *gin.Context) {
@@ -64,13 +179,36 @@ func (h *PlayerHandler) CreatePlayer(c *gin.Context) {
 
 	player := &models.Player{
 		Name:      input.Name,
-		LevelID:   uint(input.LevelID),
 		Balance:   0,
+		Role:      models.RolePlayer,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
 
+	// the level always follows the experience, the caller cannot pick one
+	level, err := progression.LevelForExperience(h.db, 0)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to create player")
+		return
+	}
+	if level != nil {
+		player.LevelID = level.ID
+	}
+
+	if input.Password != "" {
+		hash, err := hashPassword(input.Password)
+		if err != nil {
+			response.Error(c, http.StatusInternalServerError, "Fail to create player")
+			return
+		}
+		player.PasswordHash = hash
+	}
+
 	if err := h.db.Create(player).Error; err != nil {
+		if validator.IsUniqueViolation(err) {
+			response.Error(c, http.StatusConflict, "Player name already exists")
+			return
+		}
 		response.Error(c, http.StatusInternalServerError, "Fail to create player")
 		return
 	}
@@ -87,8 +225,11 @@ func (h *PlayerHandler) CreatePlayer(c *gin.Context) {
 // @Produce json
 // @Param id path int true "Player ID"
 // @Success 200 {object} models.Player
+// @Header 200 {string} ETag "Version of the player, for If-Match"
+// @Failure 403 {object} response.Response
 // @Failure 404 {object} response.Response
 // @Failure 500 {object} response.Response
+// @Security BearerAuth
 // @Router /players/{id} [get]
 func (h *PlayerHandler) GetPlayerByID(c *gin.Context) {
 	id, err := validator.GetParamID(c)
@@ -107,22 +248,31 @@ func (h *PlayerHandler) GetPlayerByID(c *gin.Context) {
 		return
 	}
 
+	etag.Set(c, etag.Of(player.ID, player.UpdatedAt))
 	response.Success(c, player)
 }
 
 // UpdatePlayerByID godoc
 // @Summary Update a player by ID
-// @Description Updates the details of a player by their ID
+// @Description Updates the details of a player by their ID. PUT replaces them, PATCH applies a JSON merge patch (RFC 7396).
 // @Tags players
 // @Accept json
 // @Produce json
 // @Param id path int true "Player ID"
-// @Param player body models.Player true "Updated player information"
+// @Param If-Match header string false "ETag of the version being changed"
+// @Param player body validator.PlayerValidation true "Updated player information"
 // @Success 200 {object} models.Player
+// @Header 200 {string} ETag "New version of the player"
 // @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
 // @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 412 {object} response.Response
+// @Failure 415 {object} response.Response
 // @Failure 500 {object} response.Response
+// @Security BearerAuth
 // @Router /players/{id} [put]
+// @Router /players/{id} [patch]
 func (h *PlayerHandler) UpdatePlayerByID(c *gin.Context) {
 	id, err := validator.GetParamID(c)
 	if err != nil {
@@ -140,11 +290,20 @@ func (h *PlayerHandler) UpdatePlayerByID(c *gin.Context) {
 		return
 	}
 
-	var input validator.PlayerValidation
-	if err := c.ShouldBindJSON(&input); err != nil {
-		response.Error(c, http.StatusBadRequest, err.Error())
+	if !ifMatch(c, player.ID, player.UpdatedAt) {
 		return
 	}
+
+	input := validator.PlayerValidation{Name: player.Name, LevelID: int(player.LevelID)}
+	if err := bindUpdate(c, &input); err != nil {
+		bindError(c, err, "")
+		return
+	}
+	if uint(input.LevelID) != player.LevelID && !middleware.CurrentPrincipal(c).Can(middleware.PermPlayersWrite) {
+		response.PermissionDenied(c, middleware.PermPlayersWrite)
+		return
+	}
+
 	var level models.Level
 	if err := h.db.First(&level, input.LevelID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -154,14 +313,45 @@ func (h *PlayerHandler) UpdatePlayerByID(c *gin.Context) {
 		response.Error(c, http.StatusInternalServerError, "Failed to verify level")
 		return
 	}
+	// Only the columns the request controls are written, experience and the
+	// rest are owned by other code paths
 	player.Name = input.Name
-	player.LevelID = uint(input.LevelID)
+	changes := map[string]interface{}{"name": player.Name}
+	if input.Password != "" {
+		hash, err := hashPassword(input.Password)
+		if err != nil {
+			response.Error(c, http.StatusInternalServerError, "Fail to update player")
+			return
+		}
+		player.PasswordHash = hash
+		changes["password_hash"] = hash
+	}
 
-	if err := h.db.Save(player).Error; err != nil {
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockVersion(tx, &models.Player{}, player.ID, player.UpdatedAt); err != nil {
+			return err
+		}
+		if err := progression.SetLevel(tx, player, &level, "manual level change"); err != nil {
+			return err
+		}
+		player.UpdatedAt = time.Now()
+		changes["updated_at"] = player.UpdatedAt
+		return tx.Model(player).Updates(changes).Error
+	})
+	if errors.Is(err, errStale) {
+		response.Error(c, http.StatusPreconditionFailed, staleMessage)
+		return
+	}
+	if validator.IsUniqueViolation(err) {
+		response.Error(c, http.StatusConflict, "Player name already exists")
+		return
+	}
+	if err != nil {
 		response.Error(c, http.StatusInternalServerError, "Fail to update player")
 		return
 	}
 
+	etag.Set(c, etag.Of(player.ID, player.UpdatedAt))
 	response.Success(c, player)
 }
 
@@ -171,9 +361,13 @@ func (h *PlayerHandler) UpdatePlayerByID(c *gin.Context) {
 // @Tags players
 // @Produce json
 // @Param id path int true "Player ID"
+// @Param If-Match header string false "ETag of the version being deleted"
 // @Success 200 {object} response.Response
+// @Failure 403 {object} response.Response
 // @Failure 404 {object} response.Response
+// @Failure 412 {object} response.Response
 // @Failure 500 {object} response.Response
+// @Security BearerAuth
 // @Router /players/{id} [delete]
 func (h *PlayerHandler) DeletePlayerByID(c *gin.Context) {
 	id, err := validator.GetParamID(c)
@@ -191,6 +385,9 @@ func (h *PlayerHandler) DeletePlayerByID(c *gin.Context) {
 		response.Error(c, http.StatusInternalServerError, "Fail to fetch player by ID")
 		return
 	}
+	if !ifMatch(c, player.ID, player.UpdatedAt) {
+		return
+	}
 
 	if err := h.db.Delete(&player).Error; err != nil {
 		response.Error(c, http.StatusInternalServerError, "Fail to delete player")
@@ -199,3 +396,322 @@ func (h *PlayerHandler) DeletePlayerByID(c *gin.Context) {
 
 	response.Success(c, gin.H{"message": fmt.Sprintf("Player %d is deleted successfully", id)})
 }
+
+// UpdatePlayerRole godoc
+// @Summary Change a player's role
+// @Description Grants the player, operator or admin role to a player
+// @Tags players
+// @Accept json
+// @Produce json
+// @Param id path int true "Player ID"
+// @Param role body validator.RoleValidation true "New role"
+// @Success 200 {object} models.Player
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /players/{id}/role [put]
+func (h *PlayerHandler) UpdatePlayerRole(c *gin.Context) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var input validator.RoleValidation
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	player, err := validator.FindPlayerByID(h.db, id)
+	if err != nil {
+		if err.Error() == "player not found" {
+			response.Error(c, http.StatusNotFound, "Player not found")
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch player by ID")
+		return
+	}
+
+	if err := h.db.Model(player).Update("role", input.Role).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to update player role")
+		return
+	}
+
+	response.Success(c, player)
+}
+
+// AwardExperience godoc
+// @Summary Award experience to a player
+// @Description Adds (or with a negative amount removes) experience, promoting or demoting the player when a level boundary is crossed
+// @Tags players
+// @Accept json
+// @Produce json
+// @Param id path int true "Player ID"
+// @Param experience body validator.ExperienceValidation true "Experience award"
+// @Success 200 {object} progression.Result
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /players/{id}/experience [post]
+func (h *PlayerHandler) AwardExperience(c *gin.Context) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var input validator.ExperienceValidation
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := progression.AwardExperience(h.db, uint(id), input.Amount, input.Reason)
+	if err != nil {
+		if err == progression.ErrPlayerNotFound {
+			response.Error(c, http.StatusNotFound, "Player not found")
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "Fail to award experience")
+		return
+	}
+
+	response.Success(c, result)
+}
+
+// GetLevelHistory godoc
+// @Summary Get a player's level history
+// @Description Lists every promotion and demotion of a player, newest first
+// @Tags players
+// @Produce json
+// @Param id path int true "Player ID"
+// @Success 200 {array} models.LevelChange
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /players/{id}/level-history [get]
+func (h *PlayerHandler) GetLevelHistory(c *gin.Context) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var changes []models.LevelChange
+	if err := h.db.Where("player_id = ?", id).Order("created_at desc, id desc").Find(&changes).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch level history")
+		return
+	}
+
+	response.Success(c, changes)
+}
+
+// GetDeletedPlayers godoc
+// @Summary List deleted players
+// @Description Lists soft-deleted players that can still be restored or purged
+// @Tags players
+// @Produce json
+// @Param limit query int false "Page size, 1 to 100, defaults to 20"
+// @Param offset query int false "Number of players to skip"
+// @Success 200 {object} response.Page{items=[]models.Player}
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /players/deleted [get]
+func (h *PlayerHandler) GetDeletedPlayers(c *gin.Context) {
+	allowedParams := map[string]bool{
+		"limit":  true,
+		"offset": true,
+	}
+
+	validator.CheckQueryParam(c, allowedParams)
+
+	if c.IsAborted() {
+		return
+	}
+
+	limit, offset, err := validator.GetPagination(c, 20, 100)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query := h.db.Unscoped().Model(&models.Player{}).Where("deleted_at IS NOT NULL")
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch deleted players")
+		return
+	}
+
+	players := []models.Player{}
+	if err := query.Order("deleted_at desc").Limit(limit).Offset(offset).Find(&players).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch deleted players")
+		return
+	}
+
+	response.Success(c, response.Page{
+		Items:  players,
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	})
+}
+
+// RestorePlayer godoc
+// @Summary Restore a deleted player
+// @Description Restores a soft-deleted player, a new name can be given when the old one has been taken in the meantime
+// @Tags players
+// @Accept json
+// @Produce json
+// @Param id path int true "Player ID"
+// @Param player body validator.RestoreValidation false "Optional new name"
+// @Success 200 {object} models.Player
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /players/{id}/restore [post]
+func (h *PlayerHandler) RestorePlayer(c *gin.Context) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var input validator.RestoreValidation
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			response.Error(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	var player models.Player
+	if err := h.db.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", id).First(&player).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			response.Error(c, http.StatusNotFound, "Deleted player not found")
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch player by ID")
+		return
+	}
+
+	if input.Name != "" {
+		player.Name = input.Name
+	}
+
+	if err := h.db.Unscoped().Model(&player).Updates(map[string]interface{}{
+		"name":       player.Name,
+		"deleted_at": nil,
+	}).Error; err != nil {
+		if validator.IsUniqueViolation(err) {
+			response.Error(c, http.StatusConflict, "Player name is taken, restore with a new name")
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "Fail to restore player")
+		return
+	}
+	player.DeletedAt = gorm.DeletedAt{}
+
+	response.Success(c, player)
+}
+
+// PurgePlayer godoc
+// @Summary Permanently erase a deleted player
+// @Description Hard deletes a soft-deleted player with their tokens, level history, leaderboard scores, logs and reservations. Players with challenge or payment history cannot be purged.
+// @Tags players
+// @Produce json
+// @Param id path int true "Player ID"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /players/{id}/purge [delete]
+func (h *PlayerHandler) PurgePlayer(c *gin.Context) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var player models.Player
+	if err := h.db.Unscoped().First(&player, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			response.Error(c, http.StatusNotFound, "Player not found")
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch player by ID")
+		return
+	}
+	if !player.DeletedAt.Valid {
+		response.Error(c, http.StatusConflict, "Only deleted players can be purged")
+		return
+	}
+
+	for _, model := range []interface{}{&models.Challenge{}, &models.Payment{}} {
+		var count int64
+		if err := h.db.Model(model).Where("player_id = ?", id).Count(&count).Error; err != nil {
+			response.Error(c, http.StatusInternalServerError, "Fail to purge player")
+			return
+		}
+		if count > 0 {
+			response.Error(c, http.StatusConflict, "Player has challenge or payment history and cannot be purged")
+			return
+		}
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{&models.ReservationParticipant{}, &models.ReservationTransition{}} {
+			if err := tx.Where("reservation_id IN (?)", tx.Model(&models.Reservation{}).Select("id").Where("player_id = ?", id)).
+				Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("requester_id = ? OR addressee_id = ?", id, id).Delete(&models.Friendship{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("created_by = ? OR (owner_type = ? AND owner_id = ?)", id, models.FeedPlayer, id).
+			Delete(&models.CalendarFeed{}).Error; err != nil {
+			return err
+		}
+		for _, model := range []interface{}{
+			&models.AuthToken{},
+			&models.LevelChange{},
+			&models.LeaderboardEntry{},
+			&models.GameLog{},
+			&models.Reservation{},
+			&models.ReservationSeries{},
+			&models.Sanction{},
+			&models.PrivacySettings{},
+			&models.PlayerProfile{},
+			&models.ReservationParticipant{},
+			&models.WaitlistEntry{},
+			&models.OutboxEvent{},
+			&models.Notification{},
+		} {
+			if err := tx.Where("player_id = ?", id).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Unscoped().Delete(&player).Error
+	})
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to purge player")
+		return
+	}
+
+	response.Success(c, gin.H{"message": fmt.Sprintf("Player %d is purged successfully", id)})
+}
@@ -0,0 +1,375 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"oxo-game-api/config"
+	"oxo-game-api/internal/models"
+	"oxo-game-api/pkg/utils/bulk"
+	"oxo-game-api/pkg/utils/response"
+	"oxo-game-api/pkg/utils/validator"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+)
+
+const (
+	bulkModeAllOrNothing = "all_or_nothing"
+	bulkModeBestEffort   = "best_effort"
+
+	maxBulkRows  = 10000
+	maxBulkBytes = 16 << 20
+)
+
+const (
+	rowCreated = "created"
+	rowFailed  = "failed"
+	rowSkipped = "skipped"
+)
+
+// playerExportColumns are the CSV columns of GET /players:export
+var playerExportColumns = []string{"id", "name", "level_id", "balance", "experience", "role", "created_at", "updated_at"}
+
+type bulkRow struct {
+	input  validator.PlayerValidation
+	result response.BulkRowResult
+	player models.Player
+}
+
+// BulkImportPlayers godoc
+// @Summary Import players in bulk
+// @Description Creates players from a CSV file with a name, level_id and password header, or from NDJSON objects with the same fields. Every row is validated and reported. In all_or_nothing mode a single invalid row cancels the import, in best_effort mode valid rows are created anyway.
+// @Tags players
+// @Accept text/csv
+// @Accept application/x-ndjson
+// @Produce json
+// @Param mode query string false "Import mode, defaults to all_or_nothing" Enums(all_or_nothing, best_effort)
+// @Success 200 {object} response.BulkImportResponse
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 413 {object} response.Response
+// @Failure 415 {object} response.Response
+// @Failure 422 {object} response.BulkImportResponse
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /players:bulk [post]
+func (h *PlayerHandler) BulkImportPlayers(c *gin.Context) {
+	allowedParams := map[string]bool{
+		"mode": true,
+	}
+
+	validator.CheckQueryParam(c, allowedParams)
+
+	if c.IsAborted() {
+		return
+	}
+
+	mode := c.DefaultQuery("mode", bulkModeAllOrNothing)
+	if mode != bulkModeAllOrNothing && mode != bulkModeBestEffort {
+		response.Error(c, http.StatusBadRequest, fmt.Sprintf("Invalid mode: %s", mode))
+		return
+	}
+
+	format, err := bulk.FormatOf(c.ContentType())
+	if err != nil {
+		response.Error(c, http.StatusUnsupportedMediaType, err.Error())
+		return
+	}
+
+	var rows []*bulkRow
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, maxBulkBytes)
+	err = bulk.Decode(body, format, func(line int, decode func(v interface{}) error) error {
+		if len(rows) == maxBulkRows {
+			return fmt.Errorf("an import is limited to %d rows", maxBulkRows)
+		}
+		row := &bulkRow{result: response.BulkRowResult{Line: line}}
+		if err := decode(&row.input); err != nil {
+			row.fail(err.Error())
+		} else if err := binding.Validator.ValidateStruct(&row.input); err != nil {
+			row.fail(err.Error())
+		}
+		row.result.Name = row.input.Name
+		rows = append(rows, row)
+		return nil
+	})
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			response.Error(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("An import is limited to %d bytes", maxBulkBytes))
+			return
+		}
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	levels, err := checkBulkPlayers(h.db, h.retention, rows)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to validate players")
+		return
+	}
+
+	result := response.BulkImportResponse{Mode: mode, Total: len(rows), Rows: make([]response.BulkRowResult, 0, len(rows))}
+	valid := make([]*bulkRow, 0, len(rows))
+	for _, row := range rows {
+		if row.result.Status == rowFailed {
+			result.Failed++
+			continue
+		}
+		valid = append(valid, row)
+	}
+
+	if mode == bulkModeAllOrNothing && result.Failed > 0 {
+		for _, row := range valid {
+			row.result.Status = rowSkipped
+		}
+		result.Rows = collectRows(rows)
+		response.ErrorWithData(c, http.StatusUnprocessableEntity, "Import cancelled, no player was created", result)
+		return
+	}
+
+	if err := preparePlayers(valid, levels); err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to create players")
+		return
+	}
+
+	if mode == bulkModeAllOrNothing {
+		err = h.db.Transaction(func(tx *gorm.DB) error {
+			for _, row := range valid {
+				if err := tx.Create(&row.player).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if validator.IsUniqueViolation(err) {
+			response.Error(c, http.StatusConflict, "A player name was taken during the import, no player was created")
+			return
+		}
+		if err != nil {
+			log.Printf("Error importing players: %v", err)
+			response.Error(c, http.StatusInternalServerError, "Fail to create players, no player was created")
+			return
+		}
+		for _, row := range valid {
+			row.created()
+		}
+		result.Created = len(valid)
+	} else {
+		for _, row := range valid {
+			if err := h.db.Create(&row.player).Error; err != nil {
+				if validator.IsUniqueViolation(err) {
+					row.fail("Player name already exists")
+					result.Failed++
+					continue
+				}
+				log.Printf("Error importing player %q: %v", row.input.Name, err)
+				row.fail("Fail to create player")
+				result.Failed++
+				continue
+			}
+			row.created()
+			result.Created++
+		}
+	}
+
+	result.Rows = collectRows(rows)
+	response.Success(c, result)
+}
+
+// ExportPlayers godoc
+// @Summary Export all players
+// @Description Streams every player as CSV or NDJSON
+// @Tags players
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Param format query string false "Output format, defaults to ndjson" Enums(csv, ndjson)
+// @Success 200 {string} string "Players, one per line"
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Security BearerAuth
+// @Router /players:export [get]
+func (h *PlayerHandler) ExportPlayers(c *gin.Context) {
+	allowedParams := map[string]bool{
+		"format": true,
+	}
+
+	validator.CheckQueryParam(c, allowedParams)
+
+	if c.IsAborted() {
+		return
+	}
+
+	format := c.DefaultQuery("format", bulk.FormatNDJSON)
+	if format != bulk.FormatCSV && format != bulk.FormatNDJSON {
+		response.Error(c, http.StatusBadRequest, fmt.Sprintf("Invalid format: %s", format))
+		return
+	}
+
+	c.Header("Content-Type", bulk.ContentType(format))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=players.%s", format))
+	c.Status(http.StatusOK)
+
+	encoder := bulk.NewEncoder(c.Writer, format, playerExportColumns)
+	var batch []models.Player
+	err := h.db.Order("id").FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+		for i := range batch {
+			if err := encoder.Encode(&batch[i]); err != nil {
+				return err
+			}
+		}
+		if err := encoder.Flush(); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+		return nil
+	}).Error
+	if err == nil {
+		err = encoder.Flush()
+	}
+	if err != nil {
+		// The status line is already sent, cutting the stream short is all
+		// that is left to signal the failure
+		log.Printf("Error exporting players: %v", err)
+		c.Abort()
+	}
+}
+
+// checkBulkPlayers fails the rows whose name is repeated in the import or
+// already taken, or whose level does not exist. It returns the level ladder
+// sorted by experience.
+func checkBulkPlayers(db *gorm.DB, cfg *config.RetentionConfig, rows []*bulkRow) ([]models.Level, error) {
+	names := make([]string, 0, len(rows))
+	seen := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		if row.result.Status == rowFailed {
+			continue
+		}
+		if seen[row.input.Name] {
+			row.fail("duplicate name in import")
+			continue
+		}
+		seen[row.input.Name] = true
+		names = append(names, row.input.Name)
+	}
+
+	query := db
+	if cfg.DeletedNamePolicy == config.NamePolicyReserve {
+		query = query.Unscoped()
+	}
+	taken := make(map[string]bool)
+	for start := 0; start < len(names); start += 1000 {
+		end := start + 1000
+		if end > len(names) {
+			end = len(names)
+		}
+		var existing []string
+		if err := query.Model(&models.Player{}).Where("name IN ?", names[start:end]).Pluck("name", &existing).Error; err != nil {
+			return nil, err
+		}
+		for _, name := range existing {
+			taken[name] = true
+		}
+	}
+
+	var ladder []models.Level
+	if err := db.Order("min_exp").Find(&ladder).Error; err != nil {
+		return nil, err
+	}
+	levels := make(map[uint]bool, len(ladder))
+	for _, level := range ladder {
+		levels[level.ID] = true
+	}
+
+	for _, row := range rows {
+		if row.result.Status == rowFailed {
+			continue
+		}
+		if taken[row.input.Name] {
+			row.fail("Player name already exists")
+		} else if row.input.LevelID != 0 && !levels[uint(row.input.LevelID)] {
+			row.fail("Invalid level ID")
+		}
+	}
+	return ladder, nil
+}
+
+// preparePlayers builds the players of valid rows, hashing passwords on all
+// CPUs since bcrypt dominates the cost of large imports. Players start with
+// the experience at the bottom of their level, or on the lowest level of the
+// ladder when the row names none, so their level matches their experience.
+func preparePlayers(rows []*bulkRow, ladder []models.Level) error {
+	now := time.Now()
+	for _, row := range rows {
+		row.player = models.Player{
+			Name:      row.input.Name,
+			Role:      models.RolePlayer,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if len(ladder) == 0 {
+			continue
+		}
+		level := ladder[0]
+		for _, candidate := range ladder {
+			if candidate.ID == uint(row.input.LevelID) {
+				level = candidate
+			}
+		}
+		row.player.LevelID = level.ID
+		row.player.Experience = level.MinExp
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	sem := make(chan struct{}, runtime.NumCPU())
+	for _, row := range rows {
+		if row.input.Password == "" {
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(row *bulkRow) {
+			defer func() { <-sem; wg.Done() }()
+			hash, err := hashPassword(row.input.Password)
+			if err != nil {
+				mu.Lock()
+				firstErr = err
+				mu.Unlock()
+				return
+			}
+			row.player.PasswordHash = hash
+		}(row)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+func (r *bulkRow) fail(message string) {
+	r.result.Status = rowFailed
+	r.result.Error = strings.TrimSpace(message)
+}
+
+func (r *bulkRow) created() {
+	r.result.Status = rowCreated
+	r.result.PlayerID = r.player.ID
+}
+
+func collectRows(rows []*bulkRow) []response.BulkRowResult {
+	results := make([]response.BulkRowResult, len(rows))
+	for i, row := range rows {
+		results[i] = row.result
+	}
+	return results
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"oxo-game-api/internal/api/middleware"
+	"oxo-game-api/internal/models"
+	"oxo-game-api/internal/presence"
+	"oxo-game-api/internal/social"
+	"oxo-game-api/pkg/utils/response"
+	"oxo-game-api/pkg/utils/validator"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type PresenceHandler struct {
+	db      *gorm.DB
+	tracker *presence.Tracker
+}
+
+func NewPresenceHandler(db *gorm.DB, tracker *presence.Tracker) *PresenceHandler {
+	return &PresenceHandler{db: db, tracker: tracker}
+}
+
+// Occupant is a player inside a room
+type Occupant struct {
+	presence.Session
+	Name string `json:"name"`
+}
+
+// Occupancy is who is inside a room right now. Count includes the players
+// whose presence is hidden from the viewer.
+type Occupancy struct {
+	RoomID    uint       `json:"room_id"`
+	Status    string     `json:"status"`
+	Capacity  int        `json:"capacity"`
+	Count     int        `json:"count"`
+	Occupants []Occupant `json:"occupants"`
+}
+
+// EnterRoom godoc
+// @Summary Enter a room
+// @Description Puts the player in the room and logs it. A player is in one room at a time, entering another room leaves the current one. Entering the room the player is already in refreshes the session like a heartbeat. Sessions without a heartbeat end after a timeout, 5 minutes by default. Operators and API keys can pass player_id to act for another player.
+// @Tags rooms
+// @Accept json
+// @Produce json
+// @Param id path int true "Room ID"
+// @Param presence body validator.PresenceValidation false "Player entering the room"
+// @Success 200 {object} presence.Session
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 422 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /rooms/{id}/enter [post]
+func (h *PresenceHandler) EnterRoom(c *gin.Context) {
+	roomID, playerID, ok := h.bind(c)
+	if !ok {
+		return
+	}
+	if rejectSanctioned(c, h.db, playerID) {
+		return
+	}
+
+	session, err := h.tracker.Enter(playerID, roomID, "", time.Now())
+	if err != nil {
+		presenceError(c, err, "Fail to enter the room")
+		return
+	}
+
+	response.Success(c, session)
+}
+
+// RoomHeartbeat godoc
+// @Summary Keep a room session alive
+// @Description Tells the server the player is still in the room, so the session does not time out
+// @Tags rooms
+// @Accept json
+// @Produce json
+// @Param id path int true "Room ID"
+// @Param presence body validator.PresenceValidation false "Player in the room"
+// @Success 200 {object} presence.Session
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /rooms/{id}/heartbeat [post]
+func (h *PresenceHandler) RoomHeartbeat(c *gin.Context) {
+	roomID, playerID, ok := h.bind(c)
+	if !ok {
+		return
+	}
+
+	session, err := h.tracker.Heartbeat(playerID, roomID, time.Now())
+	if err != nil {
+		presenceError(c, err, "Fail to refresh the room session")
+		return
+	}
+
+	response.Success(c, session)
+}
+
+// LeaveRoom godoc
+// @Summary Leave a room
+// @Description Takes the player out of the room and logs it. The room is available again once the last player leaves.
+// @Tags rooms
+// @Accept json
+// @Produce json
+// @Param id path int true "Room ID"
+// @Param presence body validator.PresenceValidation false "Player leaving the room"
+// @Success 200 {object} presence.Session
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /rooms/{id}/leave [post]
+func (h *PresenceHandler) LeaveRoom(c *gin.Context) {
+	roomID, playerID, ok := h.bind(c)
+	if !ok {
+		return
+	}
+
+	session, err := h.tracker.Leave(playerID, roomID, "", time.Now())
+	if err != nil {
+		presenceError(c, err, "Fail to leave the room")
+		return
+	}
+
+	response.Success(c, session)
+}
+
+// GetRoomOccupants godoc
+// @Summary Get the players in a room
+// @Description Lists the players inside the room right now, first entered first. Players who hide their presence from the caller are counted but not listed.
+// @Tags rooms
+// @Produce json
+// @Param id path int true "Room ID"
+// @Success 200 {object} Occupancy
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /rooms/{id}/occupants [get]
+func (h *PresenceHandler) GetRoomOccupants(c *gin.Context) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	room, err := validator.FindRoomByID(h.db, id)
+	if err != nil {
+		if err.Error() == "room not found" {
+			response.Error(c, http.StatusNotFound, "Room not found")
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch room by ID")
+		return
+	}
+
+	sessions := h.tracker.Occupants(room.ID)
+	ids := make([]uint, 0, len(sessions))
+	for _, session := range sessions {
+		ids = append(ids, session.PlayerID)
+	}
+	var players []models.Player
+	if err := h.db.Where("id IN ?", ids).Find(&players).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch occupants")
+		return
+	}
+	names := make(map[uint]string, len(players))
+	for _, player := range players {
+		names[player.ID] = player.Name
+	}
+
+	principal := middleware.CurrentPrincipal(c)
+	occupancy := Occupancy{
+		RoomID:    room.ID,
+		Status:    room.Status,
+		Capacity:  room.Capacity,
+		Count:     len(sessions),
+		Occupants: make([]Occupant, 0, len(sessions)),
+	}
+	for _, session := range sessions {
+		name, ok := names[session.PlayerID]
+		if !ok {
+			continue
+		}
+		if !principal.Can(middleware.PermPlayersRead) {
+			settings, err := social.Settings(h.db, session.PlayerID)
+			if err != nil {
+				response.Error(c, http.StatusInternalServerError, "Fail to fetch privacy settings")
+				return
+			}
+			visible, err := social.CanSee(h.db, settings.PresenceVisibility, principal.PlayerID, session.PlayerID)
+			if err != nil {
+				response.Error(c, http.StatusInternalServerError, "Fail to check friendship")
+				return
+			}
+			if !visible {
+				continue
+			}
+		}
+		occupancy.Occupants = append(occupancy.Occupants, Occupant{Session: session, Name: name})
+	}
+
+	response.Success(c, occupancy)
+}
+
+// bind reads the room from the path and the player from the optional body,
+// the caller by default
+func (h *PresenceHandler) bind(c *gin.Context) (uint, uint, bool) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return 0, 0, false
+	}
+
+	var input validator.PresenceValidation
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			response.Error(c, http.StatusBadRequest, err.Error())
+			return 0, 0, false
+		}
+	}
+
+	principal := middleware.CurrentPrincipal(c)
+	var acting bool
+	if input.PlayerID, acting = actingPlayer(c, input.PlayerID); !acting {
+		return 0, 0, false
+	}
+	if !principal.CanActFor(input.PlayerID, middleware.PermLogsWrite) {
+		response.PermissionDenied(c, middleware.PermLogsWrite)
+		return 0, 0, false
+	}
+	return uint(id), input.PlayerID, true
+}
+
+func presenceError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, presence.ErrRoomNotFound):
+		response.Error(c, http.StatusNotFound, "Room not found")
+	case errors.Is(err, presence.ErrRoomFull),
+		errors.Is(err, presence.ErrNotInRoom):
+		response.Error(c, http.StatusConflict, err.Error())
+	case errors.Is(err, presence.ErrRoomClosed):
+		response.Error(c, http.StatusUnprocessableEntity, err.Error())
+	case validator.IsForeignKeyViolation(err):
+		response.Error(c, http.StatusUnprocessableEntity, "Room presence references a player that does not exist")
+	default:
+		response.Error(c, http.StatusInternalServerError, fallback)
+	}
+}
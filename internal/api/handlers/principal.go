@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"net/http"
+
+	"oxo-game-api/internal/api/middleware"
+	"oxo-game-api/pkg/utils/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// actingPlayer returns the player a request acts for, requested when given
+// and the caller otherwise. Callers that are no player, such as API keys,
+// have to name one. It answers 400 itself when there is none.
+func actingPlayer(c *gin.Context, requested uint) (uint, bool) {
+	if requested == 0 {
+		requested = middleware.CurrentPrincipal(c).PlayerID
+	}
+	if requested == 0 {
+		response.Error(c, http.StatusBadRequest, "player_id is required when the caller is not a player")
+		return 0, false
+	}
+	return requested, true
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"oxo-game-api/internal/api/middleware"
+	"oxo-game-api/internal/models"
+	"oxo-game-api/internal/privacy"
+	"oxo-game-api/pkg/utils/response"
+	"oxo-game-api/pkg/utils/validator"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type PrivacyHandler struct {
+	db *gorm.DB
+}
+
+func NewPrivacyHandler(db *gorm.DB) *PrivacyHandler {
+	return &PrivacyHandler{db: db}
+}
+
+// ExportPlayerData godoc
+// @Summary Export a player's data
+// @Description Returns every record stored about a player as a single JSON document
+// @Tags privacy
+// @Produce json
+// @Param id path int true "Player ID"
+// @Success 200 {object} privacy.Bundle
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /players/{id}/export [get]
+func (h *PrivacyHandler) ExportPlayerData(c *gin.Context) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	bundle, err := privacy.Export(h.db, uint(id))
+	if errors.Is(err, privacy.ErrPlayerNotFound) {
+		response.Error(c, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to export player data")
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=player-%d-export.json", id))
+	response.Success(c, bundle)
+}
+
+// RequestErasure godoc
+// @Summary Request erasure of a player's data
+// @Description Starts a background job anonymizing the player. Financial records are kept.
+// @Tags privacy
+// @Produce json
+// @Param id path int true "Player ID"
+// @Success 200 {object} models.ErasureJob
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /players/{id}/erasure [post]
+func (h *PrivacyHandler) RequestErasure(c *gin.Context) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var player models.Player
+	if err := h.db.Unscoped().First(&player, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Error(c, http.StatusNotFound, "player not found")
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch player")
+		return
+	}
+
+	var inProgress int64
+	if err := h.db.Model(&models.ErasureJob{}).
+		Where("player_id = ? AND status IN ?", id, []string{models.ErasurePending, models.ErasureRunning}).
+		Count(&inProgress).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to check erasure jobs")
+		return
+	}
+	if inProgress > 0 {
+		response.Error(c, http.StatusConflict, "An erasure of this player is already in progress")
+		return
+	}
+
+	job := models.ErasureJob{
+		PlayerID:    uint(id),
+		RequestedBy: middleware.CurrentPrincipal(c).PlayerID,
+		Status:      models.ErasurePending,
+	}
+	if err := h.db.Create(&job).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to create erasure job")
+		return
+	}
+
+	go privacy.RunErasureJob(h.db, job.ID)
+
+	response.Success(c, job)
+}
+
+// GetErasureJob godoc
+// @Summary Get an erasure job
+// @Description Returns the status of an erasure job
+// @Tags privacy
+// @Produce json
+// @Param id path int true "Erasure job ID"
+// @Success 200 {object} models.ErasureJob
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /erasure-jobs/{id} [get]
+func (h *PrivacyHandler) GetErasureJob(c *gin.Context) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var job models.ErasureJob
+	if err := h.db.First(&job, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Error(c, http.StatusNotFound, "erasure job not found")
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch erasure job")
+		return
+	}
+
+	principal := middleware.CurrentPrincipal(c)
+	if !principal.CanActFor(job.PlayerID, middleware.PermPrivacyManage) {
+		response.PermissionDenied(c, middleware.PermPrivacyManage)
+		return
+	}
+
+	response.Success(c, job)
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"oxo-game-api/internal/api/middleware"
+	"oxo-game-api/internal/models"
+	"oxo-game-api/pkg/utils/response"
+	"oxo-game-api/pkg/utils/validator"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ProfileHandler struct {
+	db *gorm.DB
+}
+
+func NewProfileHandler(db *gorm.DB) *ProfileHandler {
+	return &ProfileHandler{db: db}
+}
+
+// GetProfile godoc
+// @Summary Get a player's profile
+// @Description Returns the full profile to the player and to holders of players:read, the public part to everyone else
+// @Tags players
+// @Produce json
+// @Param id path int true "Player ID"
+// @Success 200 {object} models.PlayerProfile
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /players/{id}/profile [get]
+func (h *ProfileHandler) GetProfile(c *gin.Context) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	player, err := validator.FindPlayerByID(h.db, id)
+	if err != nil {
+		response.Error(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	profile, err := loadProfile(h.db, player)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch profile")
+		return
+	}
+	if profile.DisplayName == "" {
+		profile.DisplayName = player.Name
+	}
+
+	if !middleware.CurrentPrincipal(c).CanActFor(player.ID, middleware.PermPlayersRead) {
+		response.Success(c, profile.Public())
+		return
+	}
+
+	response.Success(c, profile)
+}
+
+// UpdateProfile godoc
+// @Summary Update a player's profile
+// @Description Partially updates a profile, omitted fields are left unchanged. An empty display name falls back to the player's name, an empty locale or timezone resets it. Notifications are emailed to email when set.
+// @Tags players
+// @Accept json
+// @Produce json
+// @Param id path int true "Player ID"
+// @Param profile body validator.ProfileValidation true "Profile fields to change"
+// @Success 200 {object} models.PlayerProfile
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /players/{id}/profile [patch]
+func (h *ProfileHandler) UpdateProfile(c *gin.Context) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var input validator.ProfileValidation
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	player, err := validator.FindPlayerByID(h.db, id)
+	if err != nil {
+		response.Error(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	profile, err := loadProfile(h.db, player)
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch profile")
+		return
+	}
+
+	if input.DisplayName != nil {
+		profile.DisplayName = *input.DisplayName
+	}
+	if input.AvatarURL != nil {
+		profile.AvatarURL = *input.AvatarURL
+	}
+	if input.Locale != nil {
+		profile.Locale = *input.Locale
+		if profile.Locale == "" {
+			profile.Locale = models.DefaultLocale
+		}
+	}
+	if input.Timezone != nil {
+		profile.Timezone = *input.Timezone
+		if profile.Timezone == "" {
+			profile.Timezone = models.DefaultTimezone
+		}
+	}
+	if input.Email != nil {
+		profile.Email = *input.Email
+	}
+	if prefs := input.Notifications; prefs != nil {
+		notifications := &profile.Notifications
+		for _, field := range []struct {
+			value  *bool
+			target *bool
+		}{
+			{prefs.ReservationReminders, &notifications.ReservationReminders},
+			{prefs.ChallengeResults, &notifications.ChallengeResults},
+			{prefs.PaymentUpdates, &notifications.PaymentUpdates},
+			{prefs.FriendRequests, &notifications.FriendRequests},
+		} {
+			if field.value != nil {
+				*field.target = *field.value
+			}
+		}
+	}
+	profile.UpdatedAt = time.Now()
+
+	if err := h.db.Save(profile).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to update profile")
+		return
+	}
+
+	if profile.DisplayName == "" {
+		profile.DisplayName = player.Name
+	}
+	response.Success(c, profile)
+}
+
+// loadProfile returns the stored profile of a player, or the defaults when
+// the player never edited it. An empty display name is stored as is so the
+// profile follows later renames of the player.
+func loadProfile(db *gorm.DB, player *models.Player) (*models.PlayerProfile, error) {
+	profile := models.DefaultProfile(player.ID)
+	if err := db.Where("player_id = ?", player.ID).Limit(1).Find(&profile).Error; err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
@@ -1,24 +1,33 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 
+	"oxo-game-api/config"
+	"oxo-game-api/internal/api/middleware"
 	"oxo-game-api/internal/models"
+	"oxo-game-api/internal/scheduling"
+	"oxo-game-api/internal/social"
+	"oxo-game-api/pkg/utils/etag"
 	"oxo-game-api/pkg/utils/response"
 	"oxo-game-api/pkg/utils/validator"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type ReservationHandler struct {
-	db *gorm.DB
+	db  *gorm.DB
+	cfg *config.ReservationConfig
 }
 
-func NewReservationHandler(db *gorm.DB) *ReservationHandler {
-	return &ReservationHandler{db: db}
+func NewReservationHandler(db *gorm.DB, cfg *config.ReservationConfig) *ReservationHandler {
+	return &ReservationHandler{db: db, cfg: cfg}
 }
 
 // GetReservations godoc
@@ -27,19 +36,25 @@ func NewReservationHandler(db *gorm.DB) *ReservationHandler {
 // @Tags reservations
 // @Produce json
 // @Param room_id query string false "Room ID"
-// @Param date query string false "Reservation date in YYYY-MM-DD format"
+// @Param player_id query string false "Player ID hosting or taking part, players without reservations:read only see their own"
+// @Param date query string false "Day the reservation starts on, YYYY-MM-DD in UTC"
+// @Param status query string false "booked, checked_in, completed, cancelled or no_show"
 // @Param limit query int false "Limit the number of reservations"
 // @Success 200 {array} models.Reservation
 // @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
 // @Failure 404 {object} response.Response
 // @Failure 500 {object} response.Response
+// @Security BearerAuth
 // @Router /reservations [get]
 func (h *ReservationHandler) GetReservations(c *gin.Context) {
 	var reservations []models.Reservation
 	allowedParams := map[string]bool{
-		"room_id": true,
-		"date":    true,
-		"limit":   true,
+		"room_id":   true,
+		"player_id": true,
+		"date":      true,
+		"status":    true,
+		"limit":     true,
 	}
 
 	validator.CheckQueryParam(c, allowedParams)
@@ -49,20 +64,41 @@ func (h *ReservationHandler) GetReservations(c *gin.Context) {
 	}
 
 	roomID := c.Query("room_id")
+	playerID := c.Query("player_id")
 	date := c.Query("date")
+	status := c.Query("status")
 	limit := c.Query("limit")
-	query := h.db.Preload("Room")
+	query := h.db.Preload("Room").Preload("Participants", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	})
+
+	principal := middleware.CurrentPrincipal(c)
+	if !principal.Can(middleware.PermReservationsRead) {
+		if playerID != "" && playerID != strconv.FormatUint(uint64(principal.PlayerID), 10) {
+			response.PermissionDenied(c, middleware.PermReservationsRead)
+			return
+		}
+		playerID = strconv.FormatUint(uint64(principal.PlayerID), 10)
+	}
 
 	if roomID != "" {
 		query = query.Where("room_id = ?", roomID)
 	}
+	if playerID != "" {
+		query = query.Where("player_id = ? OR id IN (?)", playerID,
+			h.db.Model(&models.ReservationParticipant{}).Select("reservation_id").
+				Where("player_id = ? AND status <> ?", playerID, models.InvitationDeclined))
+	}
 	if date != "" {
-		_, err := time.Parse("2006-01-02", date)
+		day, err := time.Parse("2006-01-02", date)
 		if err != nil {
 			response.Error(c, http.StatusBadRequest, "Invalid date format. USE YYYY-MM-DD.")
 			return
 		}
-		query = query.Where("date = ?", date)
+		query = query.Where("starts_at >= ? AND starts_at < ?", day, day.AddDate(0, 0, 1))
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
 	}
 	if limit != "" {
 		limitInt, err := strconv.Atoi(limit)
@@ -71,7 +107,7 @@ func (h *ReservationHandler) GetReservations(c *gin.Context) {
 		}
 	}
 
-	if err := query.Find(&reservations).Error; err != nil {
+	if err := query.Order("starts_at").Find(&reservations).Error; err != nil {
 		response.Error(c, http.StatusInternalServerError, "Fail to fetch reservations")
 		return
 	}
@@ -86,30 +122,584 @@ func (h *ReservationHandler) GetReservations(c *gin.Context) {
 
 // CreateReservation godoc
 // @Summary Create a new reservation
-// @Description Creates a new reservation with the specified details
+// @Description Books a room from starts_at until ends_at, or for duration_minutes. The slot must be in the future, within the room's opening hours and free. With waitlist set, a taken slot puts the player on the waitlist and answers 202 with the entry. With rrule (FREQ=DAILY or WEEKLY, INTERVAL, BYDAY, COUNT or UNTIL) the slot repeats and every occurrence is booked, or none if one of them conflicts. The answer then holds series_id and reservation_ids. A room or player that does not exist answers 404.
 // @Tags reservations
 // @Accept json
 // @Produce json
-// @Param reservation body models.Reservation true "Reservation information"
+// @Param reservation body validator.ReservationValidation true "Reservation information"
 // @Success 200 {object} response.ReservCreateResponse
+// @Success 202 {object} models.WaitlistEntry
 // @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 422 {object} response.Response
 // @Failure 500 {object} response.Response
+// @Security BearerAuth
 // @Router /reservations [post]
 func (h *ReservationHandler) CreateReservation(c *gin.Context) {
-	var reservation models.Reservation
-	if err := c.ShouldBindJSON(&reservation); err != nil {
+	var input validator.ReservationValidation
+	if err := c.ShouldBindJSON(&input); err != nil {
 		response.Error(c, http.StatusBadRequest, err.Error())
 		return
 	}
+	if input.EndsAt != nil && input.DurationMinutes > 0 {
+		response.Error(c, http.StatusBadRequest, "Give either ends_at or duration_minutes, not both")
+		return
+	}
+	if input.RRule != "" && input.Waitlist {
+		response.Error(c, http.StatusBadRequest, "Recurring reservations cannot join the waitlist")
+		return
+	}
 
-	reservation.Date = time.Now().Format("2006-01-02")
-	reservation.Time = time.Now().Format("15:04:05")
-	reservation.CreatedAt = time.Now()
-	reservation.UpdatedAt = time.Now()
+	principal := middleware.CurrentPrincipal(c)
+	var acting bool
+	if input.PlayerID, acting = actingPlayer(c, input.PlayerID); !acting {
+		return
+	}
+	if !principal.CanActFor(input.PlayerID, middleware.PermReservationsWrite) {
+		response.PermissionDenied(c, middleware.PermReservationsWrite)
+		return
+	}
+	if rejectSanctioned(c, h.db, input.PlayerID) {
+		return
+	}
+
+	slot, err := reservationSlot(input)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
 
-	if err := h.db.Create(&reservation).Error; err != nil {
-		response.Error(c, http.StatusInternalServerError, "Fail to create reservation")
+	room, err := validator.FindRoomByID(h.db, uint64(input.RoomID))
+	if err != nil {
+		if err.Error() == "room not found" {
+			response.Error(c, http.StatusNotFound, "Room not found")
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch room by ID")
+		return
+	}
+	if _, err := validator.FindPlayerByID(h.db, uint64(input.PlayerID)); err != nil {
+		if err.Error() == "player not found" {
+			response.Error(c, http.StatusNotFound, "Player not found")
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch player by ID")
+		return
+	}
+
+	if input.RRule != "" {
+		h.createSeries(c, input, room, slot)
+		return
+	}
+
+	reservation := models.Reservation{
+		RoomID:    room.ID,
+		PlayerID:  input.PlayerID,
+		StartsAt:  slot.Start,
+		EndsAt:    slot.End,
+		Status:    models.ReservationBooked,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := scheduling.CheckSlot(tx, room, slot, 0, time.Now()); err != nil {
+			return err
+		}
+		if err := tx.Create(&reservation).Error; err != nil {
+			return err
+		}
+		if err := scheduling.AddHost(tx, &reservation); err != nil {
+			return err
+		}
+		return scheduling.RecordBooked(tx, &reservation, principal.PlayerID)
+	})
+	if input.Waitlist && slotTaken(err) {
+		h.joinWaitlist(c, input, slot)
+		return
+	}
+	if err != nil {
+		scheduleError(c, err, "Fail to create reservation")
 		return
 	}
 	response.Success(c, gin.H{"reservation_id": reservation.ID})
 }
+
+// InviteFriends godoc
+// @Summary Invite friends to a reservation
+// @Description Invites friends of the host. Players already taking part are skipped. Returns every participant of the reservation.
+// @Tags reservations
+// @Accept json
+// @Produce json
+// @Param id path int true "Reservation ID"
+// @Param invitation body validator.InvitationValidation true "Invited players"
+// @Success 200 {array} models.ReservationParticipant
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 422 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /reservations/{id}/invitations [post]
+func (h *ReservationHandler) InviteFriends(c *gin.Context) {
+	reservation, ok := h.findReservation(c, middleware.PermReservationsWrite)
+	if !ok {
+		return
+	}
+
+	var input validator.InvitationValidation
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	principal := middleware.CurrentPrincipal(c)
+
+	for _, playerID := range input.PlayerIDs {
+		friends, err := social.AreFriends(h.db, reservation.PlayerID, playerID)
+		if err != nil {
+			response.Error(c, http.StatusInternalServerError, "Fail to check friendship")
+			return
+		}
+		if !friends {
+			response.Error(c, http.StatusUnprocessableEntity,
+				fmt.Sprintf("player %d is not a friend of the reservation owner", playerID))
+			return
+		}
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		return scheduling.Invite(tx, &reservation, input.PlayerIDs, principal.PlayerID, time.Now())
+	})
+	if err != nil {
+		scheduleError(c, err, "Fail to create invitations")
+		return
+	}
+
+	var all []models.ReservationParticipant
+	if err := h.db.Where("reservation_id = ?", reservation.ID).Order("id").Find(&all).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch invitations")
+		return
+	}
+
+	response.Success(c, all)
+}
+
+// AcceptInvitation godoc
+// @Summary Accept an invitation to a reservation
+// @Description Joins the reservation the caller was invited to, as long as the room has space left
+// @Tags reservations
+// @Produce json
+// @Param id path int true "Reservation ID"
+// @Success 200 {object} models.ReservationParticipant
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /reservations/{id}/accept [post]
+func (h *ReservationHandler) AcceptInvitation(c *gin.Context) {
+	h.respond(c, true)
+}
+
+// DeclineInvitation godoc
+// @Summary Decline an invitation to a reservation
+// @Tags reservations
+// @Produce json
+// @Param id path int true "Reservation ID"
+// @Success 200 {object} models.ReservationParticipant
+// @Failure 400 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /reservations/{id}/decline [post]
+func (h *ReservationHandler) DeclineInvitation(c *gin.Context) {
+	h.respond(c, false)
+}
+
+func (h *ReservationHandler) respond(c *gin.Context, accept bool) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	playerID, ok := actingPlayer(c, 0)
+	if !ok {
+		return
+	}
+
+	participant, err := scheduling.Respond(h.db, uint(id), playerID, accept, time.Now())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		response.Error(c, http.StatusNotFound, "reservation not found")
+		return
+	}
+	if err != nil {
+		scheduleError(c, err, "Fail to answer invitation")
+		return
+	}
+	response.Success(c, participant)
+}
+
+// GetReservationByID godoc
+// @Summary Get a reservation by ID
+// @Tags reservations
+// @Produce json
+// @Param id path int true "Reservation ID"
+// @Success 200 {object} models.Reservation
+// @Header 200 {string} ETag "Version of the reservation, for If-Match"
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /reservations/{id} [get]
+func (h *ReservationHandler) GetReservationByID(c *gin.Context) {
+	reservation, ok := h.findReservation(c, middleware.PermReservationsRead)
+	if !ok {
+		return
+	}
+
+	etag.Set(c, etag.Of(reservation.ID, reservation.UpdatedAt))
+	response.Success(c, reservation)
+}
+
+// UpdateReservationByID godoc
+// @Summary Update a reservation by ID
+// @Description Reschedules a booked reservation with a JSON merge patch (RFC 7396). A duration_minutes in the patch moves ends_at. The new slot is checked like a new reservation. Players can only reschedule before the cancellation window closes.
+// @Tags reservations
+// @Accept json
+// @Produce json
+// @Param id path int true "Reservation ID"
+// @Param If-Match header string false "ETag of the version being changed"
+// @Param reservation body validator.ReservationValidation true "Reservation fields to change"
+// @Success 200 {object} models.Reservation
+// @Header 200 {string} ETag "New version of the reservation"
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 412 {object} response.Response
+// @Failure 415 {object} response.Response
+// @Failure 422 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /reservations/{id} [patch]
+func (h *ReservationHandler) UpdateReservationByID(c *gin.Context) {
+	reservation, ok := h.findReservation(c, middleware.PermReservationsWrite)
+	if !ok {
+		return
+	}
+	if !ifMatch(c, reservation.ID, reservation.UpdatedAt) {
+		return
+	}
+	if reservation.Status != models.ReservationBooked {
+		response.Error(c, http.StatusConflict, fmt.Sprintf("A %s reservation cannot be rescheduled", reservation.Status))
+		return
+	}
+	if !h.canChange(c, reservation) {
+		return
+	}
+
+	previousRoomID := reservation.RoomID
+	previous := scheduling.Interval{Start: reservation.StartsAt, End: reservation.EndsAt}
+	input := validator.ReservationValidation{
+		RoomID:   reservation.RoomID,
+		PlayerID: reservation.PlayerID,
+		StartsAt: ptrTime(previous.Start),
+		EndsAt:   ptrTime(previous.End),
+	}
+	if err := bindUpdate(c, &input); err != nil {
+		bindError(c, err, "")
+		return
+	}
+	if input.RRule != "" {
+		response.Error(c, http.StatusBadRequest, "Change the recurrence of a series with PATCH /reservations/series/{id}")
+		return
+	}
+
+	slot, err := reservationSlot(input)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	room, err := validator.FindRoomByID(h.db, uint64(input.RoomID))
+	if err != nil {
+		if err.Error() == "room not found" {
+			response.Error(c, http.StatusNotFound, "Room not found")
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch room by ID")
+		return
+	}
+
+	version := reservation.UpdatedAt
+	reservation.RoomID = room.ID
+	reservation.Room = room
+	reservation.StartsAt = slot.Start
+	reservation.EndsAt = slot.End
+	reservation.Sequence++
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockVersion(tx, &models.Reservation{}, reservation.ID, version); err != nil {
+			return err
+		}
+		if err := scheduling.CheckSlot(tx, room, slot, reservation.ID, time.Now()); err != nil {
+			return err
+		}
+		joined, err := scheduling.Joined(tx, reservation.ID)
+		if err != nil {
+			return err
+		}
+		if joined > int64(room.Capacity) {
+			return scheduling.ErrRoomTooSmall
+		}
+		if err := tx.Omit(clause.Associations).Save(&reservation).Error; err != nil {
+			return err
+		}
+		_, err = scheduling.Promote(tx, previousRoomID, previous, h.cfg.WaitlistConfirmWindow, time.Now())
+		return err
+	})
+	if errors.Is(err, errStale) {
+		response.Error(c, http.StatusPreconditionFailed, staleMessage)
+		return
+	}
+	if err != nil {
+		scheduleError(c, err, "Fail to update reservation")
+		return
+	}
+
+	etag.Set(c, etag.Of(reservation.ID, reservation.UpdatedAt))
+	response.Success(c, reservation)
+}
+
+// CancelReservation godoc
+// @Summary Cancel a reservation
+// @Description Cancels a booked reservation and passes its slot on to the waitlist. Players have to cancel before the cancellation window closes, operators can cancel at any time.
+// @Tags reservations
+// @Accept json
+// @Produce json
+// @Param id path int true "Reservation ID"
+// @Param cancellation body validator.CancelValidation false "Reason for cancelling"
+// @Success 200 {object} models.Reservation
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /reservations/{id}/cancel [post]
+func (h *ReservationHandler) CancelReservation(c *gin.Context) {
+	reservation, ok := h.findReservation(c, middleware.PermReservationsWrite)
+	if !ok {
+		return
+	}
+
+	var input validator.CancelValidation
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			response.Error(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	principal := middleware.CurrentPrincipal(c)
+	override := principal.Can(middleware.PermReservationsWrite)
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := scheduling.Cancel(tx, &reservation, principal.PlayerID, input.Reason, h.cfg.CancellationWindow, override, now); err != nil {
+			return err
+		}
+		_, err := scheduling.ReleaseSlot(tx, &reservation, h.cfg.WaitlistConfirmWindow, now)
+		return err
+	})
+	if err != nil {
+		scheduleError(c, err, "Fail to cancel reservation")
+		return
+	}
+
+	etag.Set(c, etag.Of(reservation.ID, reservation.UpdatedAt))
+	response.Success(c, reservation)
+}
+
+// CheckInReservation godoc
+// @Summary Check in to a reservation
+// @Description Checks the player in. Check-in opens shortly before the start and closes at the end of the reservation.
+// @Tags reservations
+// @Produce json
+// @Param id path int true "Reservation ID"
+// @Success 200 {object} models.Reservation
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /reservations/{id}/check-in [post]
+func (h *ReservationHandler) CheckInReservation(c *gin.Context) {
+	reservation, ok := h.findReservation(c, middleware.PermReservationsWrite)
+	if !ok {
+		return
+	}
+
+	principal := middleware.CurrentPrincipal(c)
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		return scheduling.CheckIn(tx, &reservation, principal.PlayerID, h.cfg.CheckInOpens, time.Now())
+	})
+	if err != nil {
+		scheduleError(c, err, "Fail to check in")
+		return
+	}
+
+	etag.Set(c, etag.Of(reservation.ID, reservation.UpdatedAt))
+	response.Success(c, reservation)
+}
+
+// GetReservationTransitions godoc
+// @Summary Get the status history of a reservation
+// @Description Lists every status change of the reservation, oldest first. Changes made by the no-show sweeper have no actor_id.
+// @Tags reservations
+// @Produce json
+// @Param id path int true "Reservation ID"
+// @Success 200 {array} models.ReservationTransition
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /reservations/{id}/transitions [get]
+func (h *ReservationHandler) GetReservationTransitions(c *gin.Context) {
+	reservation, ok := h.findReservation(c, middleware.PermReservationsRead)
+	if !ok {
+		return
+	}
+
+	transitions := []models.ReservationTransition{}
+	if err := h.db.Where("reservation_id = ?", reservation.ID).Order("id").Find(&transitions).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch reservation history")
+		return
+	}
+	response.Success(c, transitions)
+}
+
+// canChange answers 409 when a player tries to change their reservation after
+// the cancellation window has closed, operators are not bound by it
+func (h *ReservationHandler) canChange(c *gin.Context, reservation models.Reservation) bool {
+	if middleware.CurrentPrincipal(c).Can(middleware.PermReservationsWrite) {
+		return true
+	}
+	if time.Now().After(reservation.StartsAt.Add(-h.cfg.CancellationWindow)) {
+		response.Error(c, http.StatusConflict, scheduling.ErrCancellationClosed.Error())
+		return false
+	}
+	return true
+}
+
+// findReservation loads the reservation named by the id parameter and checks
+// the caller owns it or holds perm, participants can also read it. It answers
+// the request itself on failure.
+func (h *ReservationHandler) findReservation(c *gin.Context, perm string) (models.Reservation, bool) {
+	var reservation models.Reservation
+
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return reservation, false
+	}
+
+	if err := h.db.Preload("Room").Preload("Participants", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).First(&reservation, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Error(c, http.StatusNotFound, "reservation not found")
+			return reservation, false
+		}
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch reservation")
+		return reservation, false
+	}
+
+	principal := middleware.CurrentPrincipal(c)
+	if principal.CanActFor(reservation.PlayerID, perm) {
+		return reservation, true
+	}
+	if perm == middleware.PermReservationsRead && participates(reservation, principal.PlayerID) {
+		return reservation, true
+	}
+	response.PermissionDenied(c, perm)
+	return reservation, false
+}
+
+// participates reports whether the player is invited to or has joined the
+// reservation
+func participates(reservation models.Reservation, playerID uint) bool {
+	for _, participant := range reservation.Participants {
+		if participant.PlayerID == playerID && participant.Status != models.InvitationDeclined {
+			return true
+		}
+	}
+	return false
+}
+
+// reservationSlot turns the requested start and end or duration into the
+// slot to book, in UTC
+func reservationSlot(input validator.ReservationValidation) (scheduling.Interval, error) {
+	start := input.StartsAt.UTC()
+	if input.DurationMinutes > 0 {
+		return scheduling.Interval{Start: start, End: start.Add(time.Duration(input.DurationMinutes) * time.Minute)}, nil
+	}
+	if input.EndsAt == nil {
+		return scheduling.Interval{}, errors.New("either ends_at or duration_minutes is required")
+	}
+	return scheduling.Interval{Start: start, End: input.EndsAt.UTC()}, nil
+}
+
+func scheduleError(c *gin.Context, err error, fallback string) {
+	var conflict *scheduling.ConflictError
+	var seriesConflict *scheduling.SeriesConflictError
+	switch {
+	case errors.As(err, &conflict):
+		response.ErrorWithData(c, http.StatusConflict, conflict.Error(), gin.H{"conflicting_reservation_id": conflict.ReservationID})
+	case errors.As(err, &seriesConflict):
+		response.ErrorWithData(c, http.StatusConflict, seriesConflict.Error(), gin.H{"conflicts": seriesConflict.Conflicts})
+	case scheduling.IsOverlapViolation(err):
+		response.Error(c, http.StatusConflict, (&scheduling.ConflictError{}).Error())
+	case errors.Is(err, scheduling.ErrMaintenance),
+		errors.Is(err, scheduling.ErrReservationFull),
+		errors.Is(err, scheduling.ErrRoomTooSmall),
+		errors.Is(err, scheduling.ErrNotJoinable),
+		errors.Is(err, scheduling.ErrAlreadyAnswered),
+		errors.Is(err, scheduling.ErrAlreadyWaiting),
+		errors.Is(err, scheduling.ErrNotWaiting),
+		errors.Is(err, scheduling.ErrOfferExpired),
+		errors.Is(err, scheduling.ErrEntryClosed),
+		errors.Is(err, scheduling.ErrInvalidTransition),
+		errors.Is(err, scheduling.ErrCancellationClosed),
+		errors.Is(err, scheduling.ErrCheckInClosed),
+		errors.Is(err, scheduling.ErrNoUpcoming):
+		response.Error(c, http.StatusConflict, err.Error())
+	case errors.Is(err, scheduling.ErrInPast),
+		errors.Is(err, scheduling.ErrEmptySlot),
+		errors.Is(err, scheduling.ErrTooLong),
+		errors.Is(err, scheduling.ErrInvalidStartTime):
+		response.Error(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, scheduling.ErrNotInvited):
+		response.Error(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, scheduling.ErrOutsideHours),
+		errors.Is(err, scheduling.ErrRoomUnavailable):
+		response.Error(c, http.StatusUnprocessableEntity, err.Error())
+	case validator.IsForeignKeyViolation(err):
+		response.Error(c, http.StatusUnprocessableEntity, "Reservation references a room or player that does not exist")
+	default:
+		response.Error(c, http.StatusInternalServerError, fallback)
+	}
+}
+
+func ptrTime(t time.Time) *time.Time {
+	return &t
+}
@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"oxo-game-api/internal/api/middleware"
+	"oxo-game-api/internal/models"
+	"oxo-game-api/internal/scheduling"
+	"oxo-game-api/pkg/utils/response"
+	"oxo-game-api/pkg/utils/validator"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetReservationSeries godoc
+// @Summary Get a recurring reservation
+// @Description Returns the series with every occurrence, cancelled ones included
+// @Tags reservations
+// @Produce json
+// @Param id path int true "Series ID"
+// @Success 200 {object} models.ReservationSeries
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /reservations/series/{id} [get]
+func (h *ReservationHandler) GetReservationSeries(c *gin.Context) {
+	series, ok := h.findSeries(c, middleware.PermReservationsRead)
+	if !ok {
+		return
+	}
+
+	if err := h.db.Where("series_id = ?", series.ID).Order("starts_at").Find(&series.Reservations).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch reservations of the series")
+		return
+	}
+	response.Success(c, series)
+}
+
+// UpdateReservationSeries godoc
+// @Summary Edit a recurring reservation
+// @Description Moves the upcoming booked occurrences of the series to another room, start time (HH:MM in the room's time zone) or duration, each on its own day. With from_reservation_id only that occurrence and the ones after it change, and they are split off into a new series. Every occurrence is checked and nothing changes if one of them conflicts. Players cannot change occurrences once their cancellation window has closed.
+// @Tags reservations
+// @Accept json
+// @Produce json
+// @Param id path int true "Series ID"
+// @Param series body validator.SeriesUpdateValidation true "Series fields to change"
+// @Success 200 {object} models.ReservationSeries
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 422 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /reservations/series/{id} [patch]
+func (h *ReservationHandler) UpdateReservationSeries(c *gin.Context) {
+	series, ok := h.findSeries(c, middleware.PermReservationsWrite)
+	if !ok {
+		return
+	}
+
+	var input validator.SeriesUpdateValidation
+	if err := c.ShouldBindJSON(&input); err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	change := scheduling.SeriesChange{StartTime: input.StartTime, DurationMinutes: input.DurationMinutes}
+	if input.RoomID != nil {
+		room, err := validator.FindRoomByID(h.db, uint64(*input.RoomID))
+		if err != nil {
+			if err.Error() == "room not found" {
+				response.Error(c, http.StatusNotFound, "Room not found")
+				return
+			}
+			response.Error(c, http.StatusInternalServerError, "Fail to fetch room by ID")
+			return
+		}
+		change.Room = room
+	}
+
+	from, ok := h.seriesFrom(c, series, input.FromReservationID)
+	if !ok {
+		return
+	}
+
+	target := &series
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&series, series.ID).Error; err != nil {
+			return err
+		}
+		if input.FromReservationID != nil {
+			split, err := scheduling.SplitSeries(tx, &series, from)
+			if err != nil {
+				return err
+			}
+			target = split
+		}
+
+		occurrences, err := h.upcoming(c, tx, target.ID, from, now)
+		if err != nil {
+			return err
+		}
+		roomIDs := make([]uint, len(occurrences))
+		for i, occurrence := range occurrences {
+			roomIDs[i] = occurrence.RoomID
+		}
+
+		previous, err := scheduling.EditSeries(tx, target, occurrences, change, now)
+		if err != nil {
+			return err
+		}
+		for i, slot := range previous {
+			if _, err := scheduling.Promote(tx, roomIDs[i], slot, h.cfg.WaitlistConfirmWindow, now); err != nil {
+				return err
+			}
+		}
+		return tx.Where("series_id = ?", target.ID).Order("starts_at").Find(&target.Reservations).Error
+	})
+	if err != nil {
+		scheduleError(c, err, "Fail to update reservation series")
+		return
+	}
+	response.Success(c, target)
+}
+
+// CancelReservationSeries godoc
+// @Summary Cancel a recurring reservation
+// @Description Cancels the upcoming booked occurrences of the series and passes their slots on to the waitlist. With from_reservation_id only that occurrence and the ones after it are cancelled. Players cannot cancel occurrences once their cancellation window has closed, those are kept.
+// @Tags reservations
+// @Accept json
+// @Produce json
+// @Param id path int true "Series ID"
+// @Param cancellation body validator.SeriesCancelValidation false "Reason and first occurrence to cancel"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 422 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /reservations/series/{id}/cancel [post]
+func (h *ReservationHandler) CancelReservationSeries(c *gin.Context) {
+	series, ok := h.findSeries(c, middleware.PermReservationsWrite)
+	if !ok {
+		return
+	}
+
+	var input validator.SeriesCancelValidation
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			response.Error(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	from, ok := h.seriesFrom(c, series, input.FromReservationID)
+	if !ok {
+		return
+	}
+
+	principal := middleware.CurrentPrincipal(c)
+	override := principal.Can(middleware.PermReservationsWrite)
+	cancelled := []uint{}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		occurrences, err := h.upcoming(c, tx, series.ID, from, now)
+		if err != nil {
+			return err
+		}
+		if len(occurrences) == 0 {
+			return scheduling.ErrNoUpcoming
+		}
+		for i := range occurrences {
+			if err := scheduling.Cancel(tx, &occurrences[i], principal.PlayerID, input.Reason, h.cfg.CancellationWindow, override, now); err != nil {
+				return err
+			}
+			if _, err := scheduling.ReleaseSlot(tx, &occurrences[i], h.cfg.WaitlistConfirmWindow, now); err != nil {
+				return err
+			}
+			cancelled = append(cancelled, occurrences[i].ID)
+		}
+		return nil
+	})
+	if err != nil {
+		scheduleError(c, err, "Fail to cancel reservation series")
+		return
+	}
+	response.Success(c, gin.H{"series_id": series.ID, "cancelled_reservation_ids": cancelled})
+}
+
+// createSeries books every occurrence of the recurrence rule, or none of
+// them when one conflicts
+func (h *ReservationHandler) createSeries(c *gin.Context, input validator.ReservationValidation, room *models.Room, slot scheduling.Interval) {
+	slots, err := scheduling.Occurrences(room, input.RRule, slot)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	series := models.ReservationSeries{
+		PlayerID:  input.PlayerID,
+		RRule:     input.RRule,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	var reservations []models.Reservation
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		reservations, err = scheduling.BookSeries(tx, room, &series, slots, middleware.CurrentPrincipal(c).PlayerID, time.Now())
+		return err
+	})
+	if err != nil {
+		scheduleError(c, err, "Fail to create reservation series")
+		return
+	}
+
+	ids := make([]uint, len(reservations))
+	for i, reservation := range reservations {
+		ids[i] = reservation.ID
+	}
+	response.Success(c, gin.H{"series_id": series.ID, "reservation_ids": ids})
+}
+
+// upcoming loads the booked occurrences of the series starting at or after
+// from that the caller can still change, for players the ones outside the
+// cancellation window
+func (h *ReservationHandler) upcoming(c *gin.Context, tx *gorm.DB, seriesID uint, from, now time.Time) ([]models.Reservation, error) {
+	cutoff := now
+	if !middleware.CurrentPrincipal(c).Can(middleware.PermReservationsWrite) {
+		cutoff = now.Add(h.cfg.CancellationWindow)
+	}
+	if from.After(cutoff) {
+		cutoff = from
+	}
+
+	var occurrences []models.Reservation
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("series_id = ? AND status = ? AND starts_at >= ?", seriesID, models.ReservationBooked, cutoff).
+		Order("starts_at").Find(&occurrences).Error
+	return occurrences, err
+}
+
+// seriesFrom returns the start of the occurrence a "this and following"
+// change begins with, or the zero time for a change to the whole series. It
+// answers the request itself on failure.
+func (h *ReservationHandler) seriesFrom(c *gin.Context, series models.ReservationSeries, reservationID *uint) (time.Time, bool) {
+	if reservationID == nil {
+		return time.Time{}, true
+	}
+
+	var reservation models.Reservation
+	err := h.db.Where("id = ? AND series_id = ?", *reservationID, series.ID).First(&reservation).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		response.Error(c, http.StatusUnprocessableEntity, scheduling.ErrNotInSeries.Error())
+		return time.Time{}, false
+	}
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch reservation")
+		return time.Time{}, false
+	}
+	return reservation.StartsAt, true
+}
+
+// findSeries loads the series named by the id parameter and checks the
+// caller owns it or holds perm. It answers the request itself on failure.
+func (h *ReservationHandler) findSeries(c *gin.Context, perm string) (models.ReservationSeries, bool) {
+	var series models.ReservationSeries
+
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return series, false
+	}
+
+	if err := h.db.First(&series, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Error(c, http.StatusNotFound, "reservation series not found")
+			return series, false
+		}
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch reservation series")
+		return series, false
+	}
+
+	if !middleware.CurrentPrincipal(c).CanActFor(series.PlayerID, perm) {
+		response.PermissionDenied(c, perm)
+		return series, false
+	}
+	return series, true
+}
@@ -1,11 +1,17 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"oxo-game-api/internal/api/middleware"
 	"oxo-game-api/internal/models"
+	"oxo-game-api/internal/scheduling"
+	"oxo-game-api/pkg/utils/etag"
 	"oxo-game-api/pkg/utils/response"
 	"oxo-game-api/pkg/utils/validator"
 
@@ -21,38 +27,228 @@ func NewRoomHandler(db *gorm.DB) *RoomHandler {
 	return &RoomHandler{db: db}
 }
 
+// roomSortFields whitelists the columns GET /rooms can sort on
+var roomSortFields = map[string]string{
+	"id":         "id",
+	"name":       "name",
+	"status":     "status",
+	"capacity":   "capacity",
+	"created_at": "created_at",
+}
+
 // GetRooms godoc
-// @Summary Get all rooms
-// @Description Fetches a list of all rooms
+// @Summary Get rooms
+// @Description Searches, filters, sorts and paginates rooms. With free_from and free_to only the rooms that can be booked for that whole slot are listed.
 // @Tags rooms
 // @Produce json
-// @Success 200 {array} models.Room
+// @Param status query string false "Comma separated statuses: available, occupied, maintenance, closed"
+// @Param min_capacity query int false "Minimum capacity"
+// @Param max_capacity query int false "Maximum capacity"
+// @Param name_prefix query string false "Case-insensitive name prefix"
+// @Param name_contains query string false "Case-insensitive name substring"
+// @Param free_from query string false "Start of the slot the room must be free for, RFC 3339"
+// @Param free_to query string false "End of the slot the room must be free for, RFC 3339"
+// @Param sort query string false "Sort field, prefix with - for descending: id, name, status, capacity, created_at"
+// @Param limit query int false "Page size, 1 to 100, defaults to 20"
+// @Param offset query int false "Number of rooms to skip"
+// @Success 200 {object} response.Page{items=[]models.Room}
+// @Failure 400 {object} response.Response
 // @Failure 500 {object} response.Response
+// @Security BearerAuth
 // @Router /rooms [get]
 func (h *RoomHandler) GetRooms(c *gin.Context) {
-	var rooms []models.Room
-	if err := h.db.Find(&rooms).Error; err != nil {
-		response.Error(c, http.StatusInternalServerError, "Failed to fetch rooms")
+	allowedParams := map[string]bool{
+		"status":        true,
+		"min_capacity":  true,
+		"max_capacity":  true,
+		"name_prefix":   true,
+		"name_contains": true,
+		"free_from":     true,
+		"free_to":       true,
+		"sort":          true,
+		"limit":         true,
+		"offset":        true,
+	}
+
+	validator.CheckQueryParam(c, allowedParams)
+
+	if c.IsAborted() {
 		return
 	}
 
-	if len(rooms) == 0 {
-		response.Success(c, gin.H{"message": "There is no rooms"})
+	limit, offset, err := validator.GetPagination(c, 20, 100)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query := h.db.Model(&models.Room{})
+
+	if status := c.Query("status"); status != "" {
+		known := make(map[string]bool, len(models.RoomStatuses))
+		for _, s := range models.RoomStatuses {
+			known[s] = true
+		}
+		statuses := strings.Split(status, ",")
+		for _, s := range statuses {
+			if !known[s] {
+				response.Error(c, http.StatusBadRequest, fmt.Sprintf("Invalid status: %s", s))
+				return
+			}
+		}
+		query = query.Where("status IN ?", statuses)
+	}
+	if minCapacity := c.Query("min_capacity"); minCapacity != "" {
+		value, err := strconv.Atoi(minCapacity)
+		if err != nil {
+			response.Error(c, http.StatusBadRequest, "Invalid min_capacity")
+			return
+		}
+		query = query.Where("capacity >= ?", value)
+	}
+	if maxCapacity := c.Query("max_capacity"); maxCapacity != "" {
+		value, err := strconv.Atoi(maxCapacity)
+		if err != nil {
+			response.Error(c, http.StatusBadRequest, "Invalid max_capacity")
+			return
+		}
+		query = query.Where("capacity <= ?", value)
+	}
+	if prefix := c.Query("name_prefix"); prefix != "" {
+		query = query.Where("name ILIKE ?", validator.EscapeLike(prefix)+"%")
+	}
+	if contains := c.Query("name_contains"); contains != "" {
+		query = query.Where("name ILIKE ?", "%"+validator.EscapeLike(contains)+"%")
+	}
+
+	order, err := validator.GetSortOrder(c.DefaultQuery("sort", "id"), roomSortFields)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
 		return
 	}
-	response.Success(c, rooms)
+
+	slot, ok := freeSlotQuery(c)
+	if !ok {
+		return
+	}
+	if slot == nil {
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			response.Error(c, http.StatusInternalServerError, "Failed to fetch rooms")
+			return
+		}
+
+		rooms := []models.Room{}
+		if err := query.Order(order).Order("id").Limit(limit).Offset(offset).Find(&rooms).Error; err != nil {
+			response.Error(c, http.StatusInternalServerError, "Failed to fetch rooms")
+			return
+		}
+
+		response.Success(c, response.Page{
+			Items:  rooms,
+			Total:  total,
+			Limit:  limit,
+			Offset: offset,
+		})
+		return
+	}
+
+	// the database rules out the rooms that are booked or under maintenance,
+	// opening hours depend on each room's timezone and are checked here
+	query = query.Where("status IN ?", []string{models.RoomAvailable, models.RoomOccupied}).
+		Where("NOT EXISTS (?)", h.db.Model(&models.Reservation{}).Select("1").
+			Where("reservations.room_id = rooms.id AND reservations.status IN ? AND reservations.starts_at < ? AND reservations.ends_at > ?",
+				models.ActiveReservationStatuses, slot.End, slot.Start)).
+		Where("NOT EXISTS (?)", h.db.Model(&models.MaintenanceWindow{}).Select("1").
+			Where("maintenance_windows.room_id = rooms.id AND maintenance_windows.starts_at < ? AND maintenance_windows.ends_at > ?",
+				slot.End, slot.Start))
+
+	var candidates []models.Room
+	if err := query.Order(order).Order("id").Find(&candidates).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Failed to fetch rooms")
+		return
+	}
+
+	now := time.Now()
+	free := []models.Room{}
+	for _, room := range candidates {
+		hours, err := scheduling.RoomHours(&room)
+		if err != nil {
+			response.Error(c, http.StatusInternalServerError, "Failed to fetch rooms")
+			return
+		}
+		if scheduling.Validate(*slot, hours, now) == nil {
+			free = append(free, room)
+		}
+	}
+
+	rooms := []models.Room{}
+	if offset < len(free) {
+		end := offset + limit
+		if end > len(free) {
+			end = len(free)
+		}
+		rooms = free[offset:end]
+	}
+	response.Success(c, response.Page{
+		Items:  rooms,
+		Total:  int64(len(free)),
+		Limit:  limit,
+		Offset: offset,
+	})
+}
+
+// freeSlotQuery reads the free_from and free_to query parameters, the slot is
+// nil when neither is given
+func freeSlotQuery(c *gin.Context) (*scheduling.Interval, bool) {
+	from, to := c.Query("free_from"), c.Query("free_to")
+	if from == "" && to == "" {
+		return nil, true
+	}
+	if from == "" || to == "" {
+		response.Error(c, http.StatusBadRequest, "free_from and free_to must be given together")
+		return nil, false
+	}
+
+	start, err := parseQueryTime(from)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid free_from, use RFC 3339 like 2025-01-06T20:00:00Z")
+		return nil, false
+	}
+	end, err := parseQueryTime(to)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid free_to, use RFC 3339 like 2025-01-06T21:00:00Z")
+		return nil, false
+	}
+
+	slot := scheduling.Interval{Start: start, End: end}
+	switch {
+	case !slot.End.After(slot.Start):
+		response.Error(c, http.StatusBadRequest, "free_to must be after free_from")
+		return nil, false
+	case !slot.Start.After(time.Now()):
+		response.Error(c, http.StatusBadRequest, "free_from must be in the future")
+		return nil, false
+	case slot.Duration() > scheduling.MaxDuration:
+		response.Error(c, http.StatusBadRequest, fmt.Sprintf("A free slot is at most %d hours long", int(scheduling.MaxDuration.Hours())))
+		return nil, false
+	}
+	return &slot, true
 }
 
 // CreateRoom godoc
 // @Summary Create a new room
-// @Description Creates a new room with the specified details
+// @Description Creates a new room with the specified details. The status is available unless given, opening hours default to 00:00-24:00 UTC.
 // @Tags rooms
 // @Accept json
 // @Produce json
 // @Param room body models.Room true "Room information"
 // @Success 200 {object} response.RoomCreateResponse"
 // @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 409 {object} response.Response
 // @Failure 500 {object} response.Response
+// @Security BearerAuth
 // @Router /rooms [post]
 func (h *RoomHandler) CreateRoom(c *gin.Context) {
 	var input validator.RoomValidation
@@ -60,16 +256,28 @@ func (h *RoomHandler) CreateRoom(c *gin.Context) {
 		response.Error(c, http.StatusBadRequest, err.Error())
 		return
 	}
+	if err := roomDefaults(&input); err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
 
 	room := &models.Room{
 		Name:        input.Name,
 		Description: input.Description,
-		Status:      "Active",
+		Status:      input.Status,
+		Capacity:    input.Capacity,
+		OpensAt:     input.OpensAt,
+		ClosesAt:    input.ClosesAt,
+		Timezone:    input.Timezone,
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
 
 	if err := h.db.Create(room).Error; err != nil {
+		if validator.IsUniqueViolation(err) {
+			response.Error(c, http.StatusConflict, "Room name already exists")
+			return
+		}
 		response.Error(c, http.StatusInternalServerError, "Failed to create room")
 		return
 	}
@@ -83,8 +291,10 @@ func (h *RoomHandler) CreateRoom(c *gin.Context) {
 // @Produce json
 // @Param id path int true "Room ID"
 // @Success 200 {object} models.Room
+// @Header 200 {string} ETag "Version of the room, for If-Match"
 // @Failure 404 {object} response.Response
 // @Failure 500 {object} response.Response
+// @Security BearerAuth
 // @Router /rooms/{id} [get]
 func (h *RoomHandler) GetRoomByID(c *gin.Context) {
 	id, err := validator.GetParamID(c)
@@ -102,22 +312,31 @@ func (h *RoomHandler) GetRoomByID(c *gin.Context) {
 		response.Error(c, http.StatusInternalServerError, "Fail to fetch room by ID")
 		return
 	}
+	etag.Set(c, etag.Of(room.ID, room.UpdatedAt))
 	response.Success(c, room)
 }
 
 // UpdateRoomByID godoc
 // @Summary Update a room by ID
-// @Description Updates the details of a room by its ID
+// @Description Updates the details of a room by its ID. PUT replaces them, PATCH applies a JSON merge patch (RFC 7396). The status is one of available, occupied, maintenance and closed. Rooms under maintenance or closed have to be made available before they can be occupied. Only available and occupied rooms take reservations.
 // @Tags rooms
 // @Accept json
 // @Produce json
 // @Param id path int true "Room ID"
-// @Param room body models.Room true "Updated room information"
+// @Param If-Match header string false "ETag of the version being changed"
+// @Param room body validator.RoomValidation true "Updated room information"
 // @Success 200 {object} models.Room
+// @Header 200 {string} ETag "New version of the room"
 // @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
 // @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 412 {object} response.Response
+// @Failure 415 {object} response.Response
 // @Failure 500 {object} response.Response
+// @Security BearerAuth
 // @Router /rooms/{id} [put]
+// @Router /rooms/{id} [patch]
 func (h *RoomHandler) UpdateRoomByID(c *gin.Context) {
 	id, err := validator.GetParamID(c)
 	if err != nil {
@@ -135,35 +354,101 @@ func (h *RoomHandler) UpdateRoomByID(c *gin.Context) {
 		return
 	}
 
-	var input validator.RoomValidation
-	if err := c.ShouldBindJSON(&input); err != nil {
+	if !ifMatch(c, room.ID, room.UpdatedAt) {
+		return
+	}
+
+	input := validator.RoomValidation{
+		Name:        room.Name,
+		Description: room.Description,
+		Status:      room.Status,
+		Capacity:    room.Capacity,
+		OpensAt:     room.OpensAt,
+		ClosesAt:    room.ClosesAt,
+		Timezone:    room.Timezone,
+	}
+	if err := bindUpdate(c, &input); err != nil {
+		bindError(c, err, "")
+		return
+	}
+	if input.Status == "" {
+		input.Status = room.Status
+	}
+	if err := roomDefaults(&input); err != nil {
 		response.Error(c, http.StatusBadRequest, err.Error())
 		return
 	}
+	if err := scheduling.CheckRoomStatus(room.Status, input.Status); err != nil {
+		response.Error(c, http.StatusConflict, err.Error())
+		return
+	}
 
+	version := room.UpdatedAt
 	room.Name = input.Name
 	room.Description = input.Description
 	room.Status = input.Status
+	room.Capacity = input.Capacity
+	room.OpensAt = input.OpensAt
+	room.ClosesAt = input.ClosesAt
+	room.Timezone = input.Timezone
 	room.UpdatedAt = time.Now()
 
-	if err := h.db.Save(&room).Error; err != nil {
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockVersion(tx, &models.Room{}, room.ID, version); err != nil {
+			return err
+		}
+		joined, err := scheduling.MostJoined(tx, room.ID)
+		if err != nil {
+			return err
+		}
+		if joined > int64(room.Capacity) {
+			return scheduling.ErrRoomTooSmall
+		}
+		return tx.Save(&room).Error
+	})
+	if errors.Is(err, errStale) {
+		response.Error(c, http.StatusPreconditionFailed, staleMessage)
+		return
+	}
+	if errors.Is(err, scheduling.ErrRoomTooSmall) {
+		response.Error(c, http.StatusConflict, "Capacity is below the players who joined an upcoming reservation")
+		return
+	}
+	if validator.IsUniqueViolation(err) {
+		response.Error(c, http.StatusConflict, "Room name already exists")
+		return
+	}
+	if err != nil {
 		response.Error(c, http.StatusInternalServerError, "Failed to update room")
 		return
 	}
+	etag.Set(c, etag.Of(room.ID, room.UpdatedAt))
 	response.Success(c, room)
 }
 
 // DeleteRoomByID godoc
 // @Summary Delete a room by ID
-// @Description Deletes a room by its ID
+// @Description Soft-deletes a room and closes it. Its upcoming booked reservations are cancelled, or moved to the room given in reassign_to when they fit there. Players waiting for the room are taken off the waitlist, and everyone affected is notified.
 // @Tags rooms
 // @Produce json
 // @Param id path int true "Room ID"
+// @Param reassign_to query int false "Room to move upcoming reservations to"
+// @Param If-Match header string false "ETag of the version being deleted"
 // @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
 // @Failure 404 {object} response.Response
+// @Failure 412 {object} response.Response
+// @Failure 422 {object} response.Response
 // @Failure 500 {object} response.Response
+// @Security BearerAuth
 // @Router /rooms/{id} [delete]
 func (h *RoomHandler) DeleteRoomByID(c *gin.Context) {
+	validator.CheckQueryParam(c, map[string]bool{"reassign_to": true})
+	if c.IsAborted() {
+		return
+	}
+
 	id, err := validator.GetParamID(c)
 	if err != nil {
 		response.Error(c, http.StatusBadRequest, err.Error())
@@ -179,9 +464,257 @@ func (h *RoomHandler) DeleteRoomByID(c *gin.Context) {
 		response.Error(c, http.StatusInternalServerError, "Fail to fetch room by ID")
 		return
 	}
-	if err := h.db.Delete(&room, id).Error; err != nil {
+	if !ifMatch(c, room.ID, room.UpdatedAt) {
+		return
+	}
+
+	var target *models.Room
+	if value := c.Query("reassign_to"); value != "" {
+		targetID, err := strconv.ParseUint(value, 10, 64)
+		if err != nil || targetID == id {
+			response.Error(c, http.StatusBadRequest, "reassign_to must be the ID of another room")
+			return
+		}
+		target, err = validator.FindRoomByID(h.db, targetID)
+		if err != nil {
+			if err.Error() == "room not found" {
+				response.Error(c, http.StatusUnprocessableEntity, "Room to reassign to not found")
+				return
+			}
+			response.Error(c, http.StatusInternalServerError, "Fail to fetch room by ID")
+			return
+		}
+		if !target.Bookable() {
+			response.Error(c, http.StatusUnprocessableEntity, "Room to reassign to is not taking reservations")
+			return
+		}
+	}
+
+	var moved, cancelled []uint
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := lockVersion(tx, &models.Room{}, room.ID, room.UpdatedAt); err != nil {
+			return err
+		}
+		var err error
+		moved, cancelled, err = scheduling.VacateRoom(tx, room, target, middleware.CurrentPrincipal(c).PlayerID, now)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(room).Updates(map[string]interface{}{"status": models.RoomClosed, "updated_at": now}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(room).Error
+	})
+	if errors.Is(err, errStale) {
+		response.Error(c, http.StatusPreconditionFailed, staleMessage)
+		return
+	}
+	if err != nil {
 		response.Error(c, http.StatusInternalServerError, "Fail to delete room")
 		return
 	}
-	response.Success(c, gin.H{"message": fmt.Sprintf("Room %d is deleted successfully", id)})
+	response.Success(c, gin.H{
+		"message":                   fmt.Sprintf("Room %d is deleted successfully", id),
+		"moved_reservation_ids":     moved,
+		"cancelled_reservation_ids": cancelled,
+	})
+}
+
+// GetDeletedRooms godoc
+// @Summary List deleted rooms
+// @Description Lists soft-deleted rooms that can still be restored or purged
+// @Tags rooms
+// @Produce json
+// @Param limit query int false "Page size, 1 to 100, defaults to 20"
+// @Param offset query int false "Number of rooms to skip"
+// @Success 200 {object} response.Page{items=[]models.Room}
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /rooms/deleted [get]
+func (h *RoomHandler) GetDeletedRooms(c *gin.Context) {
+	allowedParams := map[string]bool{
+		"limit":  true,
+		"offset": true,
+	}
+
+	validator.CheckQueryParam(c, allowedParams)
+
+	if c.IsAborted() {
+		return
+	}
+
+	limit, offset, err := validator.GetPagination(c, 20, 100)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query := h.db.Unscoped().Model(&models.Room{}).Where("deleted_at IS NOT NULL")
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Failed to fetch deleted rooms")
+		return
+	}
+
+	rooms := []models.Room{}
+	if err := query.Order("deleted_at desc").Limit(limit).Offset(offset).Find(&rooms).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Failed to fetch deleted rooms")
+		return
+	}
+
+	response.Success(c, response.Page{
+		Items:  rooms,
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	})
+}
+
+// RestoreRoom godoc
+// @Summary Restore a deleted room
+// @Description Restores a soft-deleted room, a new name can be given when the old one has been taken in the meantime
+// @Tags rooms
+// @Accept json
+// @Produce json
+// @Param id path int true "Room ID"
+// @Param room body validator.RestoreValidation false "Optional new name"
+// @Success 200 {object} models.Room
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /rooms/{id}/restore [post]
+func (h *RoomHandler) RestoreRoom(c *gin.Context) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var input validator.RestoreValidation
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			response.Error(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	var room models.Room
+	if err := h.db.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", id).First(&room).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			response.Error(c, http.StatusNotFound, "Deleted room not found")
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch room by ID")
+		return
+	}
+
+	if input.Name != "" {
+		room.Name = input.Name
+	}
+
+	if err := h.db.Unscoped().Model(&room).Updates(map[string]interface{}{
+		"name":       room.Name,
+		"deleted_at": nil,
+	}).Error; err != nil {
+		if validator.IsUniqueViolation(err) {
+			response.Error(c, http.StatusConflict, "Room name is taken, restore with a new name")
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "Failed to restore room")
+		return
+	}
+	room.DeletedAt = gorm.DeletedAt{}
+
+	response.Success(c, room)
+}
+
+// PurgeRoom godoc
+// @Summary Permanently erase a deleted room
+// @Description Hard deletes a soft-deleted room, rooms that still have reservations cannot be purged
+// @Tags rooms
+// @Produce json
+// @Param id path int true "Room ID"
+// @Success 200 {object} response.Response
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /rooms/{id}/purge [delete]
+func (h *RoomHandler) PurgeRoom(c *gin.Context) {
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var room models.Room
+	if err := h.db.Unscoped().First(&room, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			response.Error(c, http.StatusNotFound, "Room not found")
+			return
+		}
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch room by ID")
+		return
+	}
+	if !room.DeletedAt.Valid {
+		response.Error(c, http.StatusConflict, "Only deleted rooms can be purged")
+		return
+	}
+
+	var count int64
+	if err := h.db.Model(&models.Reservation{}).Where("room_id = ?", id).Count(&count).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to purge room")
+		return
+	}
+	if count > 0 {
+		response.Error(c, http.StatusConflict, "Room still has reservations and cannot be purged")
+		return
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("room_id = ?", room.ID).Delete(&models.MaintenanceWindow{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("owner_type = ? AND owner_id = ?", models.FeedRoom, room.ID).Delete(&models.CalendarFeed{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&room).Error
+	})
+	if err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to purge room")
+		return
+	}
+
+	response.Success(c, gin.H{"message": fmt.Sprintf("Room %d is purged successfully", id)})
+}
+
+// roomDefaults fills in the default capacity and opening hours and validates
+// the hours
+func roomDefaults(input *validator.RoomValidation) error {
+	if input.Status == "" {
+		input.Status = models.RoomAvailable
+	}
+	if input.Capacity == 0 {
+		input.Capacity = models.DefaultRoomCapacity
+	}
+	if input.OpensAt == "" {
+		input.OpensAt = scheduling.DefaultOpensAt
+	}
+	if input.ClosesAt == "" {
+		input.ClosesAt = scheduling.DefaultClosesAt
+	}
+	if input.Timezone == "" {
+		input.Timezone = scheduling.DefaultTimezone
+	}
+	_, err := scheduling.ParseHours(input.OpensAt, input.ClosesAt, input.Timezone)
+	return err
 }
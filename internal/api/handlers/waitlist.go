@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"oxo-game-api/internal/api/middleware"
+	"oxo-game-api/internal/models"
+	"oxo-game-api/internal/scheduling"
+	"oxo-game-api/pkg/utils/response"
+	"oxo-game-api/pkg/utils/validator"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GetWaitlist godoc
+// @Summary Get waitlist entries
+// @Description Lists waitlist entries in the order they will be promoted. Players without reservations:read only see their own.
+// @Tags reservations
+// @Produce json
+// @Param room_id query int false "Room ID"
+// @Param player_id query int false "Player ID"
+// @Param status query string false "waiting, offered, confirmed, expired or cancelled"
+// @Success 200 {array} models.WaitlistEntry
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /reservations/waitlist [get]
+func (h *ReservationHandler) GetWaitlist(c *gin.Context) {
+	validator.CheckQueryParam(c, map[string]bool{"room_id": true, "player_id": true, "status": true})
+	if c.IsAborted() {
+		return
+	}
+
+	playerID := c.Query("player_id")
+	principal := middleware.CurrentPrincipal(c)
+	if !principal.Can(middleware.PermReservationsRead) {
+		if playerID != "" && playerID != strconv.FormatUint(uint64(principal.PlayerID), 10) {
+			response.PermissionDenied(c, middleware.PermReservationsRead)
+			return
+		}
+		playerID = strconv.FormatUint(uint64(principal.PlayerID), 10)
+	}
+
+	query := h.db.Model(&models.WaitlistEntry{})
+	if roomID := c.Query("room_id"); roomID != "" {
+		query = query.Where("room_id = ?", roomID)
+	}
+	if playerID != "" {
+		query = query.Where("player_id = ?", playerID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	entries := []models.WaitlistEntry{}
+	if err := query.Order("starts_at, priority desc, created_at, id").Find(&entries).Error; err != nil {
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch waitlist")
+		return
+	}
+	response.Success(c, entries)
+}
+
+// ConfirmWaitlistOffer godoc
+// @Summary Confirm a reservation offered from the waitlist
+// @Description Keeps the reservation made for the player when they were promoted. Offers not confirmed by offer_expires_at are cancelled and passed on.
+// @Tags reservations
+// @Produce json
+// @Param id path int true "Waitlist entry ID"
+// @Success 200 {object} models.WaitlistEntry
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /reservations/waitlist/{id}/confirm [post]
+func (h *ReservationHandler) ConfirmWaitlistOffer(c *gin.Context) {
+	entry, ok := h.findWaitlistEntry(c)
+	if !ok {
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&entry, entry.ID).Error; err != nil {
+			return err
+		}
+		return scheduling.ConfirmOffer(tx, &entry, time.Now())
+	})
+	if err != nil {
+		scheduleError(c, err, "Fail to confirm waitlist offer")
+		return
+	}
+	response.Success(c, entry)
+}
+
+// LeaveWaitlist godoc
+// @Summary Leave the waitlist
+// @Description Takes the entry off the waitlist. A pending offer is declined and passed on to the next player.
+// @Tags reservations
+// @Produce json
+// @Param id path int true "Waitlist entry ID"
+// @Success 200 {object} models.WaitlistEntry
+// @Failure 400 {object} response.Response
+// @Failure 403 {object} response.Response
+// @Failure 404 {object} response.Response
+// @Failure 409 {object} response.Response
+// @Failure 500 {object} response.Response
+// @Security BearerAuth
+// @Router /reservations/waitlist/{id} [delete]
+func (h *ReservationHandler) LeaveWaitlist(c *gin.Context) {
+	entry, ok := h.findWaitlistEntry(c)
+	if !ok {
+		return
+	}
+
+	principal := middleware.CurrentPrincipal(c)
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&entry, entry.ID).Error; err != nil {
+			return err
+		}
+		return scheduling.LeaveWaitlist(tx, &entry, principal.PlayerID, h.cfg.WaitlistConfirmWindow, time.Now())
+	})
+	if err != nil {
+		scheduleError(c, err, "Fail to leave waitlist")
+		return
+	}
+	response.Success(c, entry)
+}
+
+// joinWaitlist queues the player for a slot that turned out to be taken
+func (h *ReservationHandler) joinWaitlist(c *gin.Context, input validator.ReservationValidation, slot scheduling.Interval) {
+	entry := models.WaitlistEntry{
+		RoomID:    input.RoomID,
+		PlayerID:  input.PlayerID,
+		StartsAt:  slot.Start,
+		EndsAt:    slot.End,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if h.cfg.WaitlistLevelPriority {
+			priority, err := scheduling.LevelPriority(tx, entry.PlayerID)
+			if err != nil {
+				return err
+			}
+			entry.Priority = priority
+		}
+		return scheduling.JoinWaitlist(tx, &entry)
+	})
+	if err != nil {
+		scheduleError(c, err, "Fail to join waitlist")
+		return
+	}
+	c.JSON(http.StatusAccepted, response.Response{
+		Code:    http.StatusAccepted,
+		Message: "Slot is taken, you are on the waitlist",
+		Data:    entry,
+	})
+}
+
+// findWaitlistEntry loads the entry named by the id parameter and checks the
+// caller owns it or holds reservations:write. It answers the request itself
+// on failure.
+func (h *ReservationHandler) findWaitlistEntry(c *gin.Context) (models.WaitlistEntry, bool) {
+	var entry models.WaitlistEntry
+
+	id, err := validator.GetParamID(c)
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, err.Error())
+		return entry, false
+	}
+
+	if err := h.db.First(&entry, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Error(c, http.StatusNotFound, "waitlist entry not found")
+			return entry, false
+		}
+		response.Error(c, http.StatusInternalServerError, "Fail to fetch waitlist entry")
+		return entry, false
+	}
+
+	if !middleware.CurrentPrincipal(c).CanActFor(entry.PlayerID, middleware.PermReservationsWrite) {
+		response.PermissionDenied(c, middleware.PermReservationsWrite)
+		return entry, false
+	}
+	return entry, true
+}
+
+// slotTaken reports whether err means another reservation holds the slot
+func slotTaken(err error) bool {
+	var conflict *scheduling.ConflictError
+	return errors.As(err, &conflict) || scheduling.IsOverlapViolation(err)
+}
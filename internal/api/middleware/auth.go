@@ -0,0 +1,192 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"oxo-game-api/internal/models"
+	"oxo-game-api/pkg/utils/response"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	principalKey = "principal"
+
+	// APIKeyHeader carries machine credentials for game servers and service clients
+	APIKeyHeader = "X-API-Key"
+	apiKeyPrefix = "oxo_"
+
+	// lastUsedPrecision throttles writes of APIKey.LastUsedAt for busy keys
+	lastUsedPrecision = time.Minute
+)
+
+// appealRoutes are the only routes an appeal only session may call
+var appealRoutes = map[string]bool{
+	"GET /players/:id/sanctions": true,
+	"POST /sanctions/:id/appeal": true,
+	"POST /auth/logout":          true,
+}
+
+// Principal is the authenticated caller of a request, either a logged in
+// player or an API key acting with its scopes
+type Principal struct {
+	PlayerID uint
+	Role     string
+	APIKeyID uint
+	Scopes   map[string]bool
+}
+
+// HashToken returns the hex encoded sha256 of a raw token, only hashes are stored
+func HashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// FormatAPIKey builds the raw key handed out to clients, the prefix is stored
+// in clear text to look the key up
+func FormatAPIKey(prefix, secret string) string {
+	return apiKeyPrefix + prefix + "_" + secret
+}
+
+// ParseAPIKeyPrefix extracts the lookup prefix from a raw key
+func ParseAPIKeyPrefix(raw string) (string, bool) {
+	rest, found := strings.CutPrefix(raw, apiKeyPrefix)
+	if !found {
+		return "", false
+	}
+	prefix, secret, found := strings.Cut(rest, "_")
+	if !found || prefix == "" || secret == "" {
+		return "", false
+	}
+	return prefix, true
+}
+
+// Authenticate resolves a player bearer token or a scoped API key into a
+// principal and rejects anonymous requests
+func Authenticate(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if key := c.GetHeader(APIKeyHeader); key != "" {
+			authenticateAPIKey(c, db, key)
+			return
+		}
+
+		header := c.GetHeader("Authorization")
+		raw, found := strings.CutPrefix(header, "Bearer ")
+		if !found || raw == "" {
+			response.Error(c, http.StatusUnauthorized, "Authentication required")
+			c.Abort()
+			return
+		}
+
+		var token models.AuthToken
+		err := db.Preload("Player").
+			Where("token_hash = ? AND expires_at > ?", HashToken(raw), time.Now()).
+			First(&token).Error
+		if err != nil || token.Player == nil {
+			response.Error(c, http.StatusUnauthorized, "Invalid or expired token")
+			c.Abort()
+			return
+		}
+		if token.AppealOnly && !appealRoutes[c.Request.Method+" "+c.FullPath()] {
+			response.Error(c, http.StatusForbidden, "This session can only read and appeal sanctions")
+			c.Abort()
+			return
+		}
+
+		c.Set(principalKey, &Principal{
+			PlayerID: token.PlayerID,
+			Role:     token.Player.Role,
+		})
+		c.Next()
+	}
+}
+
+// AuthenticateFeed lets calendar apps read the feed of the ownerType named
+// by the id parameter with the token in its URL. The request then acts as
+// the player who created the token. Requests without a token are
+// authenticated by Authenticate.
+func AuthenticateFeed(db *gorm.DB, ownerType string) gin.HandlerFunc {
+	authenticate := Authenticate(db)
+	return func(c *gin.Context) {
+		raw := c.Query("token")
+		if raw == "" {
+			authenticate(c)
+			return
+		}
+
+		var feed models.CalendarFeed
+		err := db.Preload("Creator").
+			Where("token_hash = ? AND owner_type = ? AND owner_id = ? AND revoked_at IS NULL", HashToken(raw), ownerType, c.Param("id")).
+			First(&feed).Error
+		if err != nil || feed.Creator == nil {
+			response.Error(c, http.StatusUnauthorized, "Invalid or revoked calendar feed token")
+			c.Abort()
+			return
+		}
+
+		c.Set(principalKey, &Principal{
+			PlayerID: feed.CreatedBy,
+			Role:     feed.Creator.Role,
+		})
+		c.Next()
+	}
+}
+
+func authenticateAPIKey(c *gin.Context, db *gorm.DB, raw string) {
+	prefix, ok := ParseAPIKeyPrefix(raw)
+	if !ok {
+		response.Error(c, http.StatusUnauthorized, "Invalid API key")
+		c.Abort()
+		return
+	}
+
+	now := time.Now()
+	var key models.APIKey
+	if err := db.Where("prefix = ?", prefix).First(&key).Error; err != nil ||
+		subtle.ConstantTimeCompare([]byte(key.KeyHash), []byte(HashToken(raw))) != 1 ||
+		!key.Active(now) {
+		response.Error(c, http.StatusUnauthorized, "Invalid or expired API key")
+		c.Abort()
+		return
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > lastUsedPrecision {
+		if err := db.Model(&key).UpdateColumn("last_used_at", now).Error; err != nil {
+			log.Printf("Error tracking API key %d usage: %v", key.ID, err)
+		}
+	}
+
+	c.Set(principalKey, &Principal{
+		APIKeyID: key.ID,
+		Scopes:   permissionSet(SplitScopes(key.Scopes)),
+	})
+	c.Next()
+}
+
+// SplitScopes turns the stored comma separated scope list into a slice
+func SplitScopes(scopes string) []string {
+	var result []string
+	for _, scope := range strings.Split(scopes, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			result = append(result, scope)
+		}
+	}
+	return result
+}
+
+// CurrentPrincipal returns the caller set by Authenticate, nil on public routes
+func CurrentPrincipal(c *gin.Context) *Principal {
+	value, ok := c.Get(principalKey)
+	if !ok {
+		return nil
+	}
+	principal, _ := value.(*Principal)
+	return principal
+}
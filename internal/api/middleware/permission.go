@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+
+	"oxo-game-api/internal/models"
+	"oxo-game-api/pkg/utils/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Permissions granted on top of the player's access to their own resources
+const (
+	PermPlayersRead       = "players:read"
+	PermPlayersWrite      = "players:write"
+	PermPlayersDelete     = "players:delete"
+	PermExperienceWrite   = "experience:write"
+	PermRolesWrite        = "roles:write"
+	PermLevelsWrite       = "levels:write"
+	PermRoomsWrite        = "rooms:write"
+	PermRoomsDelete       = "rooms:delete"
+	PermReservationsRead  = "reservations:read"
+	PermReservationsWrite = "reservations:write"
+	PermChallengesWrite   = "challenges:write"
+	PermLogsRead          = "logs:read"
+	PermLogsWrite         = "logs:write"
+	PermPaymentsRead      = "payments:read"
+	PermPaymentsWrite     = "payments:write"
+	PermAPIKeysManage     = "api_keys:manage"
+	PermLeaderboardsAdmin = "leaderboards:admin"
+	PermRecordsRecover    = "records:recover"
+	PermPrivacyManage     = "privacy:manage"
+	PermPlayersModerate   = "players:moderate"
+)
+
+var operatorPermissions = []string{
+	PermPlayersRead,
+	PermPlayersModerate,
+	PermRoomsWrite,
+	PermReservationsRead,
+	PermReservationsWrite,
+	PermLogsRead,
+	PermPaymentsRead,
+}
+
+var adminPermissions = append([]string{
+	PermPlayersWrite,
+	PermPlayersDelete,
+	PermExperienceWrite,
+	PermRolesWrite,
+	PermLevelsWrite,
+	PermRoomsDelete,
+	PermChallengesWrite,
+	PermLogsWrite,
+	PermPaymentsWrite,
+	PermAPIKeysManage,
+	PermLeaderboardsAdmin,
+	PermRecordsRecover,
+	PermPrivacyManage,
+}, operatorPermissions...)
+
+var rolePermissions = map[string]map[string]bool{
+	models.RolePlayer:   {},
+	models.RoleOperator: permissionSet(operatorPermissions),
+	models.RoleAdmin:    permissionSet(adminPermissions),
+}
+
+var knownPermissions = permissionSet(adminPermissions)
+
+// IsKnownPermission reports whether perm can be granted as an API key scope
+func IsKnownPermission(perm string) bool {
+	return knownPermissions[perm]
+}
+
+func permissionSet(perms []string) map[string]bool {
+	set := make(map[string]bool, len(perms))
+	for _, perm := range perms {
+		set[perm] = true
+	}
+	return set
+}
+
+// Can reports whether the principal holds the permission, through its role
+// for players or its scopes for API keys
+func (p *Principal) Can(perm string) bool {
+	if p == nil {
+		return false
+	}
+	if p.APIKeyID != 0 {
+		return p.Scopes[perm]
+	}
+	return rolePermissions[p.Role][perm]
+}
+
+// CanActFor reports whether the principal may touch resources owned by playerID
+func (p *Principal) CanActFor(playerID uint, perm string) bool {
+	if p == nil {
+		return false
+	}
+	return (p.PlayerID != 0 && p.PlayerID == playerID) || p.Can(perm)
+}
+
+// RequirePermission only lets principals holding perm through
+func RequirePermission(perm string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !CurrentPrincipal(c).Can(perm) {
+			response.PermissionDenied(c, perm)
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireSelfOrPermission lets a player through for their own player ID in the
+// given path parameter, anybody else needs perm
+func RequireSelfOrPermission(param, perm string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, err := strconv.ParseUint(c.Param(param), 10, 64)
+		if err != nil {
+			response.Error(c, http.StatusBadRequest, "invalid ID")
+			c.Abort()
+			return
+		}
+
+		if !CurrentPrincipal(c).CanActFor(uint(id), perm) {
+			response.PermissionDenied(c, perm)
+			return
+		}
+		c.Next()
+	}
+}
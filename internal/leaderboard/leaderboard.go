@@ -0,0 +1,234 @@
+// Package leaderboard keeps materialized per-window player scores so ranked
+// views stay a single indexed query however many challenges were played.
+package leaderboard
+
+import (
+	"time"
+
+	"oxo-game-api/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	KindExperience = "experience"
+	KindWins       = "wins"
+	KindWinnings   = "winnings"
+	KindEntries    = "entries"
+
+	WindowDaily   = "daily"
+	WindowWeekly  = "weekly"
+	WindowAllTime = "all_time"
+)
+
+var Kinds = map[string]bool{
+	KindExperience: true,
+	KindWins:       true,
+	KindWinnings:   true,
+	KindEntries:    true,
+}
+
+var Windows = []string{WindowDaily, WindowWeekly, WindowAllTime}
+
+// Entry is a ranked row of a leaderboard
+type Entry struct {
+	Rank       int64   `json:"rank"`
+	PlayerID   uint    `json:"player_id"`
+	PlayerName string  `json:"player_name"`
+	Score      float64 `json:"score"`
+}
+
+// PeriodStart returns the start of the window period containing t, periods
+// are aligned on UTC days and ISO weeks starting on Monday
+func PeriodStart(window string, t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch window {
+	case WindowDaily:
+		return day
+	case WindowWeekly:
+		offset := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -offset)
+	default:
+		return time.Unix(0, 0).UTC()
+	}
+}
+
+// IsValidWindow reports whether window is daily, weekly or all_time
+func IsValidWindow(window string) bool {
+	for _, w := range Windows {
+		if w == window {
+			return true
+		}
+	}
+	return false
+}
+
+// Increment adds delta to the player's score in every window of kind
+func Increment(db *gorm.DB, kind string, playerID uint, delta float64, at time.Time) error {
+	for _, window := range Windows {
+		if err := upsert(db, kind, window, playerID, at, gorm.Expr("leaderboard_entries.score + ?", delta), delta); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordExperience keeps the experience board in line after an award, the
+// all-time board holds the total while daily and weekly boards hold the
+// experience earned during the period
+func RecordExperience(db *gorm.DB, playerID uint, awarded int, total uint, at time.Time) error {
+	if err := upsert(db, KindExperience, WindowAllTime, playerID, at, float64(total), float64(total)); err != nil {
+		return err
+	}
+	if awarded <= 0 {
+		return nil
+	}
+	for _, window := range []string{WindowDaily, WindowWeekly} {
+		if err := upsert(db, KindExperience, window, playerID, at, gorm.Expr("leaderboard_entries.score + ?", awarded), float64(awarded)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func upsert(db *gorm.DB, kind, window string, playerID uint, at time.Time, update interface{}, initial float64) error {
+	entry := models.LeaderboardEntry{
+		Kind:        kind,
+		Window:      window,
+		PeriodStart: PeriodStart(window, at),
+		PlayerID:    playerID,
+		Score:       initial,
+		UpdatedAt:   time.Now(),
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "kind"}, {Name: "time_window"}, {Name: "period_start"}, {Name: "player_id"}},
+		DoUpdates: clause.Set{
+			{Column: clause.Column{Name: "score"}, Value: update},
+			{Column: clause.Column{Name: "updated_at"}, Value: entry.UpdatedAt},
+		},
+	}).Create(&entry).Error
+}
+
+// Top returns one page of the leaderboard ordered by score, ties go to the
+// player who reached the score first
+func Top(db *gorm.DB, kind, window string, at time.Time, limit, offset int) ([]Entry, int64, error) {
+	query := db.Model(&models.LeaderboardEntry{}).
+		Where("kind = ? AND time_window = ? AND period_start = ?", kind, window, PeriodStart(window, at))
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var rows []models.LeaderboardEntry
+	if err := query.Preload("Player").
+		Order("score desc, updated_at asc, player_id asc").
+		Limit(limit).Offset(offset).
+		Find(&rows).Error; err != nil {
+		return nil, 0, err
+	}
+
+	entries := make([]Entry, 0, len(rows))
+	for i, row := range rows {
+		entry := Entry{
+			Rank:     int64(offset + i + 1),
+			PlayerID: row.PlayerID,
+			Score:    row.Score,
+		}
+		if row.Player != nil {
+			entry.PlayerName = row.Player.Name
+		}
+		entries = append(entries, entry)
+	}
+	return entries, total, nil
+}
+
+// Rank returns the player's position on the leaderboard, nil when the player
+// has no score in the period yet
+func Rank(db *gorm.DB, kind, window string, at time.Time, playerID uint) (*Entry, error) {
+	periodStart := PeriodStart(window, at)
+
+	var row models.LeaderboardEntry
+	err := db.Preload("Player").
+		Where("kind = ? AND time_window = ? AND period_start = ? AND player_id = ?", kind, window, periodStart, playerID).
+		First(&row).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var ahead int64
+	err = db.Model(&models.LeaderboardEntry{}).
+		Where("kind = ? AND time_window = ? AND period_start = ?", kind, window, periodStart).
+		Where("score > ? OR (score = ? AND (updated_at < ? OR (updated_at = ? AND player_id < ?)))",
+			row.Score, row.Score, row.UpdatedAt, row.UpdatedAt, row.PlayerID).
+		Count(&ahead).Error
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &Entry{
+		Rank:     ahead + 1,
+		PlayerID: row.PlayerID,
+		Score:    row.Score,
+	}
+	if row.Player != nil {
+		entry.PlayerName = row.Player.Name
+	}
+	return entry, nil
+}
+
+// Rebuild recomputes the challenge boards of the current periods and the
+// all-time experience board from the source tables. Daily and weekly
+// experience boards cannot be rebuilt since single awards are not stored.
+func Rebuild(db *gorm.DB, at time.Time) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, window := range Windows {
+			periodStart := PeriodStart(window, at)
+
+			if err := tx.Where("kind IN ? AND time_window = ? AND period_start = ?",
+				[]string{KindEntries, KindWins, KindWinnings}, window, periodStart).
+				Delete(&models.LeaderboardEntry{}).Error; err != nil {
+				return err
+			}
+
+			sources := []struct {
+				kind  string
+				query string
+			}{
+				{KindEntries, "SELECT player_id, COUNT(*) AS score FROM challenges WHERE created_at >= ? GROUP BY player_id"},
+				{KindWins, "SELECT player_id, COUNT(*) AS score FROM challenge_results WHERE won AND created_at >= ? GROUP BY player_id"},
+				{KindWinnings, "SELECT player_id, SUM(prize) AS score FROM challenge_results WHERE won AND created_at >= ? GROUP BY player_id"},
+			}
+			for _, source := range sources {
+				var rows []struct {
+					PlayerID uint
+					Score    float64
+				}
+				if err := tx.Raw(source.query, periodStart).Scan(&rows).Error; err != nil {
+					return err
+				}
+				for _, row := range rows {
+					if err := upsert(tx, source.kind, window, row.PlayerID, at, row.Score, row.Score); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		var players []models.Player
+		if err := tx.Select("id", "experience").Find(&players).Error; err != nil {
+			return err
+		}
+		for _, player := range players {
+			if err := upsert(tx, KindExperience, WindowAllTime, player.ID, at, float64(player.Experience), float64(player.Experience)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+)
+
+// AuthToken is a player session. Sanctioned players get appeal only sessions,
+// which can just read and appeal their sanctions.
+type AuthToken struct {
+	ID         uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	PlayerID   uint      `json:"player_id" gorm:"not null;index"`
+	Player     *Player   `json:"player,omitempty" gorm:"foreignKey:PlayerID"`
+	TokenHash  string    `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	AppealOnly bool      `json:"appeal_only" gorm:"not null;default:false"`
+	ExpiresAt  time.Time `json:"expires_at" gorm:"not null"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type APIKey struct {
+	ID           uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	Name         string     `json:"name" gorm:"size:64;not null"`
+	Prefix       string     `json:"prefix" gorm:"size:16;not null;uniqueIndex"`
+	KeyHash      string     `json:"-" gorm:"size:64;not null"`
+	Scopes       string     `json:"scopes" gorm:"type:text;not null"`
+	CreatedBy    uint       `json:"created_by" gorm:"not null"`
+	ReplacedByID *uint      `json:"replaced_by_id"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	LastUsedAt   *time.Time `json:"last_used_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// Active reports whether the key can still authenticate at the given time
+func (k *APIKey) Active(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || k.ExpiresAt.After(now)
+}
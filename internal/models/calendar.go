@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Owners of calendar feeds
+const (
+	FeedRoom   = "room"
+	FeedPlayer = "player"
+)
+
+// CalendarFeed is a secret token that lets calendar apps subscribe to the
+// iCalendar feed of a room or player without logging in. The feed is read
+// with the permissions of the player who created the token.
+type CalendarFeed struct {
+	ID        uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	OwnerType string     `json:"owner_type" gorm:"size:10;not null;index:idx_calendar_feeds_owner"`
+	OwnerID   uint       `json:"owner_id" gorm:"not null;index:idx_calendar_feeds_owner"`
+	TokenHash string     `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	CreatedBy uint       `json:"created_by" gorm:"not null;index"`
+	Creator   *Player    `json:"-" gorm:"foreignKey:CreatedBy"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
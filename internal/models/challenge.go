@@ -19,5 +19,6 @@ type ChallengeResult struct {
 	PlayerID    uint       `json:"player_id" gorm:"not null"`
 	Player      *Player    `json:"player" gorm:"foreignKey:PlayerID"`
 	Won         bool       `json:"won" gorm:"not null"`
+	Prize       float64    `json:"prize" gorm:"not null;default:0"`
 	CreatedAt   time.Time  `json:"created_at"`
 }
\ No newline at end of file
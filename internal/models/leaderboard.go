@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+// LeaderboardEntry is one player's materialized score on a leaderboard for a
+// given window period, all-time entries use the unix epoch as period start
+type LeaderboardEntry struct {
+	ID          uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	Kind        string    `json:"kind" gorm:"size:20;not null;uniqueIndex:idx_leaderboard_player;index:idx_leaderboard_rank,priority:1"`
+	Window      string    `json:"window" gorm:"column:time_window;size:20;not null;uniqueIndex:idx_leaderboard_player;index:idx_leaderboard_rank,priority:2"`
+	PeriodStart time.Time `json:"period_start" gorm:"not null;uniqueIndex:idx_leaderboard_player;index:idx_leaderboard_rank,priority:3"`
+	PlayerID    uint      `json:"player_id" gorm:"not null;uniqueIndex:idx_leaderboard_player"`
+	Player      *Player   `json:"player,omitempty" gorm:"foreignKey:PlayerID"`
+	Score       float64   `json:"score" gorm:"not null;default:0;index:idx_leaderboard_rank,priority:4,sort:desc"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
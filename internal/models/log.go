@@ -4,10 +4,41 @@ import (
 	"time"
 )
 
+// Game log actions
+const (
+	ActionRegister         = "註冊"
+	ActionLogin            = "登入"
+	ActionLogout           = "登出"
+	ActionEnterRoom        = "進入房間"
+	ActionLeaveRoom        = "退出房間"
+	ActionJoinChallenge    = "參加挑戰"
+	ActionChallengeResult  = "挑戰結果"
+	ActionSanctionIssued   = "處分"
+	ActionSanctionRevoked  = "撤銷處分"
+	ActionSanctionAppealed = "處分申訴"
+	ActionAppealDecided    = "申訴裁決"
+)
+
+// LogActions lists every action a game log can record
+var LogActions = []string{
+	ActionRegister,
+	ActionLogin,
+	ActionLogout,
+	ActionEnterRoom,
+	ActionLeaveRoom,
+	ActionJoinChallenge,
+	ActionChallengeResult,
+	ActionSanctionIssued,
+	ActionSanctionRevoked,
+	ActionSanctionAppealed,
+	ActionAppealDecided,
+}
+
 type GameLog struct {
 	ID        uint      `json:"id" gorm:"primaryKey;autoIncrement"`
 	PlayerID  uint      `json:"player_id" gorm:"not null"`
 	Action    string    `json:"action" gorm:"not null"`
 	Timestamp time.Time `json:"timestamp" gorm:"not null"`
 	Details   string    `json:"details" gorm:"type:text"`
+	RoomID    *uint     `json:"room_id,omitempty" gorm:"index"`
 }
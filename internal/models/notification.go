@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// Notification is a message in a player's in-app inbox. EventID is the outbox
+// event it was made from, so a redelivered event does not show up twice.
+type Notification struct {
+	ID        uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	PlayerID  uint       `json:"player_id" gorm:"not null;index:idx_notifications_player_read"`
+	EventID   uint       `json:"event_id" gorm:"not null;uniqueIndex"`
+	Type      string     `json:"type" gorm:"size:64;not null"`
+	Title     string     `json:"title" gorm:"size:255;not null"`
+	Body      string     `json:"body" gorm:"type:text;not null"`
+	Data      string     `json:"data" gorm:"type:jsonb;not null;default:'{}'"`
+	ReadAt    *time.Time `json:"read_at,omitempty" gorm:"index:idx_notifications_player_read"`
+	CreatedAt time.Time  `json:"created_at"`
+}
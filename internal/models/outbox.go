@@ -0,0 +1,34 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// OutboxEvent is an event written in the same transaction as the change it
+// describes and delivered afterwards by the outbox relay. Delivered lists the
+// channels that already took it, comma separated, so a retry only goes to the
+// ones that failed. An event that keeps failing is given up at DeadAt.
+type OutboxEvent struct {
+	ID            uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	Type          string     `json:"type" gorm:"size:64;not null;index"`
+	PlayerID      uint       `json:"player_id" gorm:"not null;index"`
+	Payload       string     `json:"payload" gorm:"type:jsonb;not null"`
+	Attempts      int        `json:"attempts" gorm:"not null;default:0"`
+	LastError     string     `json:"last_error,omitempty" gorm:"size:255"`
+	Delivered     string     `json:"delivered,omitempty" gorm:"size:255;not null;default:''"`
+	NextAttemptAt time.Time  `json:"next_attempt_at" gorm:"not null;default:CURRENT_TIMESTAMP;index"`
+	PublishedAt   *time.Time `json:"published_at,omitempty" gorm:"index"`
+	DeadAt        *time.Time `json:"dead_at,omitempty" gorm:"index"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// DeliveredTo reports whether the channel already took the event
+func (e *OutboxEvent) DeliveredTo(channel string) bool {
+	for _, name := range strings.Split(e.Delivered, ",") {
+		if name == channel {
+			return true
+		}
+	}
+	return false
+}
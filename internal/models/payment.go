@@ -17,6 +17,7 @@ const (
 
 type Payment struct {
 	ID            uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	PlayerID      uint      `json:"player_id" gorm:"index"`
 	Method        string    `json:"method" gorm:"not null"`
 	Amount        float64   `json:"amount" gorm:"not null"`
 	Details       string    `json:"details" gorm:"type:text"`
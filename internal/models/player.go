@@ -6,15 +6,24 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	RolePlayer   = "player"
+	RoleOperator = "operator"
+	RoleAdmin    = "admin"
+)
+
 type Player struct {
-	ID        uint           `json:"id" gorm:"primaryKey"`
-	Name      string         `json:"name" gorm:"size:32;not null;unique"`
-	LevelID   uint           `json:"level_id" gorm:"not null;default:1"`
-	Level     *Level         `json:"level" gorm:"foreignKey:LevelID"`
-	Balance   float64        `json:"balance" gorm:"not null;default:0"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
+	ID           uint           `json:"id" gorm:"primaryKey"`
+	Name         string         `json:"name" gorm:"size:32;not null;uniqueIndex:idx_players_name_active,where:deleted_at IS NULL"`
+	LevelID      uint           `json:"level_id" gorm:"not null;default:1"`
+	Level        *Level         `json:"level" gorm:"foreignKey:LevelID"`
+	Balance      float64        `json:"balance" gorm:"not null;default:0"`
+	Experience   uint           `json:"experience" gorm:"not null;default:0"`
+	Role         string         `json:"role" gorm:"size:20;not null;default:'player'"`
+	PasswordHash string         `json:"-" gorm:"size:255"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
 }
 
 type Level struct {
@@ -28,6 +37,17 @@ type Level struct {
 	DeletedAt   gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
 }
 
+// LevelChange records every promotion or demotion of a player
+type LevelChange struct {
+	ID          uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	PlayerID    uint      `json:"player_id" gorm:"not null;index"`
+	FromLevelID uint      `json:"from_level_id" gorm:"not null"`
+	ToLevelID   uint      `json:"to_level_id" gorm:"not null"`
+	Experience  uint      `json:"experience" gorm:"not null"`
+	Reason      string    `json:"reason" gorm:"size:255"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
 func (Player) TableName() string {
 	return "players"
 }
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	ErasurePending   = "pending"
+	ErasureRunning   = "running"
+	ErasureCompleted = "completed"
+	ErasureFailed    = "failed"
+)
+
+// ErasureJob tracks the anonymization of a player's personal data
+type ErasureJob struct {
+	ID          uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	PlayerID    uint       `json:"player_id" gorm:"not null;index"`
+	RequestedBy uint       `json:"requested_by" gorm:"not null"`
+	Status      string     `json:"status" gorm:"size:20;not null;default:'pending'"`
+	Error       string     `json:"error,omitempty" gorm:"type:text"`
+	StartedAt   *time.Time `json:"started_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
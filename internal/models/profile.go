@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+)
+
+// Profile defaults for players who never edited their profile
+const (
+	DefaultLocale   = "en"
+	DefaultTimezone = "UTC"
+)
+
+// NotificationPreferences lists what a player wants to be notified about
+type NotificationPreferences struct {
+	ReservationReminders bool `json:"reservation_reminders" gorm:"not null"`
+	ChallengeResults     bool `json:"challenge_results" gorm:"not null"`
+	PaymentUpdates       bool `json:"payment_updates" gorm:"not null"`
+	FriendRequests       bool `json:"friend_requests" gorm:"not null"`
+}
+
+// PlayerProfile holds the presentation and preferences of a player, apart
+// from the unique name used as their handle
+type PlayerProfile struct {
+	PlayerID      uint                    `json:"player_id" gorm:"primaryKey;autoIncrement:false"`
+	DisplayName   string                  `json:"display_name" gorm:"size:64"`
+	AvatarURL     string                  `json:"avatar_url" gorm:"size:512"`
+	Locale        string                  `json:"locale" gorm:"size:35;not null"`
+	Timezone      string                  `json:"timezone" gorm:"size:64;not null"`
+	Email         string                  `json:"email" gorm:"size:254"`
+	Notifications NotificationPreferences `json:"notifications" gorm:"embedded;embeddedPrefix:notify_"`
+	CreatedAt     time.Time               `json:"created_at"`
+	UpdatedAt     time.Time               `json:"updated_at"`
+}
+
+// PublicProfile is the part of a profile visible to every player
+type PublicProfile struct {
+	PlayerID    uint   `json:"player_id"`
+	DisplayName string `json:"display_name"`
+	AvatarURL   string `json:"avatar_url"`
+}
+
+// DefaultProfile returns the profile of a player who never edited it
+func DefaultProfile(playerID uint) PlayerProfile {
+	return PlayerProfile{
+		PlayerID: playerID,
+		Locale:   DefaultLocale,
+		Timezone: DefaultTimezone,
+		Notifications: NotificationPreferences{
+			ReservationReminders: true,
+			ChallengeResults:     true,
+			PaymentUpdates:       true,
+			FriendRequests:       true,
+		},
+	}
+}
+
+// Public strips the preferences from the profile
+func (p *PlayerProfile) Public() PublicProfile {
+	return PublicProfile{
+		PlayerID:    p.PlayerID,
+		DisplayName: p.DisplayName,
+		AvatarURL:   p.AvatarURL,
+	}
+}
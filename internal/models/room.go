@@ -6,23 +6,151 @@ import (
 	"gorm.io/gorm"
 )
 
+// DefaultRoomCapacity is how many players a room holds unless set otherwise
+const DefaultRoomCapacity = 4
+
 type Room struct {
 	ID          uint           `json:"id" gorm:"primaryKey;autoIncrement"`
-	Name        string         `json:"name" gorm:"size:32;not null;unique"`
+	Name        string         `json:"name" gorm:"size:32;not null;uniqueIndex:idx_rooms_name_active,where:deleted_at IS NULL"`
 	Description string         `json:"description" gorm:"size:255"`
 	Status      string         `json:"status" gorm:"size:20;not null;default:'available'"`
+	Capacity    int            `json:"capacity" gorm:"not null;default:4"`
+	OpensAt     string         `json:"opens_at" gorm:"size:5;not null;default:'00:00'"`
+	ClosesAt    string         `json:"closes_at" gorm:"size:5;not null;default:'24:00'"`
+	Timezone    string         `json:"timezone" gorm:"size:64;not null;default:'UTC'"`
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
 }
 
-type Reservation struct {
+const (
+	RoomAvailable   = "available"
+	RoomOccupied    = "occupied"
+	RoomMaintenance = "maintenance"
+	RoomClosed      = "closed"
+)
+
+// RoomStatuses are the states a room can be in
+var RoomStatuses = []string{RoomAvailable, RoomOccupied, RoomMaintenance, RoomClosed}
+
+// Bookable reports whether the room takes reservations in its current status,
+// a room in use right now can still be booked for later
+func (r *Room) Bookable() bool {
+	return r.Status == RoomAvailable || r.Status == RoomOccupied
+}
+
+// MaintenanceWindow blocks a room from being booked
+type MaintenanceWindow struct {
 	ID        uint      `json:"id" gorm:"primaryKey;autoIncrement"`
-	RoomID    uint      `json:"room_id" gorm:"not null"`
-	Room      *Room     `json:"room" gorm:"foreignKey:RoomID"`
-	Date      string    `json:"date" gorm:"not null"`
-	Time      string    `json:"time" gorm:"not null"`
-	PlayerID  uint      `json:"player_id" gorm:"not null"`
+	RoomID    uint      `json:"room_id" gorm:"not null;index"`
+	StartsAt  time.Time `json:"starts_at" gorm:"type:timestamptz;not null"`
+	EndsAt    time.Time `json:"ends_at" gorm:"type:timestamptz;not null"`
+	Reason    string    `json:"reason" gorm:"size:255"`
+	CreatedBy uint      `json:"created_by" gorm:"not null"`
 	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+}
+
+const (
+	ReservationBooked    = "booked"
+	ReservationCheckedIn = "checked_in"
+	ReservationCompleted = "completed"
+	ReservationCancelled = "cancelled"
+	ReservationNoShow    = "no_show"
+)
+
+// ActiveReservationStatuses are the statuses that hold the room for the slot
+var ActiveReservationStatuses = []string{ReservationBooked, ReservationCheckedIn, ReservationCompleted}
+
+type Reservation struct {
+	ID           uint                     `json:"id" gorm:"primaryKey;autoIncrement"`
+	RoomID       uint                     `json:"room_id" gorm:"not null"`
+	Room         *Room                    `json:"room" gorm:"foreignKey:RoomID"`
+	StartsAt     time.Time                `json:"starts_at" gorm:"type:timestamptz;not null;index"`
+	EndsAt       time.Time                `json:"ends_at" gorm:"type:timestamptz;not null"`
+	PlayerID     uint                     `json:"player_id" gorm:"not null"`
+	Status       string                   `json:"status" gorm:"size:20;not null;default:'booked';index"`
+	CheckedInAt  *time.Time               `json:"checked_in_at,omitempty"`
+	CancelledAt  *time.Time               `json:"cancelled_at,omitempty"`
+	CancelReason string                   `json:"cancel_reason,omitempty" gorm:"size:255"`
+	SeriesID     *uint                    `json:"series_id,omitempty" gorm:"index"`
+	Sequence     int                      `json:"sequence" gorm:"not null;default:0"`
+	RemindedFor  *time.Time               `json:"-" gorm:"type:timestamptz"`
+	Participants []ReservationParticipant `json:"participants,omitempty" gorm:"foreignKey:ReservationID"`
+	CreatedAt    time.Time                `json:"created_at"`
+	UpdatedAt    time.Time                `json:"updated_at"`
+}
+
+// ReservationSeries is a recurring reservation. Each occurrence is a
+// Reservation of its own pointing back to the series.
+type ReservationSeries struct {
+	ID              uint          `json:"id" gorm:"primaryKey;autoIncrement"`
+	RoomID          uint          `json:"room_id" gorm:"not null;index"`
+	PlayerID        uint          `json:"player_id" gorm:"not null;index"`
+	RRule           string        `json:"rrule" gorm:"column:rrule;size:255;not null"`
+	StartsAt        time.Time     `json:"starts_at" gorm:"type:timestamptz;not null"`
+	DurationMinutes int           `json:"duration_minutes" gorm:"not null"`
+	Reservations    []Reservation `json:"reservations,omitempty" gorm:"foreignKey:SeriesID"`
+	CreatedAt       time.Time     `json:"created_at"`
+	UpdatedAt       time.Time     `json:"updated_at"`
+}
+
+// ReservationTransition records a change of a reservation's status
+type ReservationTransition struct {
+	ID            uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	ReservationID uint      `json:"reservation_id" gorm:"not null;index"`
+	FromStatus    string    `json:"from_status" gorm:"size:20"`
+	ToStatus      string    `json:"to_status" gorm:"size:20;not null"`
+	ActorID       *uint     `json:"actor_id"`
+	Reason        string    `json:"reason" gorm:"size:255"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+const (
+	ParticipantHost    = "host"
+	ParticipantInvitee = "invitee"
+)
+
+const (
+	InvitationPending  = "pending"
+	InvitationAccepted = "accepted"
+	InvitationDeclined = "declined"
+)
+
+// ReservationParticipant is a player taking part in a reservation, either
+// the host who made it or a friend they invited
+type ReservationParticipant struct {
+	ID            uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	ReservationID uint       `json:"reservation_id" gorm:"not null;uniqueIndex:idx_participants_reservation_player"`
+	PlayerID      uint       `json:"player_id" gorm:"not null;uniqueIndex:idx_participants_reservation_player;index"`
+	Role          string     `json:"role" gorm:"size:20;not null;default:'invitee'"`
+	InvitedBy     uint       `json:"invited_by" gorm:"not null"`
+	Status        string     `json:"status" gorm:"size:20;not null;default:'pending'"`
+	RespondedAt   *time.Time `json:"responded_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+const (
+	WaitlistWaiting   = "waiting"
+	WaitlistOffered   = "offered"
+	WaitlistConfirmed = "confirmed"
+	WaitlistExpired   = "expired"
+	WaitlistCancelled = "cancelled"
+)
+
+// WaitlistEntry queues a player for a slot of a room that is already taken.
+// When the slot frees up a reservation is made for the player, who has until
+// OfferExpiresAt to confirm it.
+type WaitlistEntry struct {
+	ID             uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	RoomID         uint       `json:"room_id" gorm:"not null;index:idx_waitlist_room_status"`
+	PlayerID       uint       `json:"player_id" gorm:"not null;index"`
+	StartsAt       time.Time  `json:"starts_at" gorm:"type:timestamptz;not null"`
+	EndsAt         time.Time  `json:"ends_at" gorm:"type:timestamptz;not null"`
+	Priority       int        `json:"priority" gorm:"not null;default:0"`
+	Status         string     `json:"status" gorm:"size:20;not null;default:'waiting';index:idx_waitlist_room_status"`
+	ReservationID  *uint      `json:"reservation_id,omitempty"`
+	OfferExpiresAt *time.Time `json:"offer_expires_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
 }
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	SanctionBan        = "ban"
+	SanctionSuspension = "suspension"
+)
+
+const (
+	AppealNone     = "none"
+	AppealPending  = "pending"
+	AppealAccepted = "accepted"
+	AppealRejected = "rejected"
+)
+
+// Sanction restricts a player until it expires or is revoked. A nil
+// ExpiresAt makes it permanent.
+type Sanction struct {
+	ID           uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	PlayerID     uint       `json:"player_id" gorm:"not null;index"`
+	Kind         string     `json:"kind" gorm:"size:20;not null"`
+	Reason       string     `json:"reason" gorm:"type:text;not null"`
+	IssuedBy     uint       `json:"issued_by" gorm:"not null"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	RevokedBy    *uint      `json:"revoked_by"`
+	AppealStatus string     `json:"appeal_status" gorm:"size:20;not null;default:'none'"`
+	AppealNote   string     `json:"appeal_note" gorm:"type:text"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// Active reports whether the sanction still restricts the player at now
+func (s *Sanction) Active(now time.Time) bool {
+	if s.RevokedAt != nil {
+		return false
+	}
+	return s.ExpiresAt == nil || now.Before(*s.ExpiresAt)
+}
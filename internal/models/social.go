@@ -0,0 +1,67 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	FriendshipPending  = "pending"
+	FriendshipAccepted = "accepted"
+	FriendshipDeclined = "declined"
+	FriendshipBlocked  = "blocked"
+)
+
+// Visibility of a part of a player's social data
+const (
+	VisibilityPublic  = "public"
+	VisibilityFriends = "friends"
+	VisibilityPrivate = "private"
+)
+
+// Who may send a player friend requests
+const (
+	FriendRequestsEveryone = "everyone"
+	FriendRequestsNobody   = "nobody"
+)
+
+// Friendship links two players. There is at most one row per pair, whoever
+// sent the first request is the requester. BlockedBy is set while the pair
+// is blocked.
+type Friendship struct {
+	ID          uint       `json:"id" gorm:"primaryKey;autoIncrement"`
+	RequesterID uint       `json:"requester_id" gorm:"not null;uniqueIndex:idx_friendships_pair"`
+	AddresseeID uint       `json:"addressee_id" gorm:"not null;uniqueIndex:idx_friendships_pair;index"`
+	Status      string     `json:"status" gorm:"size:20;not null;default:'pending'"`
+	BlockedBy   *uint      `json:"blocked_by,omitempty"`
+	RespondedAt *time.Time `json:"responded_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// Other returns the player on the other side of the friendship
+func (f *Friendship) Other(playerID uint) uint {
+	if f.RequesterID == playerID {
+		return f.AddresseeID
+	}
+	return f.RequesterID
+}
+
+// PrivacySettings controls who can reach a player and see their social data.
+// Players without a row use DefaultPrivacySettings.
+type PrivacySettings struct {
+	PlayerID             uint      `json:"player_id" gorm:"primaryKey;autoIncrement:false"`
+	FriendRequests       string    `json:"friend_requests" gorm:"size:20;not null;default:'everyone'"`
+	FriendListVisibility string    `json:"friend_list_visibility" gorm:"size:20;not null;default:'friends'"`
+	PresenceVisibility   string    `json:"presence_visibility" gorm:"size:20;not null;default:'friends'"`
+	UpdatedAt            time.Time `json:"updated_at"`
+}
+
+// DefaultPrivacySettings returns the settings of a player who never changed them
+func DefaultPrivacySettings(playerID uint) PrivacySettings {
+	return PrivacySettings{
+		PlayerID:             playerID,
+		FriendRequests:       FriendRequestsEveryone,
+		FriendListVisibility: VisibilityFriends,
+		PresenceVisibility:   VisibilityFriends,
+	}
+}
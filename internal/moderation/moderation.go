@@ -0,0 +1,157 @@
+// Package moderation issues, revokes and appeals player sanctions and tells
+// the rest of the API whether a player is currently restricted. Every change
+// is written to the game log.
+package moderation
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"oxo-game-api/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrSanctionNotFound = errors.New("sanction not found")
+	ErrAlreadyRevoked   = errors.New("sanction is already revoked")
+	ErrAppealExists     = errors.New("sanction has already been appealed")
+	ErrNoPendingAppeal  = errors.New("sanction has no pending appeal")
+)
+
+// ActiveSanction returns the sanction currently restricting the player, the
+// longest lasting one when several overlap, or nil when the player is free
+func ActiveSanction(db *gorm.DB, playerID uint, now time.Time) (*models.Sanction, error) {
+	var sanction models.Sanction
+	err := db.Where("player_id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", playerID, now).
+		Order("expires_at IS NULL DESC, expires_at DESC").
+		First(&sanction).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sanction, nil
+}
+
+// Message describes the restriction to the sanctioned player
+func Message(s *models.Sanction) string {
+	verb := "suspended"
+	if s.Kind == models.SanctionBan {
+		verb = "banned"
+	}
+	if s.ExpiresAt == nil {
+		return fmt.Sprintf("Player is %s permanently: %s", verb, s.Reason)
+	}
+	return fmt.Sprintf("Player is %s until %s: %s", verb, s.ExpiresAt.UTC().Format(time.RFC3339), s.Reason)
+}
+
+// Issue records a new sanction and ends the player's open sessions
+func Issue(db *gorm.DB, sanction *models.Sanction) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		sanction.AppealStatus = models.AppealNone
+		if err := tx.Create(sanction).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("player_id = ?", sanction.PlayerID).Delete(&models.AuthToken{}).Error; err != nil {
+			return err
+		}
+
+		expiry := "permanent"
+		if sanction.ExpiresAt != nil {
+			expiry = "until " + sanction.ExpiresAt.UTC().Format(time.RFC3339)
+		}
+		return writeLog(tx, sanction, models.ActionSanctionIssued,
+			fmt.Sprintf("%s %s by player %d: %s", sanction.Kind, expiry, sanction.IssuedBy, sanction.Reason))
+	})
+}
+
+// Revoke lifts a sanction before it expires
+func Revoke(db *gorm.DB, id, actorID uint) (*models.Sanction, error) {
+	return update(db, id, func(tx *gorm.DB, s *models.Sanction) error {
+		if s.RevokedAt != nil {
+			return ErrAlreadyRevoked
+		}
+		revoke(s, actorID)
+		if err := tx.Save(s).Error; err != nil {
+			return err
+		}
+		return writeLog(tx, s, models.ActionSanctionRevoked, fmt.Sprintf("revoked by player %d", actorID))
+	})
+}
+
+// Appeal files the player's appeal against a sanction, once per sanction
+func Appeal(db *gorm.DB, id uint, note string) (*models.Sanction, error) {
+	return update(db, id, func(tx *gorm.DB, s *models.Sanction) error {
+		if s.AppealStatus != models.AppealNone {
+			return ErrAppealExists
+		}
+		s.AppealStatus = models.AppealPending
+		s.AppealNote = note
+		if err := tx.Save(s).Error; err != nil {
+			return err
+		}
+		return writeLog(tx, s, models.ActionSanctionAppealed, note)
+	})
+}
+
+// DecideAppeal accepts or rejects a pending appeal, an accepted appeal
+// revokes the sanction
+func DecideAppeal(db *gorm.DB, id, actorID uint, accepted bool) (*models.Sanction, error) {
+	return update(db, id, func(tx *gorm.DB, s *models.Sanction) error {
+		if s.AppealStatus != models.AppealPending {
+			return ErrNoPendingAppeal
+		}
+		s.AppealStatus = models.AppealRejected
+		if accepted {
+			s.AppealStatus = models.AppealAccepted
+			if s.RevokedAt == nil {
+				revoke(s, actorID)
+			}
+		}
+		if err := tx.Save(s).Error; err != nil {
+			return err
+		}
+		return writeLog(tx, s, models.ActionAppealDecided,
+			fmt.Sprintf("appeal %s by player %d", s.AppealStatus, actorID))
+	})
+}
+
+func update(db *gorm.DB, id uint, apply func(tx *gorm.DB, s *models.Sanction) error) (*models.Sanction, error) {
+	var sanction models.Sanction
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&sanction, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrSanctionNotFound
+			}
+			return err
+		}
+		return apply(tx, &sanction)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &sanction, nil
+}
+
+func revoke(s *models.Sanction, actorID uint) {
+	now := time.Now()
+	s.RevokedAt = &now
+	s.RevokedBy = &actorID
+}
+
+func writeLog(tx *gorm.DB, s *models.Sanction, action, details string) error {
+	entry := models.GameLog{
+		PlayerID:  s.PlayerID,
+		Action:    action,
+		Timestamp: time.Now(),
+		Details:   fmt.Sprintf("sanction %d: %s", s.ID, details),
+	}
+	if err := tx.Create(&entry).Error; err != nil {
+		return fmt.Errorf("failed to log sanction change: %w", err)
+	}
+	return nil
+}
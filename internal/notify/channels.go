@@ -0,0 +1,131 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"oxo-game-api/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Webhook request headers
+const (
+	HeaderEventID   = "X-Notification-Event"
+	HeaderSignature = "X-Notification-Signature"
+)
+
+// InboxChannel stores messages in the player's in-app inbox
+type InboxChannel struct {
+	db *gorm.DB
+}
+
+func NewInboxChannel(db *gorm.DB) *InboxChannel {
+	return &InboxChannel{db: db}
+}
+
+func (c *InboxChannel) Name() string {
+	return "inbox"
+}
+
+func (c *InboxChannel) Send(profile *models.PlayerProfile, msg Message) error {
+	notification := models.Notification{
+		PlayerID:  msg.PlayerID,
+		EventID:   msg.EventID,
+		Type:      msg.Type,
+		Title:     msg.Title,
+		Body:      msg.Body,
+		Data:      string(msg.Data),
+		CreatedAt: msg.CreatedAt,
+	}
+	return c.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "event_id"}},
+		DoNothing: true,
+	}).Create(&notification).Error
+}
+
+// WebhookChannel posts messages as JSON to a URL. With a secret the body is
+// signed with HMAC-SHA256, sent hex encoded in the signature header.
+// Receivers should use the event id header to drop repeated deliveries.
+type WebhookChannel struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+func NewWebhookChannel(url, secret string) *WebhookChannel {
+	return &WebhookChannel{url: url, secret: secret, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (c *WebhookChannel) Name() string {
+	return "webhook"
+}
+
+func (c *WebhookChannel) Send(profile *models.PlayerProfile, msg Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEventID, strconv.FormatUint(uint64(msg.EventID), 10))
+	if c.secret != "" {
+		req.Header.Set(HeaderSignature, "sha256="+Sign(c.secret, body))
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// EmailChannel mails messages to players who set an email address in their
+// profile
+type EmailChannel struct {
+	mailer Mailer
+	from   string
+}
+
+func NewEmailChannel(mailer Mailer, from string) *EmailChannel {
+	return &EmailChannel{mailer: mailer, from: from}
+}
+
+func (c *EmailChannel) Name() string {
+	return "email"
+}
+
+func (c *EmailChannel) Send(profile *models.PlayerProfile, msg Message) error {
+	if profile.Email == "" {
+		return nil
+	}
+	return c.mailer.Send(Mail{
+		From:    c.from,
+		To:      profile.Email,
+		Subject: msg.Title,
+		Body:    msg.Body,
+	})
+}
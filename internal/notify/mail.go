@@ -0,0 +1,86 @@
+package notify
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"strings"
+	"sync"
+)
+
+// Mail is a plain text email
+type Mail struct {
+	From    string
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails
+type Mailer interface {
+	Send(mail Mail) error
+}
+
+// SMTPMailer sends emails through an SMTP server
+type SMTPMailer struct {
+	addr string
+	auth smtp.Auth
+}
+
+// NewSMTPMailer returns a mailer for the server, it authenticates when a user
+// is given
+func NewSMTPMailer(host, port, user, password string) *SMTPMailer {
+	mailer := &SMTPMailer{addr: net.JoinHostPort(host, port)}
+	if user != "" {
+		mailer.auth = smtp.PlainAuth("", user, password, host)
+	}
+	return mailer
+}
+
+func (m *SMTPMailer) Send(mail Mail) error {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", mail.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", mail.To)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mail.Subject)
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(mail.Body)
+	msg.WriteString("\r\n")
+	return smtp.SendMail(m.addr, m.auth, mail.From, []string{mail.To}, []byte(msg.String()))
+}
+
+// LogMailer writes a line to the server log for each email instead of
+// sending it, for running without a mail server. It keeps nothing and leaves
+// the address out of the log.
+type LogMailer struct{}
+
+func (LogMailer) Send(mail Mail) error {
+	log.Printf("Mail %q not sent, no SMTP host is configured", mail.Subject)
+	return nil
+}
+
+// FakeMailer keeps the emails instead of sending them, for tests to check
+// what would have been sent
+type FakeMailer struct {
+	mu   sync.Mutex
+	sent []Mail
+}
+
+func NewFakeMailer() *FakeMailer {
+	return &FakeMailer{}
+}
+
+func (m *FakeMailer) Send(mail Mail) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, mail)
+	return nil
+}
+
+// Sent returns the emails sent so far
+func (m *FakeMailer) Sent() []Mail {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Mail(nil), m.sent...)
+}
@@ -0,0 +1,236 @@
+// Package notify turns outbox events into messages for players and sends them
+// through the configured channels: the in-app inbox, a webhook and email.
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"oxo-game-api/config"
+	"oxo-game-api/internal/models"
+	"oxo-game-api/internal/scheduling"
+	"oxo-game-api/internal/social"
+
+	"gorm.io/gorm"
+)
+
+// Events published outside the scheduling package
+const (
+	EventChallengeSettled = "challenge.settled"
+	EventPaymentStatus    = "payment.status"
+)
+
+// Message is an event rendered for a player
+type Message struct {
+	EventID   uint            `json:"event_id"`
+	PlayerID  uint            `json:"player_id"`
+	Type      string          `json:"type"`
+	Title     string          `json:"title"`
+	Body      string          `json:"body"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Channel sends messages to players one way. When a channel fails only that
+// one is retried, the others are not sent the event again. A send whose
+// outcome got lost, like a webhook timing out after it was received, can
+// still repeat, so the inbox ignores known events and webhooks carry the
+// event id.
+type Channel interface {
+	Name() string
+	Send(profile *models.PlayerProfile, msg Message) error
+}
+
+// Dispatcher delivers outbox events to the players' channels, its Deliver
+// method is an outbox.Deliverer
+type Dispatcher struct {
+	db       *gorm.DB
+	channels []Channel
+}
+
+func NewDispatcher(db *gorm.DB, channels ...Channel) *Dispatcher {
+	return &Dispatcher{db: db, channels: channels}
+}
+
+// NewChannels returns the inbox, the webhook when a URL is configured, and
+// email through SMTP, or through a LogMailer when no SMTP host is configured
+func NewChannels(db *gorm.DB, cfg *config.NotificationConfig) []Channel {
+	channels := []Channel{NewInboxChannel(db)}
+	if cfg.WebhookURL != "" {
+		channels = append(channels, NewWebhookChannel(cfg.WebhookURL, cfg.WebhookSecret))
+	}
+
+	var mailer Mailer = LogMailer{}
+	if cfg.SMTPHost != "" {
+		mailer = NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUser, cfg.SMTPPassword)
+	}
+	return append(channels, NewEmailChannel(mailer, cfg.SMTPFrom))
+}
+
+// Deliver sends the event to every channel that has not taken it yet, unless
+// the player opted out of its kind, and returns the channels that took it.
+// Events for players who were deleted are dropped.
+func (d *Dispatcher) Deliver(event models.OutboxEvent) ([]string, error) {
+	var player models.Player
+	if err := d.db.First(&player, event.PlayerID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	profile := models.DefaultProfile(player.ID)
+	if err := d.db.Where("player_id = ?", player.ID).Limit(1).Find(&profile).Error; err != nil {
+		return nil, err
+	}
+	if !Wanted(event.Type, profile.Notifications) {
+		return nil, nil
+	}
+
+	msg, err := d.render(event, &profile)
+	if err != nil {
+		return nil, err
+	}
+
+	var delivered, failed []string
+	for _, channel := range d.channels {
+		if event.DeliveredTo(channel.Name()) {
+			continue
+		}
+		if err := channel.Send(&profile, msg); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", channel.Name(), err))
+			continue
+		}
+		delivered = append(delivered, channel.Name())
+	}
+	if len(failed) > 0 {
+		return delivered, errors.New(strings.Join(failed, "; "))
+	}
+	return delivered, nil
+}
+
+// Wanted reports whether the preferences allow the event. Reminders,
+// challenge results, payment updates and friend requests can be turned off,
+// changes to a player's bookings are always sent.
+func Wanted(eventType string, prefs models.NotificationPreferences) bool {
+	switch eventType {
+	case scheduling.EventReservationReminder:
+		return prefs.ReservationReminders
+	case EventChallengeSettled:
+		return prefs.ChallengeResults
+	case EventPaymentStatus:
+		return prefs.PaymentUpdates
+	case social.EventFriendRequest:
+		return prefs.FriendRequests
+	default:
+		return true
+	}
+}
+
+// payload holds the fields the events carry, each event sets some of them
+type payload struct {
+	ReservationID uint      `json:"reservation_id"`
+	RoomID        uint      `json:"room_id"`
+	FromRoomID    uint      `json:"from_room_id"`
+	StartsAt      time.Time `json:"starts_at"`
+	ConfirmBy     time.Time `json:"confirm_by"`
+	ChallengeID   uint      `json:"challenge_id"`
+	Won           bool      `json:"won"`
+	Prize         float64   `json:"prize"`
+	Amount        float64   `json:"amount"`
+	Method        string    `json:"method"`
+	Status        string    `json:"status"`
+	ErrorMessage  string    `json:"error_message"`
+	RequesterID   uint      `json:"requester_id"`
+}
+
+// render writes the title and body of the message, times are shown in the
+// player's timezone
+func (d *Dispatcher) render(event models.OutboxEvent, profile *models.PlayerProfile) (Message, error) {
+	msg := Message{
+		EventID:   event.ID,
+		PlayerID:  event.PlayerID,
+		Type:      event.Type,
+		Data:      json.RawMessage(event.Payload),
+		CreatedAt: event.CreatedAt,
+	}
+
+	var data payload
+	if err := json.Unmarshal([]byte(event.Payload), &data); err != nil {
+		return msg, fmt.Errorf("failed to decode %s event: %w", event.Type, err)
+	}
+
+	loc, err := time.LoadLocation(profile.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	at := func(t time.Time) string {
+		return t.In(loc).Format("Mon, 02 Jan 2006 15:04 MST")
+	}
+	room := func(id uint) (string, error) {
+		var room models.Room
+		if err := d.db.Unscoped().Select("name").Where("id = ?", id).Limit(1).Find(&room).Error; err != nil {
+			return "", err
+		}
+		if room.Name == "" {
+			return fmt.Sprintf("room %d", id), nil
+		}
+		return room.Name, nil
+	}
+
+	var roomName string
+	if data.RoomID != 0 {
+		if roomName, err = room(data.RoomID); err != nil {
+			return msg, err
+		}
+	}
+
+	switch event.Type {
+	case scheduling.EventReservationReminder:
+		msg.Title = "Your reservation starts soon"
+		msg.Body = fmt.Sprintf("Your reservation in %s starts at %s.", roomName, at(data.StartsAt))
+	case scheduling.EventReservationMoved:
+		from, err := room(data.FromRoomID)
+		if err != nil {
+			return msg, err
+		}
+		msg.Title = "Your reservation was moved"
+		msg.Body = fmt.Sprintf("%s was closed, your reservation on %s moved to %s.", from, at(data.StartsAt), roomName)
+	case scheduling.EventReservationCancelled:
+		msg.Title = "Your reservation was cancelled"
+		msg.Body = fmt.Sprintf("%s was closed and no other room was free, your reservation on %s is cancelled.", roomName, at(data.StartsAt))
+	case scheduling.EventWaitlistPromoted:
+		msg.Title = "A slot you waited for is free"
+		msg.Body = fmt.Sprintf("%s is free on %s. Confirm by %s to keep it.", roomName, at(data.StartsAt), at(data.ConfirmBy))
+	case scheduling.EventWaitlistExpired:
+		msg.Title = "Your waitlist offer expired"
+		msg.Body = fmt.Sprintf("The slot in %s on %s was not confirmed in time and went to the next player.", roomName, at(data.StartsAt))
+	case EventChallengeSettled:
+		if data.Won {
+			msg.Title = "You won the challenge"
+			msg.Body = fmt.Sprintf("Challenge %d paid out a prize of %.2f.", data.ChallengeID, data.Prize)
+		} else {
+			msg.Title = "Challenge finished"
+			msg.Body = fmt.Sprintf("Challenge %d ended without a win this time.", data.ChallengeID)
+		}
+	case EventPaymentStatus:
+		msg.Title = fmt.Sprintf("Payment %s", data.Status)
+		msg.Body = fmt.Sprintf("Your %s payment of %.2f is %s.", strings.ReplaceAll(data.Method, "_", " "), data.Amount, data.Status)
+		if data.ErrorMessage != "" {
+			msg.Body += " " + data.ErrorMessage
+		}
+	case social.EventFriendRequest:
+		var requester models.Player
+		if err := d.db.Unscoped().Select("name").Where("id = ?", data.RequesterID).Limit(1).Find(&requester).Error; err != nil {
+			return msg, err
+		}
+		msg.Title = "New friend request"
+		msg.Body = fmt.Sprintf("%s wants to be your friend.", requester.Name)
+	default:
+		msg.Title = event.Type
+	}
+	return msg, nil
+}
@@ -0,0 +1,166 @@
+// Package outbox records events in the transaction that causes them and
+// relays them once that transaction has committed.
+package outbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"oxo-game-api/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultRelayInterval is how often pending events are relayed
+const DefaultRelayInterval = 5 * time.Second
+
+// MaxAttempts is how often an event is tried before it is given up
+const MaxAttempts = 10
+
+const (
+	// relayBatch is how many events a single relay pass delivers
+	relayBatch = 100
+	// claimLease keeps a claimed event from being claimed again while it is
+	// delivered, it becomes due again when the relay dies in between
+	claimLease = 30 * time.Minute
+	// retryBase and retryCap bound the exponential backoff between attempts
+	retryBase = 10 * time.Second
+	retryCap  = time.Hour
+)
+
+// Deliverer hands an event over to whatever notifies the player. It returns
+// the channels that took the event on this attempt, the ones in
+// event.Delivered are done already and should be skipped. The event is
+// published once it returns no error.
+type Deliverer func(event models.OutboxEvent) ([]string, error)
+
+// Publish writes an event for the player, tx should be the transaction making
+// the change the event describes
+func Publish(tx *gorm.DB, eventType string, playerID uint, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %w", eventType, err)
+	}
+
+	now := time.Now()
+	event := models.OutboxEvent{
+		Type:          eventType,
+		PlayerID:      playerID,
+		Payload:       string(data),
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+	if err := tx.Create(&event).Error; err != nil {
+		return fmt.Errorf("failed to record %s event: %w", eventType, err)
+	}
+	return nil
+}
+
+// Relay delivers the due events, the longest waiting first, and marks them
+// published. The events are claimed in a short transaction and delivered
+// outside of it, so slow channels hold no locks. A failed event is retried
+// with exponential backoff and given up after MaxAttempts.
+func Relay(db *gorm.DB, deliver Deliverer, now time.Time) (int, error) {
+	events, err := claim(db, now)
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for _, event := range events {
+		channels, err := deliver(event)
+		for _, channel := range channels {
+			if !event.DeliveredTo(channel) {
+				event.Delivered = strings.TrimPrefix(event.Delivered+","+channel, ",")
+			}
+		}
+
+		updates := map[string]interface{}{"delivered": event.Delivered}
+		switch {
+		case err == nil:
+			updates["published_at"] = now
+			updates["last_error"] = ""
+			delivered++
+		case event.Attempts >= MaxAttempts:
+			updates["dead_at"] = now
+			updates["last_error"] = truncate(err.Error())
+			log.Printf("Giving up on event %d %s after %d attempts: %v", event.ID, event.Type, event.Attempts, err)
+		default:
+			updates["next_attempt_at"] = now.Add(backoff(event.Attempts))
+			updates["last_error"] = truncate(err.Error())
+		}
+		if err := db.Model(&models.OutboxEvent{}).Where("id = ?", event.ID).Updates(updates).Error; err != nil {
+			return delivered, err
+		}
+	}
+	return delivered, nil
+}
+
+// claim takes the due events and counts the attempt, pushing their next
+// attempt past the lease so no other relay picks them up meanwhile
+func claim(db *gorm.DB, now time.Time) ([]models.OutboxEvent, error) {
+	var events []models.OutboxEvent
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("published_at IS NULL AND dead_at IS NULL AND next_attempt_at <= ?", now).
+			Order("next_attempt_at, id").Limit(relayBatch).Find(&events).Error; err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, len(events))
+		for i := range events {
+			events[i].Attempts++
+			ids[i] = events[i].ID
+		}
+		return tx.Model(&models.OutboxEvent{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"attempts":        gorm.Expr("attempts + 1"),
+			"next_attempt_at": now.Add(claimLease),
+		}).Error
+	})
+	return events, err
+}
+
+// backoff is the wait after the given number of failed attempts
+func backoff(attempts int) time.Duration {
+	wait := retryBase
+	for i := 1; i < attempts && wait < retryCap; i++ {
+		wait *= 2
+	}
+	if wait > retryCap {
+		wait = retryCap
+	}
+	return wait
+}
+
+func truncate(msg string) string {
+	if len(msg) > 255 {
+		return msg[:255]
+	}
+	return msg
+}
+
+// StartRelay runs Relay every interval in the background
+func StartRelay(db *gorm.DB, interval time.Duration, deliver Deliverer) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := Relay(db, deliver, time.Now()); err != nil {
+				log.Printf("Error relaying outbox events: %v", err)
+			}
+		}
+	}()
+}
+
+// LogDeliverer writes events to the server log
+func LogDeliverer(event models.OutboxEvent) ([]string, error) {
+	log.Printf("Event %d %s for player %d: %s", event.ID, event.Type, event.PlayerID, event.Payload)
+	return []string{"log"}, nil
+}
@@ -0,0 +1,271 @@
+// Package presence tracks which players are inside a room right now. Every
+// enter and leave is written as a game log, and a room's status follows its
+// occupancy between available and occupied. Those status flips leave the
+// room's updated_at alone, so its ETag only changes when the room is edited.
+package presence
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"oxo-game-api/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Reasons recorded in the details of leave logs
+const (
+	ReasonTimedOut = "session timed out"
+	ReasonMoved    = "entered another room"
+)
+
+var (
+	ErrRoomNotFound = errors.New("room not found")
+	ErrRoomClosed   = errors.New("room is not open to players")
+	ErrRoomFull     = errors.New("room is full")
+	ErrNotInRoom    = errors.New("player is not in the room")
+)
+
+// Tracker keeps the live sessions of the players in rooms
+type Tracker struct {
+	db      *gorm.DB
+	store   Store
+	timeout time.Duration
+	// mu serializes changes so the capacity check and the room status see
+	// every session
+	mu sync.Mutex
+}
+
+// NewTracker returns a tracker that ends sessions without a heartbeat for
+// timeout
+func NewTracker(db *gorm.DB, store Store, timeout time.Duration) *Tracker {
+	return &Tracker{db: db, store: store, timeout: timeout}
+}
+
+// Enter puts the player in the room, leaving the room they were in before.
+// Entering the room the player is already in counts as a heartbeat. Details
+// go into the game log.
+func (t *Tracker) Enter(playerID, roomID uint, details string, now time.Time) (Session, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	current, inRoom := t.store.Get(playerID)
+	if inRoom && current.RoomID == roomID {
+		current.LastSeen = now
+		t.store.Put(current)
+		return current, nil
+	}
+
+	var room models.Room
+	if err := t.db.First(&room, roomID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return Session{}, ErrRoomNotFound
+		}
+		return Session{}, err
+	}
+	if !room.Bookable() {
+		return Session{}, ErrRoomClosed
+	}
+	if len(t.store.Room(roomID)) >= room.Capacity {
+		return Session{}, ErrRoomFull
+	}
+
+	session := Session{PlayerID: playerID, RoomID: roomID, EnteredAt: now, LastSeen: now}
+	err := t.db.Transaction(func(tx *gorm.DB) error {
+		if inRoom {
+			if _, err := t.leave(tx, current, ReasonMoved, now); err != nil {
+				return err
+			}
+		}
+		logID, err := writeLog(tx, playerID, &roomID, models.ActionEnterRoom, details, now)
+		if err != nil {
+			return err
+		}
+		session.LogID = logID
+		return tx.Model(&models.Room{}).Where("id = ? AND status = ?", roomID, models.RoomAvailable).
+			UpdateColumn("status", models.RoomOccupied).Error
+	})
+	if err != nil {
+		return Session{}, err
+	}
+
+	t.store.Put(session)
+	return session, nil
+}
+
+// Heartbeat keeps the player's session in the room alive
+func (t *Tracker) Heartbeat(playerID, roomID uint, now time.Time) (Session, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	session, ok := t.store.Get(playerID)
+	if !ok || session.RoomID != roomID {
+		return Session{}, ErrNotInRoom
+	}
+	session.LastSeen = now
+	t.store.Put(session)
+	return session, nil
+}
+
+// Leave takes the player out of the room, details go into the game log
+func (t *Tracker) Leave(playerID, roomID uint, details string, now time.Time) (Session, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	session, ok := t.store.Get(playerID)
+	if !ok || session.RoomID != roomID {
+		return Session{}, ErrNotInRoom
+	}
+	err := t.db.Transaction(func(tx *gorm.DB) error {
+		logID, err := t.leave(tx, session, details, now)
+		session.LogID = logID
+		return err
+	})
+	if err != nil {
+		return Session{}, err
+	}
+	t.store.Remove(playerID)
+	return session, nil
+}
+
+// Occupants returns the sessions of a room, first entered first
+func (t *Tracker) Occupants(roomID uint) []Session {
+	return t.store.Room(roomID)
+}
+
+// Sweep ends the sessions without a heartbeat since the timeout. A session
+// that fails to end is kept for the next sweep, the others still end.
+func (t *Tracker) Sweep(now time.Time) ([]Session, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	cutoff := now.Add(-t.timeout)
+	ended := make([]Session, 0)
+	var firstErr error
+	for _, session := range t.store.All() {
+		if !session.LastSeen.Before(cutoff) {
+			continue
+		}
+		err := t.db.Transaction(func(tx *gorm.DB) error {
+			_, err := t.leave(tx, session, ReasonTimedOut, now)
+			return err
+		})
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		t.store.Remove(session.PlayerID)
+		ended = append(ended, session)
+	}
+	return ended, firstErr
+}
+
+// Restore rebuilds the sessions from the game logs after a restart. Players
+// whose last log entered a room are back in it and have the timeout to send
+// a heartbeat, and room statuses are brought in line with the sessions.
+func (t *Tracker) Restore(now time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var latest []models.GameLog
+	err := t.db.Model(&models.GameLog{}).
+		Select("DISTINCT ON (player_id) id, player_id, action, room_id, timestamp").
+		Where("action IN ? AND room_id IS NOT NULL", []string{models.ActionEnterRoom, models.ActionLeaveRoom}).
+		Order("player_id, timestamp desc, id desc").
+		Find(&latest).Error
+	if err != nil {
+		return err
+	}
+
+	occupied := make([]uint, 0)
+	for _, entry := range latest {
+		if entry.Action != models.ActionEnterRoom {
+			continue
+		}
+		t.store.Put(Session{PlayerID: entry.PlayerID, RoomID: *entry.RoomID, EnteredAt: entry.Timestamp, LastSeen: now, LogID: entry.ID})
+		occupied = append(occupied, *entry.RoomID)
+	}
+
+	return t.db.Transaction(func(tx *gorm.DB) error {
+		vacant := tx.Model(&models.Room{}).Where("status = ?", models.RoomOccupied)
+		if len(occupied) > 0 {
+			vacant = vacant.Where("id NOT IN ?", occupied)
+		}
+		if err := vacant.UpdateColumn("status", models.RoomAvailable).Error; err != nil {
+			return err
+		}
+		if len(occupied) == 0 {
+			return nil
+		}
+		return tx.Model(&models.Room{}).Where("id IN ? AND status = ?", occupied, models.RoomAvailable).
+			UpdateColumn("status", models.RoomOccupied).Error
+	})
+}
+
+// Start restores the sessions and runs Sweep every interval in the background
+func (t *Tracker) Start(interval time.Duration) error {
+	if err := t.Restore(time.Now()); err != nil {
+		return err
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := t.Sweep(time.Now()); err != nil {
+				log.Printf("Error ending stale room sessions: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// leave logs the player leaving and frees the room when they were the last
+// one in it. The caller removes the session from the store once tx commits.
+func (t *Tracker) leave(tx *gorm.DB, session Session, reason string, now time.Time) (uint, error) {
+	// a purged player or room can no longer be referenced by the log
+	var players, rooms int64
+	if err := tx.Unscoped().Model(&models.Player{}).Where("id = ?", session.PlayerID).Count(&players).Error; err != nil {
+		return 0, err
+	}
+	if err := tx.Unscoped().Model(&models.Room{}).Where("id = ?", session.RoomID).Count(&rooms).Error; err != nil {
+		return 0, err
+	}
+	roomID := &session.RoomID
+	if rooms == 0 {
+		roomID = nil
+	}
+	var logID uint
+	if players > 0 {
+		id, err := writeLog(tx, session.PlayerID, roomID, models.ActionLeaveRoom, reason, now)
+		if err != nil {
+			return 0, err
+		}
+		logID = id
+	}
+	for _, other := range t.store.Room(session.RoomID) {
+		if other.PlayerID != session.PlayerID {
+			return logID, nil
+		}
+	}
+	return logID, tx.Model(&models.Room{}).Where("id = ? AND status = ?", session.RoomID, models.RoomOccupied).
+		UpdateColumn("status", models.RoomAvailable).Error
+}
+
+func writeLog(tx *gorm.DB, playerID uint, roomID *uint, action, details string, now time.Time) (uint, error) {
+	entry := models.GameLog{
+		PlayerID:  playerID,
+		Action:    action,
+		Timestamp: now,
+		Details:   details,
+		RoomID:    roomID,
+	}
+	if err := tx.Create(&entry).Error; err != nil {
+		return 0, fmt.Errorf("failed to log room presence: %w", err)
+	}
+	return entry.ID, nil
+}
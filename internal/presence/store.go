@@ -0,0 +1,92 @@
+package presence
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Session is a player's stay in a room
+type Session struct {
+	PlayerID  uint      `json:"player_id"`
+	RoomID    uint      `json:"room_id"`
+	EnteredAt time.Time `json:"entered_at"`
+	LastSeen  time.Time `json:"last_seen"`
+	// LogID is the game log of the last enter or leave
+	LogID uint `json:"log_id"`
+}
+
+// Store keeps the live sessions, a player is in at most one room. The
+// Tracker serializes changes, so a Store only has to be safe for concurrent
+// reads. MemoryStore keeps the sessions in the process, a store shared
+// between servers, such as Redis, is needed to run more than one API server.
+type Store interface {
+	Get(playerID uint) (Session, bool)
+	Put(session Session)
+	Remove(playerID uint)
+	// Room returns the sessions of a room, first entered first
+	Room(roomID uint) []Session
+	All() []Session
+}
+
+// MemoryStore is a Store in the memory of the process
+type MemoryStore struct {
+	mu       sync.RWMutex
+	sessions map[uint]Session
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[uint]Session)}
+}
+
+func (s *MemoryStore) Get(playerID uint) (Session, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	session, ok := s.sessions[playerID]
+	return session, ok
+}
+
+func (s *MemoryStore) Put(session Session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[session.PlayerID] = session
+}
+
+func (s *MemoryStore) Remove(playerID uint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, playerID)
+}
+
+func (s *MemoryStore) Room(roomID uint) []Session {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sessions := make([]Session, 0)
+	for _, session := range s.sessions {
+		if session.RoomID == roomID {
+			sessions = append(sessions, session)
+		}
+	}
+	sortSessions(sessions)
+	return sessions
+}
+
+func (s *MemoryStore) All() []Session {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sessions := make([]Session, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		sessions = append(sessions, session)
+	}
+	sortSessions(sessions)
+	return sessions
+}
+
+func sortSessions(sessions []Session) {
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].EnteredAt.Equal(sessions[j].EnteredAt) {
+			return sessions[i].EnteredAt.Before(sessions[j].EnteredAt)
+		}
+		return sessions[i].PlayerID < sessions[j].PlayerID
+	})
+}
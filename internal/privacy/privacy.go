@@ -0,0 +1,210 @@
+// Package privacy answers data subject requests: exporting everything stored
+// about a player and erasing their personal data.
+package privacy
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"oxo-game-api/internal/models"
+
+	"gorm.io/gorm"
+)
+
+var ErrPlayerNotFound = errors.New("player not found")
+
+// Bundle is the export of every record kept about a player
+type Bundle struct {
+	ExportedAt       time.Time                       `json:"exported_at"`
+	Player           models.Player                   `json:"player"`
+	LevelHistory     []models.LevelChange            `json:"level_history"`
+	GameLogs         []models.GameLog                `json:"game_logs"`
+	Challenges       []models.Challenge              `json:"challenges"`
+	ChallengeResults []models.ChallengeResult        `json:"challenge_results"`
+	Reservations     []models.Reservation            `json:"reservations"`
+	ReservationLog   []models.ReservationTransition  `json:"reservation_history"`
+	Series           []models.ReservationSeries      `json:"reservation_series"`
+	Payments         []models.Payment                `json:"payments"`
+	Sanctions        []models.Sanction               `json:"sanctions"`
+	Friendships      []models.Friendship             `json:"friendships"`
+	Participations   []models.ReservationParticipant `json:"reservation_participations"`
+	Waitlist         []models.WaitlistEntry          `json:"waitlist"`
+	Notifications    []models.Notification           `json:"notifications"`
+	PrivacySettings  models.PrivacySettings          `json:"privacy_settings"`
+	Profile          models.PlayerProfile            `json:"profile"`
+}
+
+// Export collects the player's profile and every record referencing them,
+// soft-deleted players included
+func Export(db *gorm.DB, playerID uint) (*Bundle, error) {
+	bundle := &Bundle{ExportedAt: time.Now()}
+
+	if err := db.Unscoped().Preload("Level").First(&bundle.Player, playerID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPlayerNotFound
+		}
+		return nil, err
+	}
+
+	related := []interface{}{
+		&bundle.LevelHistory,
+		&bundle.GameLogs,
+		&bundle.Challenges,
+		&bundle.ChallengeResults,
+		&bundle.Reservations,
+		&bundle.Series,
+		&bundle.Payments,
+		&bundle.Sanctions,
+		&bundle.Participations,
+		&bundle.Waitlist,
+		&bundle.Notifications,
+	}
+	for _, dest := range related {
+		if err := db.Where("player_id = ?", playerID).Order("id").Find(dest).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	if err := db.Where("reservation_id IN (?)", db.Model(&models.Reservation{}).Select("id").Where("player_id = ?", playerID)).
+		Order("id").Find(&bundle.ReservationLog).Error; err != nil {
+		return nil, err
+	}
+
+	if err := db.Where("requester_id = ? OR addressee_id = ?", playerID, playerID).
+		Order("id").Find(&bundle.Friendships).Error; err != nil {
+		return nil, err
+	}
+
+	bundle.PrivacySettings = models.DefaultPrivacySettings(playerID)
+	if err := db.Where("player_id = ?", playerID).Limit(1).Find(&bundle.PrivacySettings).Error; err != nil {
+		return nil, err
+	}
+
+	bundle.Profile = models.DefaultProfile(playerID)
+	if err := db.Where("player_id = ?", playerID).Limit(1).Find(&bundle.Profile).Error; err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
+
+// Anonymize strips the personal data of a player. The player row is kept,
+// renamed and soft-deleted, so challenges, results and payments stay intact
+// for accounting while no longer pointing to an identifiable person. Log and
+// payment details, appeal notes and the reasons given for cancelling the
+// player's reservations are cleared. The player's profile, social graph,
+// calendar feeds and notifications are deleted.
+func Anonymize(tx *gorm.DB, playerID uint) error {
+	now := time.Now()
+
+	result := tx.Unscoped().Model(&models.Player{}).Where("id = ?", playerID).Updates(map[string]interface{}{
+		"name":          fmt.Sprintf("erased-%d", playerID),
+		"password_hash": "",
+		"role":          models.RolePlayer,
+		"updated_at":    now,
+		"deleted_at":    gorm.Expr("COALESCE(deleted_at, ?)", now),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrPlayerNotFound
+	}
+
+	if err := tx.Model(&models.GameLog{}).Where("player_id = ?", playerID).
+		Update("details", "").Error; err != nil {
+		return err
+	}
+	// payment details are free text that may hold card or bank data, the
+	// amount, method and status are what accounting needs
+	if err := tx.Model(&models.Payment{}).Where("player_id = ?", playerID).
+		Update("details", "").Error; err != nil {
+		return err
+	}
+	// appeal notes and cancellation reasons are written by the player, the
+	// sanctions and reservations themselves stay as records
+	if err := tx.Model(&models.Sanction{}).Where("player_id = ?", playerID).
+		Update("appeal_note", "").Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.Reservation{}).Where("player_id = ?", playerID).
+		Update("cancel_reason", "").Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.ReservationTransition{}).
+		Where("actor_id = ? OR reservation_id IN (?)", playerID, tx.Model(&models.Reservation{}).Select("id").Where("player_id = ?", playerID)).
+		Update("reason", "").Error; err != nil {
+		return err
+	}
+
+	for _, model := range []interface{}{
+		&models.AuthToken{},
+		&models.LeaderboardEntry{},
+		&models.PrivacySettings{},
+		&models.PlayerProfile{},
+		&models.ReservationParticipant{},
+		&models.WaitlistEntry{},
+		&models.OutboxEvent{},
+		&models.Notification{},
+	} {
+		if err := tx.Where("player_id = ?", playerID).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	if err := tx.Where("created_by = ? OR (owner_type = ? AND owner_id = ?)", playerID, models.FeedPlayer, playerID).
+		Delete(&models.CalendarFeed{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("requester_id = ? OR addressee_id = ?", playerID, playerID).
+		Delete(&models.Friendship{}).Error
+}
+
+// RunErasureJob executes a pending erasure job and records its outcome
+func RunErasureJob(db *gorm.DB, jobID uint) {
+	started := time.Now()
+	result := db.Model(&models.ErasureJob{}).
+		Where("id = ? AND status IN ?", jobID, []string{models.ErasurePending, models.ErasureRunning}).
+		Updates(map[string]interface{}{"status": models.ErasureRunning, "started_at": started})
+	if result.Error != nil || result.RowsAffected == 0 {
+		log.Printf("Erasure job %d could not be started: %v", jobID, result.Error)
+		return
+	}
+
+	var job models.ErasureJob
+	if err := db.First(&job, jobID).Error; err != nil {
+		log.Printf("Error loading erasure job %d: %v", jobID, err)
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return Anonymize(tx, job.PlayerID)
+	})
+
+	updates := map[string]interface{}{
+		"status":       models.ErasureCompleted,
+		"completed_at": time.Now(),
+	}
+	if err != nil {
+		updates["status"] = models.ErasureFailed
+		updates["error"] = err.Error()
+		log.Printf("Erasure job %d failed: %v", jobID, err)
+	}
+	if err := db.Model(&job).Updates(updates).Error; err != nil {
+		log.Printf("Error updating erasure job %d: %v", jobID, err)
+	}
+}
+
+// ResumeErasureJobs restarts the jobs interrupted by a shutdown
+func ResumeErasureJobs(db *gorm.DB) error {
+	var jobs []models.ErasureJob
+	if err := db.Where("status IN ?", []string{models.ErasurePending, models.ErasureRunning}).
+		Order("id").Find(&jobs).Error; err != nil {
+		return err
+	}
+
+	for _, job := range jobs {
+		go RunErasureJob(db, job.ID)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package progression
+
+import (
+	"fmt"
+	"sort"
+
+	"oxo-game-api/internal/models"
+)
+
+// LadderError explains why a set of levels is not a contiguous,
+// non-overlapping ladder starting at zero experience
+type LadderError struct {
+	Message string
+}
+
+func (e *LadderError) Error() string {
+	return e.Message
+}
+
+// ValidateLadder checks that every level has min_exp <= max_exp, that the
+// lowest level starts at 0 and that each level starts right after the
+// previous one ends
+func ValidateLadder(levels []models.Level) error {
+	sorted := make([]models.Level, len(levels))
+	copy(sorted, levels)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].MinExp < sorted[j].MinExp
+	})
+
+	for i, level := range sorted {
+		if level.MinExp > level.MaxExp {
+			return &LadderError{fmt.Sprintf("level %s: min_exp %d is greater than max_exp %d", level.Name, level.MinExp, level.MaxExp)}
+		}
+
+		if i == 0 {
+			if level.MinExp != 0 {
+				return &LadderError{fmt.Sprintf("level %s: the lowest level must start at 0 experience, got %d", level.Name, level.MinExp)}
+			}
+			continue
+		}
+
+		prev := sorted[i-1]
+		switch {
+		case level.MinExp <= prev.MaxExp:
+			return &LadderError{fmt.Sprintf("level %s (%d-%d) overlaps level %s (%d-%d)",
+				level.Name, level.MinExp, level.MaxExp, prev.Name, prev.MinExp, prev.MaxExp)}
+		case level.MinExp > prev.MaxExp+1:
+			return &LadderError{fmt.Sprintf("gap between level %s (ends at %d) and level %s (starts at %d), expected %s to start at %d",
+				prev.Name, prev.MaxExp, level.Name, level.MinExp, level.Name, prev.MaxExp+1)}
+		}
+	}
+	return nil
+}
+
+// WithoutLevel returns the ladder left after removing the level with id, the
+// removed range is absorbed by the level below it, or the level above when
+// the lowest level is removed
+func WithoutLevel(levels []models.Level, id uint) []models.Level {
+	sorted := make([]models.Level, 0, len(levels))
+	var removed *models.Level
+	for i := range levels {
+		if levels[i].ID == id {
+			removed = &levels[i]
+			continue
+		}
+		sorted = append(sorted, levels[i])
+	}
+	if removed == nil || len(sorted) == 0 {
+		return sorted
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].MinExp < sorted[j].MinExp
+	})
+
+	for i := len(sorted) - 1; i >= 0; i-- {
+		if sorted[i].MinExp < removed.MinExp {
+			if sorted[i].MaxExp < removed.MaxExp {
+				sorted[i].MaxExp = removed.MaxExp
+			}
+			return sorted
+		}
+	}
+	sorted[0].MinExp = removed.MinExp
+	return sorted
+}
@@ -0,0 +1,196 @@
+// Package progression awards experience to players and keeps their level in
+// line with the MinExp/MaxExp ranges of the level ladder.
+package progression
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"oxo-game-api/internal/leaderboard"
+	"oxo-game-api/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Experience granted by the game itself
+const (
+	ExpChallengeJoin = 10
+	ExpChallengeWin  = 100
+)
+
+var ErrPlayerNotFound = errors.New("player not found")
+
+// Result describes the outcome of an experience award
+type Result struct {
+	Player       *models.Player `json:"player"`
+	Awarded      int            `json:"awarded"`
+	FromLevelID  uint           `json:"from_level_id"`
+	LevelChanged bool           `json:"level_changed"`
+}
+
+// AwardExperience adds amount (negative to remove) to the player's experience,
+// clamped at zero, and promotes or demotes the player when the new total
+// leaves the current level's range
+func AwardExperience(db *gorm.DB, playerID uint, amount int, reason string) (*Result, error) {
+	var result *Result
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var player models.Player
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&player, playerID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrPlayerNotFound
+			}
+			return err
+		}
+
+		exp := int64(player.Experience) + int64(amount)
+		if exp < 0 {
+			exp = 0
+		}
+		player.Experience = uint(exp)
+
+		fromLevelID := player.LevelID
+		level, err := LevelForExperience(tx, player.Experience)
+		if err != nil {
+			return err
+		}
+		if level != nil {
+			player.LevelID = level.ID
+		}
+
+		if err := tx.Model(&player).Updates(map[string]interface{}{
+			"experience": player.Experience,
+			"level_id":   player.LevelID,
+			"updated_at": time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+
+		if player.LevelID != fromLevelID {
+			if err := recordLevelChange(tx, &player, fromLevelID, reason); err != nil {
+				return err
+			}
+		}
+
+		if err := leaderboard.RecordExperience(tx, player.ID, amount, player.Experience, time.Now()); err != nil {
+			return err
+		}
+
+		result = &Result{
+			Player:       &player,
+			Awarded:      amount,
+			FromLevelID:  fromLevelID,
+			LevelChanged: player.LevelID != fromLevelID,
+		}
+		return nil
+	})
+	return result, err
+}
+
+// SetLevel moves a player to levelID directly, resetting the experience to the
+// bottom of that level so later awards keep the two consistent
+func SetLevel(tx *gorm.DB, player *models.Player, level *models.Level, reason string) error {
+	if player.LevelID == level.ID {
+		return nil
+	}
+
+	fromLevelID := player.LevelID
+	player.LevelID = level.ID
+	player.Experience = level.MinExp
+
+	if err := tx.Model(player).Updates(map[string]interface{}{
+		"experience": player.Experience,
+		"level_id":   player.LevelID,
+	}).Error; err != nil {
+		return err
+	}
+	if err := leaderboard.RecordExperience(tx, player.ID, 0, player.Experience, time.Now()); err != nil {
+		return err
+	}
+	return recordLevelChange(tx, player, fromLevelID, reason)
+}
+
+// Relevel moves every player whose experience falls outside their level to
+// the level of ladder containing it, after the ranges of the ladder changed.
+// Experience is left as it is. It returns how many players moved.
+func Relevel(tx *gorm.DB, ladder []models.Level, reason string) (int, error) {
+	if len(ladder) == 0 {
+		return 0, nil
+	}
+	sorted := make([]models.Level, len(ladder))
+	copy(sorted, ladder)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].MinExp < sorted[j].MinExp
+	})
+
+	var players []models.Player
+	if err := tx.Unscoped().Select("id", "level_id", "experience").Order("id").Find(&players).Error; err != nil {
+		return 0, err
+	}
+
+	moved := 0
+	for i := range players {
+		player := &players[i]
+		level := sorted[0]
+		for _, candidate := range sorted {
+			if candidate.MinExp <= player.Experience {
+				level = candidate
+			}
+		}
+		if player.LevelID == level.ID {
+			continue
+		}
+
+		fromLevelID := player.LevelID
+		player.LevelID = level.ID
+		if err := tx.Unscoped().Model(&models.Player{}).Where("id = ?", player.ID).
+			Update("level_id", level.ID).Error; err != nil {
+			return moved, err
+		}
+		if err := recordLevelChange(tx, player, fromLevelID, reason); err != nil {
+			return moved, err
+		}
+		moved++
+	}
+	return moved, nil
+}
+
+// LevelForExperience finds the level whose range contains exp, players past
+// the top of the ladder stay on the highest level. Returns nil when no level
+// is defined at all.
+func LevelForExperience(tx *gorm.DB, exp uint) (*models.Level, error) {
+	var level models.Level
+	err := tx.Where("min_exp <= ?", exp).Order("min_exp desc").First(&level).Error
+	if err == nil {
+		return &level, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	err = tx.Order("min_exp asc").First(&level).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &level, nil
+}
+
+func recordLevelChange(tx *gorm.DB, player *models.Player, fromLevelID uint, reason string) error {
+	change := models.LevelChange{
+		PlayerID:    player.ID,
+		FromLevelID: fromLevelID,
+		ToLevelID:   player.LevelID,
+		Experience:  player.Experience,
+		Reason:      reason,
+		CreatedAt:   time.Now(),
+	}
+	if err := tx.Create(&change).Error; err != nil {
+		return fmt.Errorf("failed to record level change: %w", err)
+	}
+	return nil
+}
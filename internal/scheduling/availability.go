@@ -0,0 +1,139 @@
+package scheduling
+
+import (
+	"sort"
+	"time"
+
+	"oxo-game-api/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// MaxWindow is the longest range availability can be asked for at once
+const MaxWindow = 31 * 24 * time.Hour
+
+// Busy loads the reservations and maintenance windows of the rooms that
+// overlap window, keyed by room
+func Busy(db *gorm.DB, roomIDs []uint, window Interval) (map[uint][]Interval, error) {
+	busy := make(map[uint][]Interval, len(roomIDs))
+	if len(roomIDs) == 0 {
+		return busy, nil
+	}
+
+	var reservations []models.Reservation
+	if err := db.Where("room_id IN ? AND status IN ? AND starts_at < ? AND ends_at > ?",
+		roomIDs, models.ActiveReservationStatuses, window.End, window.Start).
+		Find(&reservations).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range reservations {
+		busy[r.RoomID] = append(busy[r.RoomID], Interval{Start: r.StartsAt, End: r.EndsAt})
+	}
+
+	var windows []models.MaintenanceWindow
+	if err := db.Where("room_id IN ? AND starts_at < ? AND ends_at > ?", roomIDs, window.End, window.Start).
+		Find(&windows).Error; err != nil {
+		return nil, err
+	}
+	for _, w := range windows {
+		busy[w.RoomID] = append(busy[w.RoomID], Interval{Start: w.StartsAt, End: w.EndsAt})
+	}
+	return busy, nil
+}
+
+// Free returns the times within window the room is open and not busy, from
+// the next whole minute after now at the earliest
+func Free(room *models.Room, window Interval, busy []Interval, now time.Time) ([]Interval, error) {
+	free := []Interval{}
+	if !room.Bookable() {
+		return free, nil
+	}
+	hours, err := RoomHours(room)
+	if err != nil {
+		return nil, err
+	}
+
+	start := window.Start
+	if earliest := now.Truncate(time.Minute).Add(time.Minute); start.Before(earliest) {
+		start = earliest
+	}
+	if !start.Before(window.End) {
+		return free, nil
+	}
+
+	var open []Interval
+	first := start.In(hours.Location)
+	for i := -1; ; i++ {
+		day := time.Date(first.Year(), first.Month(), first.Day()+i, 0, 0, 0, 0, hours.Location)
+		if !day.Before(window.End) {
+			break
+		}
+		slot := Interval{
+			Start: latest(day.Add(time.Duration(hours.Opens)*time.Minute), start),
+			End:   earliest(day.Add(time.Duration(hours.Closes)*time.Minute), window.End),
+		}
+		if !slot.Start.Before(slot.End) {
+			continue
+		}
+		if n := len(open); n > 0 && open[n-1].End.Equal(slot.Start) {
+			open[n-1].End = slot.End
+			continue
+		}
+		open = append(open, slot)
+	}
+
+	sorted := append([]Interval(nil), busy...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+
+	for _, o := range open {
+		cursor := o.Start
+		for _, b := range sorted {
+			if !b.Start.Before(o.End) {
+				break
+			}
+			if !b.End.After(cursor) || !b.End.After(b.Start) {
+				continue
+			}
+			if b.Start.After(cursor) {
+				free = append(free, Interval{Start: cursor, End: b.Start})
+			}
+			cursor = b.End
+		}
+		if cursor.Before(o.End) {
+			free = append(free, Interval{Start: cursor, End: o.End})
+		}
+	}
+	return free, nil
+}
+
+// Slots cuts the free intervals into consecutive slots of length d
+func Slots(free []Interval, d time.Duration) []Interval {
+	slots := []Interval{}
+	for _, f := range free {
+		for t := f.Start; !t.Add(d).After(f.End); t = t.Add(d) {
+			slots = append(slots, Interval{Start: t, End: t.Add(d)})
+		}
+	}
+	return slots
+}
+
+func earliest(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func latest(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// RoomAvailability lists the free times of one room
+type RoomAvailability struct {
+	RoomID uint       `json:"room_id"`
+	Name   string     `json:"name"`
+	Free   []Interval `json:"free"`
+}
@@ -0,0 +1,167 @@
+package scheduling
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"oxo-game-api/internal/models"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidTransition  = errors.New("reservation cannot change to this status")
+	ErrCancellationClosed = errors.New("the cancellation window for this reservation has closed")
+	ErrCheckInClosed      = errors.New("check-in is not open for this reservation")
+)
+
+// transitions lists the statuses each status can move to
+var transitions = map[string][]string{
+	models.ReservationBooked:    {models.ReservationCheckedIn, models.ReservationCancelled, models.ReservationNoShow},
+	models.ReservationCheckedIn: {models.ReservationCompleted},
+}
+
+// CanTransition reports whether a reservation can move from one status to another
+func CanTransition(from, to string) bool {
+	for _, status := range transitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// RecordBooked logs the creation of a reservation
+func RecordBooked(tx *gorm.DB, reservation *models.Reservation, actorID uint) error {
+	return recordTransition(tx, reservation.ID, "", models.ReservationBooked, actorID, "", reservation.CreatedAt)
+}
+
+// Transition moves the reservation to status and logs the change. actorID is
+// zero for changes made by the sweeper.
+func Transition(tx *gorm.DB, reservation *models.Reservation, to string, actorID uint, reason string, now time.Time) error {
+	from := reservation.Status
+	if !CanTransition(from, to) {
+		return fmt.Errorf("%w: %s to %s", ErrInvalidTransition, from, to)
+	}
+
+	updates := map[string]interface{}{"status": to, "updated_at": now}
+	switch to {
+	case models.ReservationCheckedIn:
+		updates["checked_in_at"] = now
+	case models.ReservationCancelled:
+		updates["cancelled_at"] = now
+		updates["cancel_reason"] = reason
+		updates["sequence"] = gorm.Expr("sequence + 1")
+	}
+
+	result := tx.Model(&models.Reservation{}).Where("id = ? AND status = ?", reservation.ID, from).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("%w: it is no longer %s", ErrInvalidTransition, from)
+	}
+
+	reservation.Status = to
+	reservation.UpdatedAt = now
+	switch to {
+	case models.ReservationCheckedIn:
+		reservation.CheckedInAt = &now
+	case models.ReservationCancelled:
+		reservation.CancelledAt = &now
+		reservation.CancelReason = reason
+		reservation.Sequence++
+	}
+	return recordTransition(tx, reservation.ID, from, to, actorID, reason, now)
+}
+
+// Cancel cancels a booked reservation. Unless override is set, it has to be
+// done at least window before the start.
+func Cancel(tx *gorm.DB, reservation *models.Reservation, actorID uint, reason string, window time.Duration, override bool, now time.Time) error {
+	if !override && reservation.Status == models.ReservationBooked && now.After(reservation.StartsAt.Add(-window)) {
+		return ErrCancellationClosed
+	}
+	return Transition(tx, reservation, models.ReservationCancelled, actorID, reason, now)
+}
+
+// CheckIn checks the player in, from opens before the start until the end
+func CheckIn(tx *gorm.DB, reservation *models.Reservation, actorID uint, opens time.Duration, now time.Time) error {
+	if reservation.Status == models.ReservationBooked &&
+		(now.Before(reservation.StartsAt.Add(-opens)) || !now.Before(reservation.EndsAt)) {
+		return ErrCheckInClosed
+	}
+	return Transition(tx, reservation, models.ReservationCheckedIn, actorID, "", now)
+}
+
+// Sweep marks booked reservations nobody checked in to within grace as
+// no-shows and completes checked-in reservations that have ended
+func Sweep(db *gorm.DB, grace time.Duration, now time.Time) (int, error) {
+	swept := 0
+	for _, step := range []struct {
+		query  string
+		cutoff time.Time
+		from   string
+		to     string
+		reason string
+	}{
+		{"status = ? AND starts_at < ?", now.Add(-grace), models.ReservationBooked, models.ReservationNoShow, "no check-in within the grace period"},
+		{"status = ? AND ends_at <= ?", now, models.ReservationCheckedIn, models.ReservationCompleted, "reservation ended"},
+	} {
+		var due []models.Reservation
+		if err := db.Where(step.query, step.from, step.cutoff).Order("id").Find(&due).Error; err != nil {
+			return swept, err
+		}
+		for i := range due {
+			err := db.Transaction(func(tx *gorm.DB) error {
+				return Transition(tx, &due[i], step.to, 0, step.reason, now)
+			})
+			if errors.Is(err, ErrInvalidTransition) {
+				continue
+			}
+			if err != nil {
+				return swept, err
+			}
+			swept++
+		}
+	}
+	return swept, nil
+}
+
+// StartSweeper runs Sweep, ExpireOffers and SendReminders every interval in
+// the background
+func StartSweeper(db *gorm.DB, interval, grace, confirmWindow, reminderLead time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := Sweep(db, grace, time.Now()); err != nil {
+				log.Printf("Error sweeping reservations: %v", err)
+			}
+			if _, err := ExpireOffers(db, confirmWindow, time.Now()); err != nil {
+				log.Printf("Error expiring waitlist offers: %v", err)
+			}
+			if _, err := SendReminders(db, reminderLead, time.Now()); err != nil {
+				log.Printf("Error sending reservation reminders: %v", err)
+			}
+		}
+	}()
+}
+
+func recordTransition(tx *gorm.DB, reservationID uint, from, to string, actorID uint, reason string, at time.Time) error {
+	transition := models.ReservationTransition{
+		ReservationID: reservationID,
+		FromStatus:    from,
+		ToStatus:      to,
+		Reason:        reason,
+		CreatedAt:     at,
+	}
+	if actorID != 0 {
+		transition.ActorID = &actorID
+	}
+	if err := tx.Create(&transition).Error; err != nil {
+		return fmt.Errorf("failed to record reservation transition: %w", err)
+	}
+	return nil
+}
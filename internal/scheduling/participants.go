@@ -0,0 +1,157 @@
+package scheduling
+
+import (
+	"errors"
+	"time"
+
+	"oxo-game-api/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrNotInvited      = errors.New("player is not invited to this reservation")
+	ErrAlreadyAnswered = errors.New("invitation has already been answered")
+	ErrReservationFull = errors.New("reservation is full")
+	ErrNotJoinable     = errors.New("reservation is no longer open to join")
+	ErrRoomTooSmall    = errors.New("room is too small for the players who joined")
+)
+
+// AddHost adds the player who made the reservation as its first participant
+func AddHost(tx *gorm.DB, reservation *models.Reservation) error {
+	host := models.ReservationParticipant{
+		ReservationID: reservation.ID,
+		PlayerID:      reservation.PlayerID,
+		Role:          models.ParticipantHost,
+		InvitedBy:     reservation.PlayerID,
+		Status:        models.InvitationAccepted,
+		RespondedAt:   &reservation.CreatedAt,
+		CreatedAt:     reservation.CreatedAt,
+		UpdatedAt:     reservation.CreatedAt,
+	}
+	return tx.Create(&host).Error
+}
+
+// Invite adds pending invitations for the players, players already taking
+// part are left as they are
+func Invite(tx *gorm.DB, reservation *models.Reservation, playerIDs []uint, invitedBy uint, now time.Time) error {
+	if !joinable(reservation) {
+		return ErrNotJoinable
+	}
+
+	room, err := roomOf(tx, reservation)
+	if err != nil {
+		return err
+	}
+	joined, err := Joined(tx, reservation.ID)
+	if err != nil {
+		return err
+	}
+	if joined >= int64(room.Capacity) {
+		return ErrReservationFull
+	}
+
+	invitations := make([]models.ReservationParticipant, 0, len(playerIDs))
+	for _, playerID := range playerIDs {
+		invitations = append(invitations, models.ReservationParticipant{
+			ReservationID: reservation.ID,
+			PlayerID:      playerID,
+			Role:          models.ParticipantInvitee,
+			InvitedBy:     invitedBy,
+			Status:        models.InvitationPending,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		})
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&invitations).Error
+}
+
+// Respond accepts or declines the player's invitation to the reservation.
+// Accepting fails once the room is full.
+func Respond(db *gorm.DB, reservationID, playerID uint, accept bool, now time.Time) (*models.ReservationParticipant, error) {
+	var participant models.ReservationParticipant
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var reservation models.Reservation
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&reservation, reservationID).Error; err != nil {
+			return err
+		}
+
+		err := tx.Where("reservation_id = ? AND player_id = ? AND role = ?", reservationID, playerID, models.ParticipantInvitee).
+			First(&participant).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotInvited
+		}
+		if err != nil {
+			return err
+		}
+		if participant.Status != models.InvitationPending {
+			return ErrAlreadyAnswered
+		}
+
+		participant.Status = models.InvitationDeclined
+		if accept {
+			if !joinable(&reservation) {
+				return ErrNotJoinable
+			}
+			room, err := roomOf(tx, &reservation)
+			if err != nil {
+				return err
+			}
+			joined, err := Joined(tx, reservation.ID)
+			if err != nil {
+				return err
+			}
+			if joined >= int64(room.Capacity) {
+				return ErrReservationFull
+			}
+			participant.Status = models.InvitationAccepted
+		}
+
+		participant.RespondedAt = &now
+		participant.UpdatedAt = now
+		return tx.Save(&participant).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &participant, nil
+}
+
+// Joined counts the participants who have accepted, the host included
+func Joined(tx *gorm.DB, reservationID uint) (int64, error) {
+	var joined int64
+	err := tx.Model(&models.ReservationParticipant{}).
+		Where("reservation_id = ? AND status = ?", reservationID, models.InvitationAccepted).
+		Count(&joined).Error
+	return joined, err
+}
+
+// MostJoined returns the largest number of players who joined an upcoming
+// reservation of the room, a room cannot shrink below it
+func MostJoined(tx *gorm.DB, roomID uint) (int64, error) {
+	var counts []int64
+	err := tx.Model(&models.ReservationParticipant{}).
+		Joins("JOIN reservations ON reservations.id = reservation_participants.reservation_id").
+		Where("reservations.room_id = ? AND reservations.status IN ? AND reservation_participants.status = ?",
+			roomID, []string{models.ReservationBooked, models.ReservationCheckedIn}, models.InvitationAccepted).
+		Group("reservation_participants.reservation_id").
+		Order("count(*) desc").Limit(1).
+		Pluck("count(*)", &counts).Error
+	if err != nil || len(counts) == 0 {
+		return 0, err
+	}
+	return counts[0], nil
+}
+
+func joinable(reservation *models.Reservation) bool {
+	return reservation.Status == models.ReservationBooked || reservation.Status == models.ReservationCheckedIn
+}
+
+func roomOf(tx *gorm.DB, reservation *models.Reservation) (*models.Room, error) {
+	var room models.Room
+	if err := tx.Unscoped().First(&room, reservation.RoomID).Error; err != nil {
+		return nil, err
+	}
+	return &room, nil
+}
@@ -0,0 +1,52 @@
+package scheduling
+
+import (
+	"time"
+
+	"oxo-game-api/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EventReservationReminder is published to the players of a booked
+// reservation that starts soon
+const EventReservationReminder = "reservation.reminder"
+
+// SendReminders reminds the players of booked reservations starting within
+// lead. A reservation is reminded once per start time, so one that is moved
+// to a later time is reminded again before the new start.
+func SendReminders(db *gorm.DB, lead time.Duration, now time.Time) (int, error) {
+	sent := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var due []models.Reservation
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND starts_at > ? AND starts_at <= ?", models.ReservationBooked, now, now.Add(lead)).
+			Where("reminded_for IS NULL OR reminded_for <> starts_at").
+			Order("starts_at, id").Find(&due).Error; err != nil {
+			return err
+		}
+
+		for i := range due {
+			reservation := &due[i]
+			if err := notifyParticipants(tx, reservation, EventReservationReminder, map[string]interface{}{
+				"reservation_id": reservation.ID,
+				"room_id":        reservation.RoomID,
+				"starts_at":      reservation.StartsAt,
+				"ends_at":        reservation.EndsAt,
+			}); err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Reservation{}).Where("id = ?", reservation.ID).
+				Update("reminded_for", reservation.StartsAt).Error; err != nil {
+				return err
+			}
+			sent++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return sent, nil
+}
@@ -0,0 +1,103 @@
+package scheduling
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"oxo-game-api/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// OverlapConstraint is the exclusion constraint that keeps reservations of a
+// room from overlapping
+const OverlapConstraint = "reservations_no_overlap"
+
+var (
+	ErrRoomUnavailable = errors.New("room is not taking reservations")
+	ErrMaintenance     = errors.New("room is under maintenance at this time")
+)
+
+// ConflictError reports the reservation already holding part of a slot
+type ConflictError struct {
+	ReservationID uint
+}
+
+func (e *ConflictError) Error() string {
+	if e.ReservationID == 0 {
+		return "room is already reserved for this time"
+	}
+	return fmt.Sprintf("room is already reserved for this time by reservation %d", e.ReservationID)
+}
+
+// RoomHours returns the opening hours of the room
+func RoomHours(room *models.Room) (Hours, error) {
+	opens, closes, timezone := room.OpensAt, room.ClosesAt, room.Timezone
+	if opens == "" {
+		opens = DefaultOpensAt
+	}
+	if closes == "" {
+		closes = DefaultClosesAt
+	}
+	if timezone == "" {
+		timezone = DefaultTimezone
+	}
+	return ParseHours(opens, closes, timezone)
+}
+
+// CheckSlot validates the slot against the room and looks for reservations
+// already holding part of it, ignoring the reservation excludeID
+func CheckSlot(db *gorm.DB, room *models.Room, slot Interval, excludeID uint, now time.Time) error {
+	return checkSlot(db, room, slot, []uint{excludeID}, now)
+}
+
+// checkSlot is CheckSlot ignoring every reservation in exclude
+func checkSlot(db *gorm.DB, room *models.Room, slot Interval, exclude []uint, now time.Time) error {
+	if len(exclude) == 0 {
+		exclude = []uint{0}
+	}
+	hours, err := RoomHours(room)
+	if err != nil {
+		return err
+	}
+	if err := Validate(slot, hours, now); err != nil {
+		return err
+	}
+	if !room.Bookable() {
+		return ErrRoomUnavailable
+	}
+
+	var blocked int64
+	if err := db.Model(&models.MaintenanceWindow{}).
+		Where("room_id = ? AND starts_at < ? AND ends_at > ?", room.ID, slot.End, slot.Start).
+		Count(&blocked).Error; err != nil {
+		return err
+	}
+	if blocked > 0 {
+		return ErrMaintenance
+	}
+
+	var existing models.Reservation
+	err = db.Where("room_id = ? AND id NOT IN ? AND status IN ? AND starts_at < ? AND ends_at > ?",
+		room.ID, exclude, models.ActiveReservationStatuses, slot.End, slot.Start).
+		Order("starts_at").First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return &ConflictError{ReservationID: existing.ID}
+}
+
+// IsOverlapViolation reports whether err comes from the database rejecting an
+// overlapping reservation, the check in CheckSlot can lose that race
+func IsOverlapViolation(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "23P01") || strings.Contains(msg, OverlapConstraint)
+}
@@ -0,0 +1,155 @@
+package scheduling
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"oxo-game-api/internal/models"
+	"oxo-game-api/internal/outbox"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Events published to the players of reservations in a deleted room
+const (
+	EventReservationMoved     = "reservation.moved"
+	EventReservationCancelled = "reservation.cancelled"
+)
+
+var ErrInvalidRoomStatus = errors.New("room cannot change to this status")
+
+// roomTransitions lists the statuses each room status can move to. Rooms
+// under maintenance or closed have to be made available before they are used.
+var roomTransitions = map[string][]string{
+	models.RoomAvailable:   {models.RoomOccupied, models.RoomMaintenance, models.RoomClosed},
+	models.RoomOccupied:    {models.RoomAvailable, models.RoomMaintenance, models.RoomClosed},
+	models.RoomMaintenance: {models.RoomAvailable, models.RoomClosed},
+	models.RoomClosed:      {models.RoomAvailable, models.RoomMaintenance},
+}
+
+// CheckRoomStatus reports whether a room can move from one status to another,
+// keeping the current status is always allowed
+func CheckRoomStatus(from, to string) error {
+	if from == to {
+		return nil
+	}
+	for _, status := range roomTransitions[from] {
+		if status == to {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s to %s", ErrInvalidRoomStatus, from, to)
+}
+
+// VacateRoom clears the room of its upcoming reservations before it is
+// deleted. Booked reservations move to target when one is given and their
+// slot is free there for all their players, the others are cancelled.
+// Waiting players are taken off the room's waitlist. Every player taking part
+// in a moved or cancelled reservation gets an event.
+func VacateRoom(tx *gorm.DB, room, target *models.Room, actorID uint, now time.Time) (moved, cancelled []uint, err error) {
+	var upcoming []models.Reservation
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("room_id = ? AND status = ? AND starts_at > ?", room.ID, models.ReservationBooked, now).
+		Order("starts_at").Find(&upcoming).Error; err != nil {
+		return nil, nil, err
+	}
+
+	moved, cancelled = []uint{}, []uint{}
+	for i := range upcoming {
+		reservation := &upcoming[i]
+		ok, err := moveReservation(tx, reservation, target, now)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		eventType := EventReservationMoved
+		if ok {
+			moved = append(moved, reservation.ID)
+		} else {
+			if err := Transition(tx, reservation, models.ReservationCancelled, actorID, "room was deleted", now); err != nil {
+				return nil, nil, err
+			}
+			if err := tx.Model(&models.WaitlistEntry{}).
+				Where("reservation_id = ? AND status = ?", reservation.ID, models.WaitlistOffered).
+				Updates(map[string]interface{}{"status": models.WaitlistCancelled, "updated_at": now}).Error; err != nil {
+				return nil, nil, err
+			}
+			cancelled = append(cancelled, reservation.ID)
+			eventType = EventReservationCancelled
+		}
+
+		if err := notifyParticipants(tx, reservation, eventType, map[string]interface{}{
+			"reservation_id": reservation.ID,
+			"from_room_id":   room.ID,
+			"room_id":        reservation.RoomID,
+			"starts_at":      reservation.StartsAt,
+			"ends_at":        reservation.EndsAt,
+			"status":         reservation.Status,
+		}); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	err = tx.Model(&models.WaitlistEntry{}).
+		Where("room_id = ? AND status = ?", room.ID, models.WaitlistWaiting).
+		Updates(map[string]interface{}{"status": models.WaitlistCancelled, "updated_at": now}).Error
+	return moved, cancelled, err
+}
+
+// moveReservation moves the reservation to target if its slot is free there
+// and the room is large enough, it reports whether it did
+func moveReservation(tx *gorm.DB, reservation *models.Reservation, target *models.Room, now time.Time) (bool, error) {
+	if target == nil {
+		return false, nil
+	}
+
+	slot := Interval{Start: reservation.StartsAt, End: reservation.EndsAt}
+	if err := CheckSlot(tx, target, slot, reservation.ID, now); err != nil {
+		if stillTaken(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	joined, err := Joined(tx, reservation.ID)
+	if err != nil {
+		return false, err
+	}
+	if joined > int64(target.Capacity) {
+		return false, nil
+	}
+
+	if err := tx.Model(&models.Reservation{}).Where("id = ?", reservation.ID).Updates(map[string]interface{}{
+		"room_id":    target.ID,
+		"sequence":   gorm.Expr("sequence + 1"),
+		"updated_at": now,
+	}).Error; err != nil {
+		return false, err
+	}
+	if err := tx.Model(&models.WaitlistEntry{}).Where("reservation_id = ?", reservation.ID).
+		Update("room_id", target.ID).Error; err != nil {
+		return false, err
+	}
+	reservation.RoomID = target.ID
+	reservation.Sequence++
+	reservation.UpdatedAt = now
+	return true, nil
+}
+
+// notifyParticipants publishes the event to the host and every player who
+// has not declined the reservation
+func notifyParticipants(tx *gorm.DB, reservation *models.Reservation, eventType string, payload interface{}) error {
+	var playerIDs []uint
+	if err := tx.Model(&models.ReservationParticipant{}).
+		Where("reservation_id = ? AND status <> ?", reservation.ID, models.InvitationDeclined).
+		Order("id").Pluck("player_id", &playerIDs).Error; err != nil {
+		return err
+	}
+	for _, playerID := range playerIDs {
+		if err := outbox.Publish(tx, eventType, playerID, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
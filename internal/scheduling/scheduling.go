@@ -0,0 +1,121 @@
+// Package scheduling validates reservation time slots against the clock and
+// the opening hours of rooms.
+package scheduling
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxDuration is the longest slot a single reservation can hold
+const MaxDuration = 12 * time.Hour
+
+// Opening hours of rooms that do not set their own
+const (
+	DefaultOpensAt  = "00:00"
+	DefaultClosesAt = "24:00"
+	DefaultTimezone = "UTC"
+)
+
+var (
+	ErrInPast       = errors.New("reservation must start in the future")
+	ErrEmptySlot    = errors.New("reservation must end after it starts")
+	ErrTooLong      = fmt.Errorf("reservation cannot last more than %s", MaxDuration)
+	ErrOutsideHours = errors.New("reservation is outside the room's opening hours")
+)
+
+// Interval is a half-open time range [Start, End)
+type Interval struct {
+	Start time.Time `json:"starts_at"`
+	End   time.Time `json:"ends_at"`
+}
+
+// Overlaps reports whether the two intervals share any instant
+func (i Interval) Overlaps(other Interval) bool {
+	return i.Start.Before(other.End) && other.Start.Before(i.End)
+}
+
+// Duration returns the length of the interval
+func (i Interval) Duration() time.Duration {
+	return i.End.Sub(i.Start)
+}
+
+// Hours are the daily opening hours of a room, as minutes since midnight in
+// the room's time zone
+type Hours struct {
+	Opens    int
+	Closes   int
+	Location *time.Location
+}
+
+// ParseHours validates opening hours given as "HH:MM" clock times, closes
+// may be "24:00", and an IANA time zone
+func ParseHours(opens, closes, timezone string) (Hours, error) {
+	var hours Hours
+	var err error
+
+	if hours.Opens, err = parseClock(opens); err != nil {
+		return hours, fmt.Errorf("invalid opening time: %w", err)
+	}
+	if hours.Closes, err = parseClock(closes); err != nil {
+		return hours, fmt.Errorf("invalid closing time: %w", err)
+	}
+	if hours.Closes <= hours.Opens {
+		return hours, errors.New("closing time must be after opening time")
+	}
+	if hours.Location, err = time.LoadLocation(timezone); err != nil || timezone == "" || timezone == "Local" {
+		return hours, fmt.Errorf("invalid time zone %q", timezone)
+	}
+	return hours, nil
+}
+
+// Contains reports whether the slot starts and ends within the opening hours
+// of a single day. The hours are wall clock times, so they stay put on days
+// daylight saving time starts or ends.
+func (h Hours) Contains(slot Interval) bool {
+	start := slot.Start.In(h.Location)
+	year, month, day := start.Date()
+
+	opens := time.Date(year, month, day, h.Opens/60, h.Opens%60, 0, 0, h.Location)
+	closes := time.Date(year, month, day, h.Closes/60, h.Closes%60, 0, 0, h.Location)
+	return !start.Before(opens) && !slot.End.After(closes)
+}
+
+// Validate checks that a slot can be booked at now in a room open during hours
+func Validate(slot Interval, hours Hours, now time.Time) error {
+	if !slot.End.After(slot.Start) {
+		return ErrEmptySlot
+	}
+	if !slot.Start.After(now) {
+		return ErrInPast
+	}
+	if slot.Duration() > MaxDuration {
+		return ErrTooLong
+	}
+	if !hours.Contains(slot) {
+		return ErrOutsideHours
+	}
+	return nil
+}
+
+func parseClock(value string) (int, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 2 || len(parts[0]) != 2 || len(parts[1]) != 2 {
+		return 0, fmt.Errorf("%q is not HH:MM", value)
+	}
+	hour, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, fmt.Errorf("%q is not HH:MM", value)
+	}
+	minute, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, fmt.Errorf("%q is not HH:MM", value)
+	}
+	if hour < 0 || minute < 0 || minute > 59 || hour > 24 || (hour == 24 && minute != 0) {
+		return 0, fmt.Errorf("%q is not a time of day", value)
+	}
+	return hour*60 + minute, nil
+}
@@ -0,0 +1,302 @@
+package scheduling
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"oxo-game-api/internal/models"
+	"oxo-game-api/pkg/utils/rrule"
+
+	"gorm.io/gorm"
+)
+
+// MaxOccurrences is the most reservations a single series can expand into
+const MaxOccurrences = 100
+
+var (
+	ErrNotInSeries      = errors.New("reservation is not part of this series")
+	ErrNoUpcoming       = errors.New("series has no upcoming occurrences that can be changed")
+	ErrInvalidStartTime = errors.New("start_time must be an HH:MM time of day")
+)
+
+// SeriesConflict is an occurrence of a series that cannot be booked
+type SeriesConflict struct {
+	StartsAt      time.Time `json:"starts_at"`
+	EndsAt        time.Time `json:"ends_at"`
+	Reason        string    `json:"reason"`
+	ReservationID uint      `json:"conflicting_reservation_id,omitempty"`
+}
+
+// SeriesConflictError lists every occurrence of a series that cannot be
+// booked. Series are booked all or nothing.
+type SeriesConflictError struct {
+	Conflicts []SeriesConflict
+}
+
+func (e *SeriesConflictError) Error() string {
+	return fmt.Sprintf("%d occurrences of the series cannot be booked", len(e.Conflicts))
+}
+
+// SeriesChange is an edit applied to the occurrences of a series. Nil fields
+// keep their current value. StartTime is an "HH:MM" clock time in the time
+// zone of the room.
+type SeriesChange struct {
+	Room            *models.Room
+	StartTime       *string
+	DurationMinutes *int
+}
+
+// Occurrences expands the recurrence rule from the first slot. The rule is
+// read in the time zone of the room, so a weekly 20:00 game stays at 20:00
+// across daylight saving changes.
+func Occurrences(room *models.Room, value string, first Interval) ([]Interval, error) {
+	hours, err := RoomHours(room)
+	if err != nil {
+		return nil, err
+	}
+	rule, err := rrule.Parse(value, hours.Location)
+	if err != nil {
+		return nil, err
+	}
+	starts, err := rule.All(first.Start.In(hours.Location), MaxOccurrences)
+	if err != nil {
+		if errors.Is(err, rrule.ErrTooMany) {
+			return nil, fmt.Errorf("%w, at most %d are allowed", err, MaxOccurrences)
+		}
+		return nil, err
+	}
+
+	slots := make([]Interval, len(starts))
+	for i, start := range starts {
+		slots[i] = Interval{Start: start.UTC(), End: start.Add(first.Duration()).UTC()}
+	}
+	return slots, nil
+}
+
+// BookSeries creates the series and a booked reservation for each slot. The
+// slots are checked first and nothing is booked when any of them conflicts.
+func BookSeries(tx *gorm.DB, room *models.Room, series *models.ReservationSeries, slots []Interval, actorID uint, now time.Time) ([]models.Reservation, error) {
+	if err := checkSeries(tx, room, slots, nil, now); err != nil {
+		return nil, err
+	}
+
+	series.RoomID = room.ID
+	series.StartsAt = slots[0].Start
+	series.DurationMinutes = int(slots[0].Duration() / time.Minute)
+	if err := tx.Create(series).Error; err != nil {
+		return nil, err
+	}
+
+	reservations := make([]models.Reservation, len(slots))
+	for i, slot := range slots {
+		reservations[i] = models.Reservation{
+			RoomID:    room.ID,
+			PlayerID:  series.PlayerID,
+			StartsAt:  slot.Start,
+			EndsAt:    slot.End,
+			Status:    models.ReservationBooked,
+			SeriesID:  &series.ID,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if err := tx.Create(&reservations[i]).Error; err != nil {
+			return nil, err
+		}
+		if err := AddHost(tx, &reservations[i]); err != nil {
+			return nil, err
+		}
+		if err := RecordBooked(tx, &reservations[i], actorID); err != nil {
+			return nil, err
+		}
+	}
+	return reservations, nil
+}
+
+// SplitSeries ends the series just before the occurrence starting at from
+// and moves that occurrence and the ones after it to a new series with the
+// same rule. It is used to edit "this and following" occurrences. Splitting
+// at the first occurrence returns the series itself.
+func SplitSeries(tx *gorm.DB, series *models.ReservationSeries, from time.Time) (*models.ReservationSeries, error) {
+	room, err := roomOf(tx, &models.Reservation{RoomID: series.RoomID})
+	if err != nil {
+		return nil, err
+	}
+	hours, err := RoomHours(room)
+	if err != nil {
+		return nil, err
+	}
+	rule, err := rrule.Parse(series.RRule, hours.Location)
+	if err != nil {
+		return nil, err
+	}
+
+	var before int64
+	if err := tx.Model(&models.Reservation{}).
+		Where("series_id = ? AND starts_at < ?", series.ID, from).Count(&before).Error; err != nil {
+		return nil, err
+	}
+	if before == 0 {
+		return series, nil
+	}
+
+	following := rule
+	if rule.Count > 0 {
+		following.Count = rule.Count - int(before)
+	}
+	next := &models.ReservationSeries{
+		RoomID:          series.RoomID,
+		PlayerID:        series.PlayerID,
+		RRule:           following.String(),
+		StartsAt:        from,
+		DurationMinutes: series.DurationMinutes,
+	}
+	if err := tx.Create(next).Error; err != nil {
+		return nil, err
+	}
+
+	rule.Count = 0
+	rule.Until = from.Add(-time.Second)
+	series.RRule = rule.String()
+	if err := tx.Model(series).Updates(map[string]interface{}{"rrule": series.RRule, "updated_at": time.Now()}).Error; err != nil {
+		return nil, err
+	}
+
+	if err := tx.Model(&models.Reservation{}).
+		Where("series_id = ? AND starts_at >= ?", series.ID, from).
+		Update("series_id", next.ID).Error; err != nil {
+		return nil, err
+	}
+	return next, nil
+}
+
+// EditSeries moves the occurrences to the room, start time and duration of
+// the change, each on the same day in the room's time zone. It fails with a
+// SeriesConflictError listing every occurrence that does not fit, without
+// moving any. It returns the slots the occurrences held before.
+func EditSeries(tx *gorm.DB, series *models.ReservationSeries, occurrences []models.Reservation, change SeriesChange, now time.Time) ([]Interval, error) {
+	if len(occurrences) == 0 {
+		return nil, ErrNoUpcoming
+	}
+	room := change.Room
+	if room == nil {
+		var err error
+		if room, err = roomOf(tx, &models.Reservation{RoomID: series.RoomID}); err != nil {
+			return nil, err
+		}
+	}
+	hours, err := RoomHours(room)
+	if err != nil {
+		return nil, err
+	}
+
+	duration := time.Duration(series.DurationMinutes) * time.Minute
+	if change.DurationMinutes != nil {
+		duration = time.Duration(*change.DurationMinutes) * time.Minute
+	}
+	clock := -1
+	if change.StartTime != nil {
+		if clock, err = parseClock(*change.StartTime); err != nil || clock >= 24*60 {
+			return nil, ErrInvalidStartTime
+		}
+	}
+
+	retime := func(start time.Time) time.Time {
+		if clock < 0 {
+			return start
+		}
+		local := start.In(hours.Location)
+		day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, hours.Location)
+		return time.Date(day.Year(), day.Month(), day.Day(), clock/60, clock%60, 0, 0, hours.Location).UTC()
+	}
+
+	slots := make([]Interval, len(occurrences))
+	previous := make([]Interval, len(occurrences))
+	ids := make([]uint, len(occurrences))
+	for i, occurrence := range occurrences {
+		start := retime(occurrence.StartsAt)
+		slots[i] = Interval{Start: start, End: start.Add(duration)}
+		previous[i] = Interval{Start: occurrence.StartsAt, End: occurrence.EndsAt}
+		ids[i] = occurrence.ID
+	}
+	if err := checkSeries(tx, room, slots, ids, now); err != nil {
+		return nil, err
+	}
+
+	// an occurrence may move into the old slot of the next one, so the
+	// overlap constraint is only checked once every occurrence has moved
+	if err := tx.Exec("SET CONSTRAINTS " + OverlapConstraint + " DEFERRED").Error; err != nil {
+		return nil, err
+	}
+
+	for i := range occurrences {
+		if room.ID != occurrences[i].RoomID {
+			joined, err := Joined(tx, occurrences[i].ID)
+			if err != nil {
+				return nil, err
+			}
+			if joined > int64(room.Capacity) {
+				return nil, ErrRoomTooSmall
+			}
+		}
+		if err := tx.Model(&models.Reservation{}).Where("id = ?", occurrences[i].ID).Updates(map[string]interface{}{
+			"room_id":    room.ID,
+			"starts_at":  slots[i].Start,
+			"ends_at":    slots[i].End,
+			"sequence":   gorm.Expr("sequence + 1"),
+			"updated_at": now,
+		}).Error; err != nil {
+			return nil, err
+		}
+		occurrences[i].RoomID = room.ID
+		occurrences[i].StartsAt = slots[i].Start
+		occurrences[i].EndsAt = slots[i].End
+		occurrences[i].UpdatedAt = now
+		occurrences[i].Sequence++
+	}
+	if err := tx.Exec("SET CONSTRAINTS " + OverlapConstraint + " IMMEDIATE").Error; err != nil {
+		return nil, err
+	}
+
+	series.RoomID = room.ID
+	series.StartsAt = retime(series.StartsAt)
+	series.DurationMinutes = int(duration / time.Minute)
+	if err := tx.Model(series).Updates(map[string]interface{}{
+		"room_id":          series.RoomID,
+		"starts_at":        series.StartsAt,
+		"duration_minutes": series.DurationMinutes,
+		"updated_at":       now,
+	}).Error; err != nil {
+		return nil, err
+	}
+	return previous, nil
+}
+
+// checkSeries checks every slot like CheckSlot and collects the ones taken
+// by other reservations or maintenance. The reservations in exclude are the
+// occurrences being moved, they do not conflict with themselves.
+func checkSeries(tx *gorm.DB, room *models.Room, slots []Interval, exclude []uint, now time.Time) error {
+	if len(slots) == 0 {
+		return errors.New("recurrence rule has no occurrences")
+	}
+
+	var conflicts []SeriesConflict
+	for _, slot := range slots {
+		err := checkSlot(tx, room, slot, exclude, now)
+		var conflict *ConflictError
+		switch {
+		case err == nil:
+			continue
+		case errors.As(err, &conflict):
+			conflicts = append(conflicts, SeriesConflict{StartsAt: slot.Start, EndsAt: slot.End, Reason: err.Error(), ReservationID: conflict.ReservationID})
+		case errors.Is(err, ErrMaintenance), errors.Is(err, ErrOutsideHours), errors.Is(err, ErrInPast):
+			conflicts = append(conflicts, SeriesConflict{StartsAt: slot.Start, EndsAt: slot.End, Reason: err.Error()})
+		default:
+			return err
+		}
+	}
+	if len(conflicts) > 0 {
+		return &SeriesConflictError{Conflicts: conflicts}
+	}
+	return nil
+}
@@ -0,0 +1,268 @@
+package scheduling
+
+import (
+	"errors"
+	"time"
+
+	"oxo-game-api/internal/models"
+	"oxo-game-api/internal/outbox"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Events published for waitlisted players
+const (
+	EventWaitlistPromoted = "waitlist.promoted"
+	EventWaitlistExpired  = "waitlist.expired"
+)
+
+var (
+	ErrAlreadyWaiting = errors.New("player is already on the waitlist for this slot")
+	ErrNotWaiting     = errors.New("waitlist entry has no pending offer")
+	ErrOfferExpired   = errors.New("the offer for this waitlist entry has expired")
+	ErrEntryClosed    = errors.New("waitlist entry is no longer active")
+)
+
+// JoinWaitlist queues the entry behind the players already waiting
+func JoinWaitlist(tx *gorm.DB, entry *models.WaitlistEntry) error {
+	var count int64
+	if err := tx.Model(&models.WaitlistEntry{}).
+		Where("room_id = ? AND player_id = ? AND status IN ? AND starts_at < ? AND ends_at > ?",
+			entry.RoomID, entry.PlayerID, []string{models.WaitlistWaiting, models.WaitlistOffered}, entry.EndsAt, entry.StartsAt).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrAlreadyWaiting
+	}
+
+	entry.Status = models.WaitlistWaiting
+	return tx.Create(entry).Error
+}
+
+// LevelPriority ranks the player's level on the level ladder, higher levels
+// go first on the waitlist when priority by level is turned on
+func LevelPriority(tx *gorm.DB, playerID uint) (int, error) {
+	var rank int64
+	err := tx.Model(&models.Level{}).
+		Where("min_exp < (?)", tx.Model(&models.Level{}).Select("levels.min_exp").
+			Joins("JOIN players ON players.level_id = levels.id").Where("players.id = ?", playerID)).
+		Count(&rank).Error
+	return int(rank), err
+}
+
+// ReleaseSlot is called once a reservation stops holding its slot. It closes
+// the waitlist offer the reservation came from and promotes the next
+// waiting players.
+func ReleaseSlot(tx *gorm.DB, reservation *models.Reservation, confirmWindow time.Duration, now time.Time) (int, error) {
+	if err := tx.Model(&models.WaitlistEntry{}).
+		Where("reservation_id = ? AND status = ?", reservation.ID, models.WaitlistOffered).
+		Updates(map[string]interface{}{"status": models.WaitlistCancelled, "updated_at": now}).Error; err != nil {
+		return 0, err
+	}
+	return Promote(tx, reservation.RoomID, Interval{Start: reservation.StartsAt, End: reservation.EndsAt}, confirmWindow, now)
+}
+
+// Promote books the freed slot for the waiting players in order, by priority
+// and then by join time. Each promoted player gets a reservation and has
+// until the confirmation deadline to confirm it. Entries whose slot is still
+// taken keep waiting.
+func Promote(tx *gorm.DB, roomID uint, freed Interval, confirmWindow time.Duration, now time.Time) (int, error) {
+	var room models.Room
+	if err := tx.Unscoped().First(&room, roomID).Error; err != nil {
+		return 0, err
+	}
+
+	var waiting []models.WaitlistEntry
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("room_id = ? AND status = ? AND starts_at < ? AND ends_at > ?", roomID, models.WaitlistWaiting, freed.End, freed.Start).
+		Order("priority desc, created_at, id").Find(&waiting).Error; err != nil {
+		return 0, err
+	}
+
+	promoted := 0
+	for i := range waiting {
+		entry := &waiting[i]
+		if !entry.StartsAt.After(now) {
+			if err := tx.Model(entry).Updates(map[string]interface{}{"status": models.WaitlistExpired, "updated_at": now}).Error; err != nil {
+				return promoted, err
+			}
+			continue
+		}
+
+		err := tx.Transaction(func(tx *gorm.DB) error {
+			return offer(tx, &room, entry, confirmWindow, now)
+		})
+		if stillTaken(err) {
+			continue
+		}
+		if err != nil {
+			return promoted, err
+		}
+		promoted++
+	}
+	return promoted, nil
+}
+
+// ConfirmOffer keeps the reservation the player was promoted to
+func ConfirmOffer(tx *gorm.DB, entry *models.WaitlistEntry, now time.Time) error {
+	if entry.Status != models.WaitlistOffered {
+		return ErrNotWaiting
+	}
+	if entry.OfferExpiresAt != nil && now.After(*entry.OfferExpiresAt) {
+		return ErrOfferExpired
+	}
+
+	entry.Status = models.WaitlistConfirmed
+	entry.UpdatedAt = now
+	return tx.Model(entry).Updates(map[string]interface{}{"status": entry.Status, "updated_at": now}).Error
+}
+
+// LeaveWaitlist takes the player off the waitlist. A pending offer is
+// declined, its reservation is cancelled and passed on to the next player.
+func LeaveWaitlist(tx *gorm.DB, entry *models.WaitlistEntry, actorID uint, confirmWindow time.Duration, now time.Time) error {
+	switch entry.Status {
+	case models.WaitlistWaiting:
+	case models.WaitlistOffered:
+		if err := dropOffer(tx, entry, actorID, "waitlist offer declined", confirmWindow, now); err != nil {
+			return err
+		}
+	default:
+		return ErrEntryClosed
+	}
+
+	entry.Status = models.WaitlistCancelled
+	entry.UpdatedAt = now
+	return tx.Model(entry).Updates(map[string]interface{}{"status": entry.Status, "updated_at": now}).Error
+}
+
+// ExpireOffers cancels the reservations of promoted players who did not
+// confirm in time and promotes the next players
+func ExpireOffers(db *gorm.DB, confirmWindow time.Duration, now time.Time) (int, error) {
+	var due []models.WaitlistEntry
+	if err := db.Where("status = ? AND offer_expires_at <= ?", models.WaitlistOffered, now).Order("id").Find(&due).Error; err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for i := range due {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			entry := &due[i]
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(entry, entry.ID).Error; err != nil {
+				return err
+			}
+			if entry.Status != models.WaitlistOffered {
+				return ErrNotWaiting
+			}
+			if err := dropOffer(tx, entry, 0, "waitlist offer not confirmed in time", confirmWindow, now); err != nil {
+				return err
+			}
+			if err := tx.Model(entry).Updates(map[string]interface{}{"status": models.WaitlistExpired, "updated_at": now}).Error; err != nil {
+				return err
+			}
+			return outbox.Publish(tx, EventWaitlistExpired, entry.PlayerID, map[string]interface{}{
+				"waitlist_entry_id": entry.ID,
+				"room_id":           entry.RoomID,
+				"starts_at":         entry.StartsAt,
+				"ends_at":           entry.EndsAt,
+			})
+		})
+		if errors.Is(err, ErrNotWaiting) {
+			continue
+		}
+		if err != nil {
+			return expired, err
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+// offer makes the reservation for a waiting player and publishes the offer
+func offer(tx *gorm.DB, room *models.Room, entry *models.WaitlistEntry, confirmWindow time.Duration, now time.Time) error {
+	slot := Interval{Start: entry.StartsAt, End: entry.EndsAt}
+	if err := CheckSlot(tx, room, slot, 0, now); err != nil {
+		return err
+	}
+
+	reservation := models.Reservation{
+		RoomID:    room.ID,
+		PlayerID:  entry.PlayerID,
+		StartsAt:  entry.StartsAt,
+		EndsAt:    entry.EndsAt,
+		Status:    models.ReservationBooked,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := tx.Create(&reservation).Error; err != nil {
+		return err
+	}
+	if err := AddHost(tx, &reservation); err != nil {
+		return err
+	}
+	if err := recordTransition(tx, reservation.ID, "", models.ReservationBooked, 0, "promoted from the waitlist", now); err != nil {
+		return err
+	}
+
+	deadline := now.Add(confirmWindow)
+	if deadline.After(entry.StartsAt) {
+		deadline = entry.StartsAt
+	}
+	entry.Status = models.WaitlistOffered
+	entry.ReservationID = &reservation.ID
+	entry.OfferExpiresAt = &deadline
+	entry.UpdatedAt = now
+	if err := tx.Model(entry).Updates(map[string]interface{}{
+		"status":           entry.Status,
+		"reservation_id":   reservation.ID,
+		"offer_expires_at": deadline,
+		"updated_at":       now,
+	}).Error; err != nil {
+		return err
+	}
+
+	return outbox.Publish(tx, EventWaitlistPromoted, entry.PlayerID, map[string]interface{}{
+		"waitlist_entry_id": entry.ID,
+		"reservation_id":    reservation.ID,
+		"room_id":           room.ID,
+		"starts_at":         entry.StartsAt,
+		"ends_at":           entry.EndsAt,
+		"confirm_by":        deadline,
+	})
+}
+
+// dropOffer cancels the reservation held for a promoted player and passes
+// the slot on
+func dropOffer(tx *gorm.DB, entry *models.WaitlistEntry, actorID uint, reason string, confirmWindow time.Duration, now time.Time) error {
+	if entry.ReservationID == nil {
+		return nil
+	}
+
+	var reservation models.Reservation
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&reservation, *entry.ReservationID).Error; err != nil {
+		return err
+	}
+	if reservation.Status != models.ReservationBooked {
+		return nil
+	}
+	if err := Transition(tx, &reservation, models.ReservationCancelled, actorID, reason, now); err != nil {
+		return err
+	}
+
+	// the entry is closed by the caller, keep ReleaseSlot from touching it
+	if err := tx.Model(entry).Update("status", models.WaitlistCancelled).Error; err != nil {
+		return err
+	}
+	_, err := ReleaseSlot(tx, &reservation, confirmWindow, now)
+	return err
+}
+
+// stillTaken reports whether err means the waiting player cannot have the
+// slot yet
+func stillTaken(err error) bool {
+	var conflict *ConflictError
+	return errors.As(err, &conflict) || IsOverlapViolation(err) ||
+		errors.Is(err, ErrMaintenance) || errors.Is(err, ErrRoomUnavailable) ||
+		errors.Is(err, ErrOutsideHours) || errors.Is(err, ErrInPast)
+}
@@ -0,0 +1,325 @@
+// Package social manages friendships between players, their privacy settings
+// and the room presence derived from enter/leave game logs.
+package social
+
+import (
+	"errors"
+	"time"
+
+	"oxo-game-api/internal/models"
+	"oxo-game-api/internal/outbox"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EventFriendRequest is published to the addressee of a new friend request
+const EventFriendRequest = "friendship.requested"
+
+var (
+	ErrSelfRequest        = errors.New("players cannot befriend themselves")
+	ErrBlocked            = errors.New("friendship is blocked")
+	ErrRequestsClosed     = errors.New("player does not accept friend requests")
+	ErrAlreadyFriends     = errors.New("players are already friends")
+	ErrRequestPending     = errors.New("friend request already sent")
+	ErrFriendshipNotFound = errors.New("friendship not found")
+	ErrNotAddressee       = errors.New("only the addressee can answer a friend request")
+	ErrNotPending         = errors.New("friend request is not pending")
+)
+
+// Between returns the friendship row of a pair of players, in either
+// direction, or nil when they never interacted
+func Between(db *gorm.DB, a, b uint) (*models.Friendship, error) {
+	var friendship models.Friendship
+	err := db.Where("(requester_id = ? AND addressee_id = ?) OR (requester_id = ? AND addressee_id = ?)", a, b, b, a).
+		First(&friendship).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &friendship, nil
+}
+
+// AreFriends reports whether two players have an accepted friendship
+func AreFriends(db *gorm.DB, a, b uint) (bool, error) {
+	friendship, err := Between(db, a, b)
+	if err != nil || friendship == nil {
+		return false, err
+	}
+	return friendship.Status == models.FriendshipAccepted, nil
+}
+
+// SendRequest asks to befriend a player and notifies them. A pending request
+// in the other direction is accepted instead, a declined one can be sent again.
+func SendRequest(db *gorm.DB, from, to uint) (*models.Friendship, error) {
+	if from == to {
+		return nil, ErrSelfRequest
+	}
+
+	var result *models.Friendship
+	err := db.Transaction(func(tx *gorm.DB) error {
+		friendship, err := Between(tx.Clauses(clause.Locking{Strength: "UPDATE"}), from, to)
+		if err != nil {
+			return err
+		}
+
+		if friendship != nil {
+			switch friendship.Status {
+			case models.FriendshipBlocked:
+				return ErrBlocked
+			case models.FriendshipAccepted:
+				return ErrAlreadyFriends
+			case models.FriendshipPending:
+				if friendship.RequesterID == from {
+					return ErrRequestPending
+				}
+				respond(friendship, models.FriendshipAccepted)
+				result = friendship
+				return tx.Save(friendship).Error
+			}
+		}
+
+		settings, err := Settings(tx, to)
+		if err != nil {
+			return err
+		}
+		if settings.FriendRequests == models.FriendRequestsNobody {
+			return ErrRequestsClosed
+		}
+
+		if friendship == nil {
+			friendship = &models.Friendship{}
+		}
+		friendship.RequesterID = from
+		friendship.AddresseeID = to
+		friendship.Status = models.FriendshipPending
+		friendship.RespondedAt = nil
+		result = friendship
+		if err := tx.Save(friendship).Error; err != nil {
+			return err
+		}
+		return outbox.Publish(tx, EventFriendRequest, to, map[string]interface{}{
+			"friendship_id": friendship.ID,
+			"requester_id":  from,
+		})
+	})
+	return result, err
+}
+
+// Respond accepts or declines a pending request addressed to playerID
+func Respond(db *gorm.DB, id, playerID uint, accept bool) (*models.Friendship, error) {
+	var friendship models.Friendship
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := lockFriendship(tx, id, &friendship); err != nil {
+			return err
+		}
+		if friendship.AddresseeID != playerID {
+			return ErrNotAddressee
+		}
+		if friendship.Status != models.FriendshipPending {
+			return ErrNotPending
+		}
+
+		status := models.FriendshipDeclined
+		if accept {
+			status = models.FriendshipAccepted
+		}
+		respond(&friendship, status)
+		return tx.Save(&friendship).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &friendship, nil
+}
+
+// Block stops all contact between two players until the blocker removes the
+// friendship. Blocking also ends an existing friendship.
+func Block(db *gorm.DB, blocker, target uint) (*models.Friendship, error) {
+	if blocker == target {
+		return nil, ErrSelfRequest
+	}
+
+	var result *models.Friendship
+	err := db.Transaction(func(tx *gorm.DB) error {
+		friendship, err := Between(tx.Clauses(clause.Locking{Strength: "UPDATE"}), blocker, target)
+		if err != nil {
+			return err
+		}
+		if friendship == nil {
+			friendship = &models.Friendship{RequesterID: blocker, AddresseeID: target}
+		}
+		result = friendship
+		if friendship.Status == models.FriendshipBlocked {
+			return nil
+		}
+
+		friendship.Status = models.FriendshipBlocked
+		friendship.BlockedBy = &blocker
+		return tx.Save(friendship).Error
+	})
+	return result, err
+}
+
+// Remove deletes a friendship on behalf of one of its players: it unfriends,
+// withdraws a request or, for the blocker only, lifts a block
+func Remove(db *gorm.DB, id, playerID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var friendship models.Friendship
+		if err := lockFriendship(tx, id, &friendship); err != nil {
+			return err
+		}
+		if friendship.RequesterID != playerID && friendship.AddresseeID != playerID {
+			return ErrFriendshipNotFound
+		}
+		if friendship.Status == models.FriendshipBlocked && *friendship.BlockedBy != playerID {
+			return ErrBlocked
+		}
+		return tx.Delete(&friendship).Error
+	})
+}
+
+// Friends lists the accepted friendships of a player
+func Friends(db *gorm.DB, playerID uint) ([]models.Friendship, error) {
+	var friendships []models.Friendship
+	err := db.Where("(requester_id = ? OR addressee_id = ?) AND status = ?", playerID, playerID, models.FriendshipAccepted).
+		Order("responded_at desc, id desc").
+		Find(&friendships).Error
+	return friendships, err
+}
+
+// Friend is an entry of a player's friend list as seen by a viewer. RoomID
+// is only filled when the friend lets the viewer see their presence.
+type Friend struct {
+	PlayerID     uint       `json:"player_id"`
+	Name         string     `json:"name"`
+	FriendshipID uint       `json:"friendship_id"`
+	Since        *time.Time `json:"since"`
+	RoomID       *uint      `json:"room_id,omitempty"`
+}
+
+// FriendList returns the friends of owner as viewer may see them
+func FriendList(db *gorm.DB, owner, viewer uint) ([]Friend, error) {
+	friendships, err := Friends(db, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint, 0, len(friendships))
+	for _, friendship := range friendships {
+		ids = append(ids, friendship.Other(owner))
+	}
+
+	var players []models.Player
+	if err := db.Where("id IN ?", ids).Find(&players).Error; err != nil {
+		return nil, err
+	}
+	names := make(map[uint]string, len(players))
+	for _, player := range players {
+		names[player.ID] = player.Name
+	}
+
+	presence, err := Presence(db, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	friends := make([]Friend, 0, len(friendships))
+	for _, friendship := range friendships {
+		id := friendship.Other(owner)
+		name, ok := names[id]
+		if !ok {
+			continue
+		}
+		friend := Friend{
+			PlayerID:     id,
+			Name:         name,
+			FriendshipID: friendship.ID,
+			Since:        friendship.RespondedAt,
+		}
+
+		if roomID, inRoom := presence[id]; inRoom {
+			settings, err := Settings(db, id)
+			if err != nil {
+				return nil, err
+			}
+			visible, err := CanSee(db, settings.PresenceVisibility, viewer, id)
+			if err != nil {
+				return nil, err
+			}
+			if visible {
+				friend.RoomID = &roomID
+			}
+		}
+		friends = append(friends, friend)
+	}
+	return friends, nil
+}
+
+// Settings returns the privacy settings of a player, defaults included
+func Settings(db *gorm.DB, playerID uint) (models.PrivacySettings, error) {
+	settings := models.DefaultPrivacySettings(playerID)
+	err := db.Where("player_id = ?", playerID).First(&settings).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return settings, err
+	}
+	return settings, nil
+}
+
+// CanSee reports whether viewer may see data of owner protected by visibility
+func CanSee(db *gorm.DB, visibility string, viewer, owner uint) (bool, error) {
+	if viewer == owner {
+		return true, nil
+	}
+	switch visibility {
+	case models.VisibilityPublic:
+		return true, nil
+	case models.VisibilityFriends:
+		return AreFriends(db, viewer, owner)
+	default:
+		return false, nil
+	}
+}
+
+// Presence maps each of the players currently inside a room to that room,
+// based on their latest enter or leave log
+func Presence(db *gorm.DB, playerIDs []uint) (map[uint]uint, error) {
+	presence := make(map[uint]uint)
+	if len(playerIDs) == 0 {
+		return presence, nil
+	}
+
+	var latest []models.GameLog
+	err := db.Model(&models.GameLog{}).
+		Select("DISTINCT ON (player_id) player_id, action, room_id").
+		Where("player_id IN ? AND action IN ? AND room_id IS NOT NULL",
+			playerIDs, []string{models.ActionEnterRoom, models.ActionLeaveRoom}).
+		Order("player_id, timestamp desc, id desc").
+		Find(&latest).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range latest {
+		if entry.Action == models.ActionEnterRoom {
+			presence[entry.PlayerID] = *entry.RoomID
+		}
+	}
+	return presence, nil
+}
+
+func lockFriendship(tx *gorm.DB, id uint, friendship *models.Friendship) error {
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(friendship, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrFriendshipNotFound
+	}
+	return err
+}
+
+func respond(friendship *models.Friendship, status string) {
+	now := time.Now()
+	friendship.Status = status
+	friendship.RespondedAt = &now
+}
@@ -0,0 +1,92 @@
+package migrations
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// ON DELETE policies of the foreign keys
+const (
+	OnDeleteRestrict = "RESTRICT"
+	OnDeleteCascade  = "CASCADE"
+	OnDeleteSetNull  = "SET NULL"
+)
+
+// deleteActions are the pg_constraint.confdeltype codes of the policies
+var deleteActions = map[string]string{
+	OnDeleteRestrict: "r",
+	OnDeleteCascade:  "c",
+	OnDeleteSetNull:  "n",
+}
+
+// ForeignKey is a column pointing at the id of another table
+type ForeignKey struct {
+	Name       string
+	Table      string
+	Column     string
+	References string
+	OnDelete   string
+}
+
+// ForeignKeys are the references the database enforces. Players and rooms are
+// soft deleted, so the policies only apply when a row is purged. History a
+// player paid for blocks the purge, derived rows go with their parent.
+var ForeignKeys = []ForeignKey{
+	{Name: "fk_reservations_room", Table: "reservations", Column: "room_id", References: "rooms", OnDelete: OnDeleteRestrict},
+	{Name: "fk_reservations_player", Table: "reservations", Column: "player_id", References: "players", OnDelete: OnDeleteRestrict},
+	{Name: "fk_challenges_player", Table: "challenges", Column: "player_id", References: "players", OnDelete: OnDeleteRestrict},
+	{Name: "fk_challenge_results_challenge", Table: "challenge_results", Column: "challenge_id", References: "challenges", OnDelete: OnDeleteCascade},
+	{Name: "fk_challenge_results_player", Table: "challenge_results", Column: "player_id", References: "players", OnDelete: OnDeleteRestrict},
+	{Name: "fk_game_logs_player", Table: "game_logs", Column: "player_id", References: "players", OnDelete: OnDeleteCascade},
+	{Name: "fk_game_logs_room", Table: "game_logs", Column: "room_id", References: "rooms", OnDelete: OnDeleteSetNull},
+	{Name: "fk_payments_player", Table: "payments", Column: "player_id", References: "players", OnDelete: OnDeleteRestrict},
+	{Name: "fk_notifications_player", Table: "notifications", Column: "player_id", References: "players", OnDelete: OnDeleteCascade},
+}
+
+// constraintSQL adds the foreign key, replacing a constraint of the same name
+// with another ON DELETE policy, such as the ones gorm creates. The key is
+// added NOT VALID so orphans left from before do not fail the migration, the
+// integrity command reports them and validates the key once they are gone.
+func (fk ForeignKey) constraintSQL() string {
+	return fmt.Sprintf(`DO $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM pg_constraint WHERE conname = '%[1]s' AND conrelid = '%[2]s'::regclass AND confdeltype <> '%[6]s') THEN
+		ALTER TABLE %[2]s DROP CONSTRAINT %[1]s;
+	END IF;
+	IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = '%[1]s' AND conrelid = '%[2]s'::regclass) THEN
+		ALTER TABLE %[2]s ADD CONSTRAINT %[1]s
+			FOREIGN KEY (%[3]s) REFERENCES %[4]s (id) ON DELETE %[5]s NOT VALID;
+	END IF;
+END $$`, fk.Name, fk.Table, fk.Column, fk.References, fk.OnDelete, deleteActions[fk.OnDelete])
+}
+
+// Orphans are the rows of a foreign key pointing at a record that does not
+// exist
+type Orphans struct {
+	ForeignKey
+	IDs []uint
+}
+
+// FindOrphans scans every foreign key for rows with a dangling reference
+func FindOrphans(db *gorm.DB) ([]Orphans, error) {
+	var found []Orphans
+	for _, fk := range ForeignKeys {
+		var ids []uint
+		query := fmt.Sprintf(`SELECT t.id FROM %[1]s t LEFT JOIN %[3]s r ON r.id = t.%[2]s
+			WHERE t.%[2]s IS NOT NULL AND r.id IS NULL ORDER BY t.id`, fk.Table, fk.Column, fk.References)
+		if err := db.Raw(query).Scan(&ids).Error; err != nil {
+			return nil, fmt.Errorf("scan %s: %w", fk.Name, err)
+		}
+		if len(ids) > 0 {
+			found = append(found, Orphans{ForeignKey: fk, IDs: ids})
+		}
+	}
+	return found, nil
+}
+
+// ValidateForeignKey checks the existing rows of a key added NOT VALID, it
+// fails while the key has orphans
+func ValidateForeignKey(db *gorm.DB, fk ForeignKey) error {
+	return db.Exec(fmt.Sprintf("ALTER TABLE %s VALIDATE CONSTRAINT %s", fk.Table, fk.Name)).Error
+}
@@ -7,14 +7,141 @@ import (
 )
 
 func Migrate(db *gorm.DB) error {
-	return db.AutoMigrate(
+	// names used to be unique across soft-deleted rows too, uniqueness is now
+	// a partial index over live rows and reservation is up to ApplyNamePolicy
+	for _, stmt := range []string{
+		"ALTER TABLE IF EXISTS players DROP CONSTRAINT IF EXISTS players_name_key",
+		"ALTER TABLE IF EXISTS rooms DROP CONSTRAINT IF EXISTS rooms_name_key",
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+
+	// reservations used to keep the booking time as "date" and "time" strings,
+	// legacy rows become zero-length slots so they never block a room
+	if err := db.Exec(`DO $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'reservations' AND column_name = 'date') THEN
+		ALTER TABLE reservations ADD COLUMN IF NOT EXISTS starts_at timestamptz, ADD COLUMN IF NOT EXISTS ends_at timestamptz;
+		UPDATE reservations SET starts_at = ("date" || ' ' || "time")::timestamp AT TIME ZONE 'UTC';
+		UPDATE reservations SET ends_at = starts_at;
+		ALTER TABLE reservations DROP COLUMN "date", DROP COLUMN "time";
+	END IF;
+END $$`).Error; err != nil {
+		return err
+	}
+
+	// reservations had no status before, the ones already over are taken as
+	// completed so the no-show sweeper leaves them alone
+	if err := db.Exec(`DO $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'reservations')
+		AND NOT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'reservations' AND column_name = 'status') THEN
+		ALTER TABLE reservations ADD COLUMN status varchar(20) NOT NULL DEFAULT 'booked';
+		UPDATE reservations SET status = 'completed' WHERE starts_at <= now();
+	END IF;
+END $$`).Error; err != nil {
+		return err
+	}
+
+	// reservation invitations became participants, with a row for the host
+	if err := db.Exec(`DO $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'reservation_invitations')
+		AND NOT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'reservation_participants') THEN
+		ALTER TABLE reservation_invitations RENAME TO reservation_participants;
+		ALTER INDEX IF EXISTS idx_invitations_reservation_player RENAME TO idx_participants_reservation_player;
+		ALTER INDEX IF EXISTS idx_reservation_invitations_player_id RENAME TO idx_reservation_participants_player_id;
+	END IF;
+END $$`).Error; err != nil {
+		return err
+	}
+
+	if err := db.AutoMigrate(
 		&models.Level{},
 		&models.Player{},
+		&models.LevelChange{},
 		&models.Reservation{},
 		&models.Room{},
 		&models.Challenge{},
 		&models.ChallengeResult{},
 		&models.GameLog{},
 		&models.Payment{},
-	)
+		&models.LeaderboardEntry{},
+		&models.AuthToken{},
+		&models.APIKey{},
+		&models.ErasureJob{},
+		&models.Sanction{},
+		&models.Friendship{},
+		&models.PrivacySettings{},
+		&models.ReservationParticipant{},
+		&models.PlayerProfile{},
+		&models.MaintenanceWindow{},
+		&models.ReservationTransition{},
+		&models.WaitlistEntry{},
+		&models.OutboxEvent{},
+		&models.ReservationSeries{},
+		&models.CalendarFeed{},
+		&models.Notification{},
+	); err != nil {
+		return err
+	}
+
+	if err := db.Exec(`INSERT INTO reservation_participants (reservation_id, player_id, role, invited_by, status, responded_at, created_at, updated_at)
+		SELECT r.id, r.player_id, 'host', r.player_id, 'accepted', r.created_at, r.created_at, r.created_at FROM reservations r
+		WHERE NOT EXISTS (SELECT 1 FROM reservation_participants p WHERE p.reservation_id = r.id AND p.role = 'host')
+		ON CONFLICT DO NOTHING`).Error; err != nil {
+		return err
+	}
+
+	// room status used to be free text, the seeds used "Active". Statuses
+	// that never took reservations become closed.
+	if err := db.Exec(`UPDATE rooms SET status = CASE
+		WHEN lower(status) IN ('available', 'occupied', 'maintenance', 'closed') THEN lower(status)
+		WHEN lower(status) = 'active' THEN 'available'
+		ELSE 'closed' END
+		WHERE status NOT IN ('available', 'occupied', 'maintenance', 'closed')`).Error; err != nil {
+		return err
+	}
+
+	return CreateConstraints(db)
+}
+
+// CreateConstraints adds the constraints gorm tags cannot express
+func CreateConstraints(db *gorm.DB) error {
+	for _, stmt := range []string{
+		"CREATE EXTENSION IF NOT EXISTS btree_gist",
+		// cancelled reservations and no-shows give their slot back, series
+		// edits defer the check until all occurrences have moved
+		`DO $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'reservations_no_overlap' AND (pg_get_constraintdef(oid) NOT LIKE '%WHERE%' OR NOT condeferrable)) THEN
+		ALTER TABLE reservations DROP CONSTRAINT reservations_no_overlap;
+	END IF;
+	IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'reservations_no_overlap') THEN
+		ALTER TABLE reservations ADD CONSTRAINT reservations_no_overlap
+			EXCLUDE USING gist (room_id WITH =, tstzrange(starts_at, ends_at, '[)') WITH &&)
+			WHERE (status IN ('booked', 'checked_in', 'completed'))
+			DEFERRABLE INITIALLY IMMEDIATE;
+	END IF;
+END $$`,
+		`DO $$
+BEGIN
+	IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'rooms_status_check') THEN
+		ALTER TABLE rooms ADD CONSTRAINT rooms_status_check
+			CHECK (status IN ('available', 'occupied', 'maintenance', 'closed'));
+	END IF;
+END $$`,
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	for _, fk := range ForeignKeys {
+		if err := db.Exec(fk.constraintSQL()).Error; err != nil {
+			return err
+		}
+	}
+	return nil
 }
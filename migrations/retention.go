@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"fmt"
+
+	"oxo-game-api/config"
+
+	"gorm.io/gorm"
+)
+
+// nameTables are the tables whose names the deleted name policy applies to
+var nameTables = []string{"players", "rooms"}
+
+// ApplyNamePolicy makes the database enforce the deleted name policy. Live
+// names are always unique through the partial indexes on the models. With
+// the reserve policy a unique index over every row, soft-deleted ones
+// included, keeps deleted names taken. With the release policy it is dropped.
+// Switching to reserve fails while soft-deleted rows share a name with
+// another row, those have to be renamed or purged first.
+func ApplyNamePolicy(db *gorm.DB, policy string) error {
+	for _, table := range nameTables {
+		index := fmt.Sprintf("idx_%s_name_reserved", table)
+		stmt := fmt.Sprintf("DROP INDEX IF EXISTS %s", index)
+		if policy == config.NamePolicyReserve {
+			stmt = fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS %s ON %s (name)", index, table)
+		}
+		if err := db.Exec(stmt).Error; err != nil {
+			return fmt.Errorf("failed to apply the %s name policy to %s: %w", policy, table, err)
+		}
+	}
+	return nil
+}
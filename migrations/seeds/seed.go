@@ -2,8 +2,10 @@ package seeds
 
 import (
 	"log"
+	"oxo-game-api/config"
 	"oxo-game-api/internal/models"
 
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
@@ -57,12 +59,12 @@ func SeedRooms(db *gorm.DB) error {
 		{
 			Name:        "Beginner",
 			Description: "Starting level for new players",
-			Status:      "Active",
+			Status:      models.RoomAvailable,
 		},
 		{
 			Name:        "Intermediate",
 			Description: "Middle level",
-			Status:      "Active",
+			Status:      models.RoomAvailable,
 		},
 	}
 
@@ -74,3 +76,25 @@ func SeedRooms(db *gorm.DB) error {
 	}
 	return nil
 }
+
+// SeedAdmin bootstraps the first admin account from ADMIN_NAME/ADMIN_PASSWORD
+func SeedAdmin(db *gorm.DB, cfg *config.AuthConfig) error {
+	if cfg.AdminName == "" || cfg.AdminPassword == "" {
+		log.Println("ADMIN_NAME or ADMIN_PASSWORD not set, skip seeding admin")
+		return nil
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(cfg.AdminPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	admin := models.Player{
+		Name:         cfg.AdminName,
+		Role:         models.RoleAdmin,
+		PasswordHash: string(hash),
+	}
+	return db.Where("name = ?", admin.Name).
+		Attrs(admin).
+		FirstOrCreate(&admin).Error
+}
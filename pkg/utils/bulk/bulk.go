@@ -0,0 +1,263 @@
+// Package bulk reads and writes records as CSV or newline-delimited JSON.
+// CSV columns are matched to struct fields through their json tags.
+package bulk
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Supported formats
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// Media types of the supported formats
+const (
+	MIMECSV    = "text/csv"
+	MIMENDJSON = "application/x-ndjson"
+)
+
+var ErrUnsupportedFormat = errors.New("content must be text/csv or application/x-ndjson")
+
+// FormatOf returns the format of a request body from its Content-Type
+func FormatOf(contentType string) (string, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", ErrUnsupportedFormat
+	}
+	switch mediaType {
+	case MIMECSV:
+		return FormatCSV, nil
+	case MIMENDJSON, "application/ndjson", "application/jsonl":
+		return FormatNDJSON, nil
+	}
+	return "", ErrUnsupportedFormat
+}
+
+// ContentType returns the media type to send a format with
+func ContentType(format string) string {
+	if format == FormatCSV {
+		return MIMECSV + "; charset=utf-8"
+	}
+	return MIMENDJSON
+}
+
+// Decode calls fn for every record of r. Line is the 1-based line of the
+// record in the input, header included for CSV, and decode fills a struct
+// from the record. A decode error only concerns its record, while an error
+// returned by Decode means the input as a whole is unreadable.
+func Decode(r io.Reader, format string, fn func(line int, decode func(v interface{}) error) error) error {
+	if format == FormatCSV {
+		return decodeCSV(r, fn)
+	}
+	return decodeNDJSON(r, fn)
+}
+
+func decodeNDJSON(r io.Reader, fn func(int, func(interface{}) error) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		raw := strings.TrimSpace(scanner.Text())
+		if raw == "" {
+			continue
+		}
+		err := fn(line, func(v interface{}) error {
+			decoder := json.NewDecoder(strings.NewReader(raw))
+			decoder.DisallowUnknownFields()
+			return decoder.Decode(v)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func decodeCSV(r io.Reader, fn func(int, func(interface{}) error) error) error {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("invalid CSV header: %w", err)
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff"))
+	}
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) && errors.Is(parseErr.Err, csv.ErrFieldCount) {
+				if err := fn(parseErr.StartLine, func(interface{}) error { return parseErr.Err }); err != nil {
+					return err
+				}
+				continue
+			}
+			return err
+		}
+
+		line, _ := reader.FieldPos(0)
+		err = fn(line, func(v interface{}) error {
+			return assign(v, header, record)
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// assign sets the fields of the struct pointed to by v from a CSV record
+func assign(v interface{}, header, record []string) error {
+	value := reflect.ValueOf(v).Elem()
+	fields := fieldsByTag(value.Type())
+
+	for i, column := range header {
+		index, ok := fields[column]
+		if !ok {
+			return fmt.Errorf("unknown column %q", column)
+		}
+		raw := strings.TrimSpace(record[i])
+		if raw == "" {
+			continue
+		}
+		if err := setField(value.Field(index), raw); err != nil {
+			return fmt.Errorf("invalid %s: %w", column, err)
+		}
+	}
+	return nil
+}
+
+func setField(field reflect.Value, raw string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(raw, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
+
+// Encoder writes records to w in one of the supported formats
+type Encoder struct {
+	format  string
+	columns []string
+	csv     *csv.Writer
+	json    *json.Encoder
+	started bool
+}
+
+// NewEncoder returns an encoder writing the given json-tagged columns. NDJSON
+// records are written whole.
+func NewEncoder(w io.Writer, format string, columns []string) *Encoder {
+	encoder := &Encoder{format: format, columns: columns}
+	if format == FormatCSV {
+		encoder.csv = csv.NewWriter(w)
+	} else {
+		encoder.json = json.NewEncoder(w)
+	}
+	return encoder
+}
+
+// Encode writes one record, a struct or a pointer to one
+func (e *Encoder) Encode(v interface{}) error {
+	if e.json != nil {
+		return e.json.Encode(v)
+	}
+
+	if !e.started {
+		e.started = true
+		if err := e.csv.Write(e.columns); err != nil {
+			return err
+		}
+	}
+
+	value := reflect.Indirect(reflect.ValueOf(v))
+	fields := fieldsByTag(value.Type())
+	record := make([]string, len(e.columns))
+	for i, column := range e.columns {
+		index, ok := fields[column]
+		if !ok {
+			return fmt.Errorf("unknown column %q", column)
+		}
+		record[i] = formatField(value.Field(index))
+	}
+	return e.csv.Write(record)
+}
+
+// Flush writes buffered CSV records to the underlying writer
+func (e *Encoder) Flush() error {
+	if e.csv == nil {
+		return nil
+	}
+	e.csv.Flush()
+	return e.csv.Error()
+}
+
+func formatField(field reflect.Value) string {
+	if t, ok := field.Interface().(time.Time); ok {
+		return t.UTC().Format(time.RFC3339)
+	}
+	switch field.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(field.Float(), 'f', -1, field.Type().Bits())
+	default:
+		return fmt.Sprint(field.Interface())
+	}
+}
+
+func fieldsByTag(t reflect.Type) map[string]int {
+	fields := make(map[string]int, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			fields[name] = i
+		}
+	}
+	return fields
+}
@@ -0,0 +1,40 @@
+// Package etag derives entity tags from record versions and evaluates
+// If-Match preconditions.
+package etag
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Of returns the strong entity tag of a record version. The timestamp is
+// truncated to the microsecond precision Postgres stores it with, so the tag
+// of a freshly saved record matches the one it has once read back.
+func Of(id uint, updatedAt time.Time) string {
+	return fmt.Sprintf(`"%d-%d"`, id, updatedAt.Truncate(time.Microsecond).UnixMicro())
+}
+
+// Set sends the entity tag of the returned record
+func Set(c *gin.Context, tag string) {
+	c.Header("ETag", tag)
+}
+
+// Match reports whether the If-Match header of the request allows changing
+// the record tagged tag. Requests without If-Match always match, weak tags
+// never do.
+func Match(c *gin.Context, tag string) bool {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return true
+	}
+
+	for _, candidate := range strings.Split(header, ",") {
+		if strings.TrimSpace(candidate) == tag {
+			return true
+		}
+	}
+	return false
+}
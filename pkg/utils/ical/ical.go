@@ -0,0 +1,119 @@
+// Package ical writes RFC 5545 iCalendar feeds
+package ical
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ContentType is the media type of iCalendar data
+const ContentType = "text/calendar; charset=utf-8"
+
+// Event statuses
+const (
+	StatusConfirmed = "CONFIRMED"
+	StatusCancelled = "CANCELLED"
+)
+
+// maxLine is the longest content line allowed before folding, in octets
+const maxLine = 75
+
+// Calendar is a feed of events
+type Calendar struct {
+	ProdID string
+	Name   string
+	Events []Event
+}
+
+// Event is a VEVENT. UID must stay the same across updates of the event so
+// calendar apps replace it instead of adding a copy, and Sequence must grow
+// with every significant change.
+type Event struct {
+	UID          string
+	Sequence     int
+	Status       string
+	Start        time.Time
+	End          time.Time
+	Summary      string
+	Description  string
+	Location     string
+	Created      time.Time
+	LastModified time.Time
+}
+
+// Encode renders the calendar with CRLF line endings and long lines folded
+func (cal Calendar) Encode() []byte {
+	var b builder
+	b.line("BEGIN", "VCALENDAR")
+	b.line("VERSION", "2.0")
+	b.line("PRODID", cal.ProdID)
+	b.line("CALSCALE", "GREGORIAN")
+	b.line("METHOD", "PUBLISH")
+	if cal.Name != "" {
+		b.line("X-WR-CALNAME", escape(cal.Name))
+	}
+
+	for _, event := range cal.Events {
+		b.line("BEGIN", "VEVENT")
+		b.line("UID", escape(event.UID))
+		b.line("DTSTAMP", utc(event.LastModified))
+		b.line("DTSTART", utc(event.Start))
+		b.line("DTEND", utc(event.End))
+		b.line("SEQUENCE", strconv.Itoa(event.Sequence))
+		if event.Status != "" {
+			b.line("STATUS", event.Status)
+		}
+		b.line("SUMMARY", escape(event.Summary))
+		if event.Location != "" {
+			b.line("LOCATION", escape(event.Location))
+		}
+		if event.Description != "" {
+			b.line("DESCRIPTION", escape(event.Description))
+		}
+		if !event.Created.IsZero() {
+			b.line("CREATED", utc(event.Created))
+		}
+		b.line("LAST-MODIFIED", utc(event.LastModified))
+		b.line("END", "VEVENT")
+	}
+
+	b.line("END", "VCALENDAR")
+	return []byte(b.String())
+}
+
+type builder struct {
+	strings.Builder
+}
+
+// line writes a content line, folding it into continuation lines starting
+// with a space so that none is longer than 75 octets. Lines are only broken
+// between UTF-8 characters.
+func (b *builder) line(name, value string) {
+	content := name + ":" + value
+	limit := maxLine
+	for len(content) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(content[cut]) {
+			cut--
+		}
+		b.WriteString(content[:cut])
+		b.WriteString("\r\n ")
+		content = content[cut:]
+		limit = maxLine - 1
+	}
+	b.WriteString(content)
+	b.WriteString("\r\n")
+}
+
+func utc(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// escape quotes the characters with a meaning in TEXT values
+func escape(value string) string {
+	return escaper.Replace(value)
+}
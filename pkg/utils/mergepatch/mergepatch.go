@@ -0,0 +1,52 @@
+// Package mergepatch applies JSON merge patches as defined by RFC 7396.
+package mergepatch
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// ContentType is the media type of a JSON merge patch
+const ContentType = "application/merge-patch+json"
+
+var ErrInvalidPatch = errors.New("patch must be a JSON object")
+
+// Apply merges patch into the JSON document doc. Members set to null in the
+// patch are removed, objects are merged recursively and every other value
+// replaces the original one.
+func Apply(doc, patch []byte) ([]byte, error) {
+	var target interface{}
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+
+	var changes interface{}
+	if err := json.Unmarshal(patch, &changes); err != nil {
+		return nil, err
+	}
+	if _, ok := changes.(map[string]interface{}); !ok {
+		return nil, ErrInvalidPatch
+	}
+
+	return json.Marshal(merge(target, changes))
+}
+
+func merge(target, patch interface{}) interface{} {
+	changes, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	result, ok := target.(map[string]interface{})
+	if !ok {
+		result = make(map[string]interface{}, len(changes))
+	}
+	for key, value := range changes {
+		if value == nil {
+			delete(result, key)
+			continue
+		}
+		result[key] = merge(result[key], value)
+	}
+	return result
+}
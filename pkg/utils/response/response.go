@@ -1,6 +1,10 @@
 package response
 
-import "github.com/gin-gonic/gin"
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
 
 type Response struct {
 	Code    int         `json:"code"`
@@ -8,6 +12,14 @@ type Response struct {
 	Data    interface{} `json:"data"`
 }
 
+// Page wraps one page of a paginated list together with the total count
+type Page struct {
+	Items  interface{} `json:"items"`
+	Total  int64       `json:"total"`
+	Limit  int         `json:"limit"`
+	Offset int         `json:"offset"`
+}
+
 type PaymentError struct {
 	Code          int    `json:"code"`
 	TransactionID string `json:"transaction_id"`
@@ -21,6 +33,21 @@ type JoinResponse struct {
 	Message		string `json:"message"`
 }
 
+type LoginResponse struct {
+	Token     string `json:"token"`
+	PlayerID  uint   `json:"player_id"`
+	Role      string `json:"role"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+type APIKeyCreateResponse struct {
+	KeyID     uint       `json:"key_id"`
+	Key       string     `json:"key"`
+	Prefix    string     `json:"prefix"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
 type LevelCreateResponse struct{
 	LevelID 	uint 	`json:"level_id"`
 }
@@ -41,6 +68,23 @@ type RoomCreateResponse struct{
 	RoomID uint	`json:"room_id"`
 }
 
+// BulkRowResult reports the outcome of one imported record
+type BulkRowResult struct {
+	Line     int    `json:"line"`
+	Name     string `json:"name,omitempty"`
+	Status   string `json:"status"`
+	PlayerID uint   `json:"player_id,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+type BulkImportResponse struct {
+	Mode    string          `json:"mode"`
+	Total   int             `json:"total"`
+	Created int             `json:"created"`
+	Failed  int             `json:"failed"`
+	Rows    []BulkRowResult `json:"rows"`
+}
+
 func Success(c *gin.Context, data interface{}) {
 	c.JSON(200, Response{
 		Code:    200,
@@ -57,6 +101,43 @@ func Error(c *gin.Context, code int, message string) {
 	})
 }
 
+// ErrorWithData answers an error that carries details, like per-row results
+func ErrorWithData(c *gin.Context, code int, message string, data interface{}) {
+	c.JSON(code, Response{
+		Code:    code,
+		Message: message,
+		Data:    data,
+	})
+}
+
+// PermissionDenied aborts the request with the 403 payload shared by every access check
+func PermissionDenied(c *gin.Context, permission string) {
+	c.AbortWithStatusJSON(403, Response{
+		Code:    403,
+		Message: "Permission denied",
+		Data:    gin.H{"required_permission": permission},
+	})
+}
+
+// Sanctioned aborts the request of a banned or suspended player
+func Sanctioned(c *gin.Context, message string, sanction interface{}) {
+	c.AbortWithStatusJSON(403, Response{
+		Code:    403,
+		Message: message,
+		Data:    gin.H{"sanction": sanction},
+	})
+}
+
+// SanctionedLogin refuses the login of a banned or suspended player, the
+// session handed out instead can only read and appeal sanctions
+func SanctionedLogin(c *gin.Context, message string, sanction interface{}, session LoginResponse) {
+	c.AbortWithStatusJSON(403, Response{
+		Code:    403,
+		Message: message,
+		Data:    gin.H{"sanction": sanction, "appeal_session": session},
+	})
+}
+
 func PaymentErrorResponse(c *gin.Context, code int, transactionID, status, errorMessage string) {
 	c.JSON(code, PaymentError{
 		Code:          code,
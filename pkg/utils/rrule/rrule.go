@@ -0,0 +1,228 @@
+// Package rrule parses and expands the subset of RFC 5545 recurrence rules
+// used for recurring reservations: FREQ=DAILY or WEEKLY with INTERVAL, BYDAY,
+// COUNT and UNTIL.
+package rrule
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Supported frequencies
+const (
+	Daily  = "DAILY"
+	Weekly = "WEEKLY"
+)
+
+var (
+	ErrUnbounded = errors.New("recurrence rule needs COUNT or UNTIL")
+	ErrTooMany   = errors.New("recurrence rule has too many occurrences")
+	ErrNever     = errors.New("recurrence rule never falls on its BYDAY days")
+)
+
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// Rule is a parsed recurrence rule
+type Rule struct {
+	Freq     string
+	Interval int
+	Count    int
+	Until    time.Time
+	ByDay    []time.Weekday
+}
+
+// Parse reads a rule like "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=10", with or
+// without the "RRULE:" prefix. A floating or date-only UNTIL is read in loc.
+func Parse(value string, loc *time.Location) (Rule, error) {
+	rule := Rule{Interval: 1}
+	value = strings.TrimPrefix(strings.TrimSpace(value), "RRULE:")
+
+	for _, part := range strings.Split(value, ";") {
+		key, val, ok := strings.Cut(part, "=")
+		if !ok || val == "" {
+			return rule, fmt.Errorf("invalid recurrence rule part %q", part)
+		}
+
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			rule.Freq = strings.ToUpper(val)
+			if rule.Freq != Daily && rule.Freq != Weekly {
+				return rule, fmt.Errorf("unsupported FREQ %q, use DAILY or WEEKLY", val)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return rule, fmt.Errorf("invalid INTERVAL %q", val)
+			}
+			rule.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return rule, fmt.Errorf("invalid COUNT %q", val)
+			}
+			rule.Count = n
+		case "UNTIL":
+			until, err := parseUntil(val, loc)
+			if err != nil {
+				return rule, err
+			}
+			rule.Until = until
+		case "BYDAY":
+			for _, day := range strings.Split(strings.ToUpper(val), ",") {
+				weekday, ok := weekdays[day]
+				if !ok {
+					return rule, fmt.Errorf("invalid BYDAY %q", day)
+				}
+				rule.ByDay = append(rule.ByDay, weekday)
+			}
+		default:
+			return rule, fmt.Errorf("unsupported recurrence rule part %s", key)
+		}
+	}
+
+	if rule.Freq == "" {
+		return rule, errors.New("recurrence rule needs FREQ")
+	}
+	if rule.Count > 0 && !rule.Until.IsZero() {
+		return rule, errors.New("recurrence rule cannot have both COUNT and UNTIL")
+	}
+	if rule.Count == 0 && rule.Until.IsZero() {
+		return rule, ErrUnbounded
+	}
+	return rule, nil
+}
+
+// String formats the rule back into RRULE syntax, UNTIL in UTC
+func (r Rule) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, 0, len(r.ByDay))
+		for _, weekday := range r.ByDay {
+			for name, day := range weekdays {
+				if day == weekday {
+					days = append(days, name)
+				}
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	return strings.Join(parts, ";")
+}
+
+// All expands the rule from dtstart, keeping the wall clock time of dtstart
+// in its location across daylight saving changes. It fails with ErrTooMany
+// when the rule has more than max occurrences and with ErrNever when no day
+// it steps on is one of its BYDAY days.
+func (r Rule) All(dtstart time.Time, max int) ([]time.Time, error) {
+	if r.Count > max {
+		return nil, ErrTooMany
+	}
+
+	days := map[time.Weekday]bool{}
+	for _, weekday := range r.ByDay {
+		days[weekday] = true
+	}
+	if r.Freq == Weekly && len(days) == 0 {
+		days[dtstart.Weekday()] = true
+	}
+
+	var occurrences []time.Time
+	add := func(t time.Time) bool {
+		if t.Before(dtstart) {
+			return true
+		}
+		if !r.Until.IsZero() && t.After(r.Until) {
+			return false
+		}
+		if r.Count > 0 && len(occurrences) == r.Count {
+			return false
+		}
+		occurrences = append(occurrences, t)
+		return true
+	}
+
+	year, month, day := dtstart.Date()
+	hour, min, sec := dtstart.Clock()
+	at := func(offset int) time.Time {
+		return time.Date(year, month, day+offset, hour, min, sec, 0, dtstart.Location())
+	}
+
+	switch r.Freq {
+	case Daily:
+		// the weekdays stepped on repeat within 7 steps, a rule that misses
+		// BYDAY that often in a row never hits it
+		missed := 0
+		for i := 0; ; i += r.Interval {
+			t := at(i)
+			if len(days) > 0 && !days[t.Weekday()] {
+				if !r.Until.IsZero() && t.After(r.Until) {
+					return occurrences, nil
+				}
+				if missed++; missed == 7 {
+					return nil, ErrNever
+				}
+				continue
+			}
+			missed = 0
+			if !add(t) {
+				return occurrences, nil
+			}
+			if len(occurrences) > max {
+				return nil, ErrTooMany
+			}
+		}
+	default:
+		weekdayOrder := make([]int, 0, len(days))
+		for weekday := range days {
+			weekdayOrder = append(weekdayOrder, (int(weekday)+6)%7)
+		}
+		sort.Ints(weekdayOrder)
+
+		// weeks start on Monday, as with the RFC 5545 default WKST=MO
+		monday := -((int(dtstart.Weekday()) + 6) % 7)
+		for week := 0; ; week += r.Interval {
+			for _, offset := range weekdayOrder {
+				if !add(at(monday + week*7 + offset)) {
+					return occurrences, nil
+				}
+				if len(occurrences) > max {
+					return nil, ErrTooMany
+				}
+			}
+		}
+	}
+}
+
+func parseUntil(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("20060102T150405", value, loc); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("20060102", value, loc); err == nil {
+		return t.AddDate(0, 0, 1).Add(-time.Second), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid UNTIL %q", value)
+}
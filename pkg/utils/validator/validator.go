@@ -1,11 +1,19 @@
 package validator
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
 	"oxo-game-api/internal/models"
+	"oxo-game-api/pkg/utils/mergepatch"
 	"oxo-game-api/pkg/utils/response"
+	"reflect"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
@@ -28,21 +36,134 @@ type Validator struct {
 }
 
 type PlayerValidation struct {
-	Name    string `json:"name" binding:"required"`
-	LevelID int    `json:"level_id"`
+	Name     string `json:"name" binding:"required"`
+	LevelID  int    `json:"level_id"`
+	Password string `json:"password" binding:"omitempty,min=8,max=72"`
+}
+
+type LoginValidation struct {
+	Name     string `json:"name" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type APIKeyValidation struct {
+	Name      string     `json:"name" binding:"required,max=64"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type APIKeyRotateValidation struct {
+	Overlap string `json:"overlap"`
+}
+
+type ExperienceValidation struct {
+	Amount int    `json:"amount" binding:"required"`
+	Reason string `json:"reason" binding:"required,max=255"`
+}
+
+type RestoreValidation struct {
+	Name string `json:"name" binding:"omitempty,min=1,max=32"`
+}
+
+type RoleValidation struct {
+	Role string `json:"role" binding:"required,oneof=player operator admin"`
+}
+
+type SanctionValidation struct {
+	Kind      string     `json:"kind" binding:"required,oneof=ban suspension"`
+	Reason    string     `json:"reason" binding:"required,max=500"`
+	ExpiresAt *time.Time `json:"expires_at" binding:"required_if=Kind suspension"`
+}
+
+type AppealValidation struct {
+	Note string `json:"note" binding:"required,max=1000"`
+}
+
+type AppealDecisionValidation struct {
+	Status string `json:"status" binding:"required,oneof=accepted rejected"`
+}
+
+type FriendRequestValidation struct {
+	PlayerID uint `json:"player_id" binding:"required"`
+}
+
+type PrivacyValidation struct {
+	FriendRequests       string `json:"friend_requests" binding:"omitempty,oneof=everyone nobody"`
+	FriendListVisibility string `json:"friend_list_visibility" binding:"omitempty,oneof=public friends private"`
+	PresenceVisibility   string `json:"presence_visibility" binding:"omitempty,oneof=public friends private"`
+}
+
+type InvitationValidation struct {
+	PlayerIDs []uint `json:"player_ids" binding:"required,min=1,max=20,dive,required"`
+}
+
+type ProfileValidation struct {
+	DisplayName   *string                       `json:"display_name" binding:"omitempty,max=64"`
+	AvatarURL     *string                       `json:"avatar_url" binding:"omitempty,http_url,max=512"`
+	Locale        *string                       `json:"locale" binding:"omitempty,bcp47_language_tag"`
+	Timezone      *string                       `json:"timezone" binding:"omitempty,timezone"`
+	Email         *string                       `json:"email" binding:"omitempty,email,max=254"`
+	Notifications *NotificationPreferencesPatch `json:"notifications"`
+}
+
+type NotificationPreferencesPatch struct {
+	ReservationReminders *bool `json:"reservation_reminders"`
+	ChallengeResults     *bool `json:"challenge_results"`
+	PaymentUpdates       *bool `json:"payment_updates"`
+	FriendRequests       *bool `json:"friend_requests"`
 }
 
 type LevelValidation struct {
 	Name        string `json:"name" binding:"required"`
 	Description string `json:"description" binding:"required"`
-	MinExp      int    `json:"min_exp" binding:"required"`
-	MaxExp      int    `json:"max_exp" binding:"required"`
+	MinExp      *int   `json:"min_exp" binding:"required,min=0"`
+	MaxExp      *int   `json:"max_exp" binding:"required,min=0"`
+}
+
+type ReservationValidation struct {
+	RoomID          uint       `json:"room_id" binding:"required"`
+	PlayerID        uint       `json:"player_id"`
+	StartsAt        *time.Time `json:"starts_at" binding:"required"`
+	EndsAt          *time.Time `json:"ends_at"`
+	DurationMinutes int        `json:"duration_minutes" binding:"omitempty,min=1,max=720"`
+	Waitlist        bool       `json:"waitlist"`
+	RRule           string     `json:"rrule" binding:"max=255"`
+}
+
+type SeriesUpdateValidation struct {
+	RoomID            *uint   `json:"room_id"`
+	StartTime         *string `json:"start_time"`
+	DurationMinutes   *int    `json:"duration_minutes" binding:"omitempty,min=1,max=720"`
+	FromReservationID *uint   `json:"from_reservation_id"`
+}
+
+type SeriesCancelValidation struct {
+	Reason            string `json:"reason" binding:"max=255"`
+	FromReservationID *uint  `json:"from_reservation_id"`
+}
+
+type CancelValidation struct {
+	Reason string `json:"reason" binding:"max=255"`
+}
+
+type PresenceValidation struct {
+	PlayerID uint `json:"player_id"`
+}
+
+type MaintenanceValidation struct {
+	StartsAt time.Time `json:"starts_at" binding:"required"`
+	EndsAt   time.Time `json:"ends_at" binding:"required"`
+	Reason   string    `json:"reason" binding:"max=255"`
 }
 
 type RoomValidation struct {
 	Name        string `json:"name" binding:"required"`
 	Description string `json:"description" binding:"required"`
-	Status      string `json:"status" binding:"required"`
+	Status      string `json:"status" binding:"omitempty,oneof=available occupied maintenance closed"`
+	Capacity    int    `json:"capacity" binding:"omitempty,min=1,max=100"`
+	OpensAt     string `json:"opens_at"`
+	ClosesAt    string `json:"closes_at"`
+	Timezone    string `json:"timezone"`
 }
 
 func NewValidator(db *gorm.DB) *Validator {
@@ -72,6 +193,40 @@ func validateLevel(fl validator.FieldLevel) bool {
 	return level >= 0 && level <= 100
 }
 
+// ErrUnsupportedPatchType is returned by BindMergePatch for bodies that are
+// not JSON merge patches
+var ErrUnsupportedPatchType = errors.New("patch must be sent as application/merge-patch+json")
+
+// BindMergePatch applies the RFC 7396 merge patch in the request body to obj,
+// which holds the current state of the resource, then validates the result
+// like a full request body
+func BindMergePatch(c *gin.Context, obj interface{}) error {
+	mediaType, _, err := mime.ParseMediaType(c.ContentType())
+	if err != nil || (mediaType != mergepatch.ContentType && mediaType != binding.MIMEJSON) {
+		return ErrUnsupportedPatchType
+	}
+
+	patch, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return err
+	}
+	current, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	merged, err := mergepatch.Apply(current, patch)
+	if err != nil {
+		return err
+	}
+
+	value := reflect.ValueOf(obj).Elem()
+	value.Set(reflect.Zero(value.Type()))
+	if err := json.Unmarshal(merged, obj); err != nil {
+		return err
+	}
+	return binding.Validator.ValidateStruct(obj)
+}
+
 // get and validate player/room ID
 func GetParamID(c *gin.Context) (uint64, error) {
 	idStr := c.Param("id")
@@ -119,6 +274,66 @@ func FindRoomByID(db *gorm.DB, id uint64) (*models.Room, error) {
 	return &room, nil
 }
 
+// IsForeignKeyViolation reports whether err is the database rejecting a row
+// that references a record which does not exist
+func IsForeignKeyViolation(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "23503")
+}
+
+// IsUniqueViolation reports whether err is the database rejecting a row
+// whose unique value, such as a name, is already taken
+func IsUniqueViolation(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "23505")
+}
+
+// get and validate limit/offset pagination query parameters
+func GetPagination(c *gin.Context, defaultLimit, maxLimit int) (int, int, error) {
+	limit := defaultLimit
+	offset := 0
+
+	if value := c.Query("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > maxLimit {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxLimit)
+		}
+		limit = parsed
+	}
+
+	if value := c.Query("offset"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			return 0, 0, fmt.Errorf("offset must be a non-negative integer")
+		}
+		offset = parsed
+	}
+
+	return limit, offset, nil
+}
+
+// get the ORDER BY clause for a sort query parameter such as "name" or
+// "-created_at", only whitelisted fields are accepted
+func GetSortOrder(sort string, allowed map[string]string) (string, error) {
+	direction := "asc"
+	field := sort
+	if strings.HasPrefix(sort, "-") {
+		direction = "desc"
+		field = sort[1:]
+	}
+
+	column, ok := allowed[field]
+	if !ok {
+		return "", fmt.Errorf("Invalid sort field: %s", field)
+	}
+	return column + " " + direction, nil
+}
+
+// escape the LIKE wildcards of user input
+func EscapeLike(value string) string {
+	return likeEscaper.Replace(value)
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
 // check query parameters
 func CheckQueryParam(c *gin.Context, allowedParams map[string]bool) {
 	for key := range c.Request.URL.Query() {
@@ -131,14 +346,9 @@ func CheckQueryParam(c *gin.Context, allowedParams map[string]bool) {
 }
 
 func CheckActionValue(c *gin.Context) {
-	allowedActions := map[string]bool{
-		"註冊":   true,
-		"登入":   true,
-		"登出":   true,
-		"進入房間": true,
-		"退出房間": true,
-		"參加挑戰": true,
-		"挑戰結果": true,
+	allowedActions := make(map[string]bool, len(models.LogActions))
+	for _, action := range models.LogActions {
+		allowedActions[action] = true
 	}
 
 	if action := c.Query("action"); action != "" {
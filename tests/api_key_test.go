@@ -0,0 +1,169 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"oxo-game-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPIKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupTestDB()
+	router := SetupTestRouter(db)
+
+	_, adminToken := IssueToken(db, "Admin", models.RoleAdmin)
+	player, _ := IssueToken(db, "Logged Player", models.RolePlayer)
+
+	createKey := func(scopes []string) string {
+		body, _ := json.Marshal(map[string]interface{}{"name": "game-server", "scopes": scopes})
+		req, _ := http.NewRequest(http.MethodPost, "/api-keys", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", adminToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Data struct {
+				KeyID uint   `json:"key_id"`
+				Key   string `json:"key"`
+			} `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response.Data.Key
+	}
+
+	postLog := func(key string) int {
+		body, _ := json.Marshal(models.GameLog{PlayerID: player.ID, Action: "登入"})
+		req, _ := http.NewRequest(http.MethodPost, "/logs", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", key)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	t.Run("scoped key writes logs for a player", func(t *testing.T) {
+		key := createKey([]string{"logs:write"})
+		assert.Equal(t, http.StatusOK, postLog(key))
+
+		var stored models.APIKey
+		db.Where("prefix <> ''").First(&stored)
+		assert.NotNil(t, stored.LastUsedAt)
+	})
+
+	t.Run("key without scope is denied", func(t *testing.T) {
+		key := createKey([]string{"challenges:write"})
+		assert.Equal(t, http.StatusForbidden, postLog(key))
+	})
+
+	t.Run("unknown scope is rejected", func(t *testing.T) {
+		body, _ := json.Marshal(map[string]interface{}{"name": "bad", "scopes": []string{"everything"}})
+		req, _ := http.NewRequest(http.MethodPost, "/api-keys", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", adminToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("a key is rotated once", func(t *testing.T) {
+		body, _ := json.Marshal(map[string]interface{}{"name": "rotating", "scopes": []string{"logs:write"}})
+		req, _ := http.NewRequest(http.MethodPost, "/api-keys", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", adminToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var created struct {
+			Data struct {
+				KeyID uint   `json:"key_id"`
+				Key   string `json:"key"`
+			} `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+
+		rotate := func(id uint) int {
+			req, _ := http.NewRequest(http.MethodPost, "/api-keys/"+itoa(id)+"/rotate", nil)
+			req.Header.Set("Authorization", adminToken)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w.Code
+		}
+		assert.Equal(t, http.StatusOK, rotate(created.Data.KeyID))
+		assert.Equal(t, http.StatusConflict, rotate(created.Data.KeyID))
+		assert.Equal(t, http.StatusOK, postLog(created.Data.Key))
+	})
+
+	t.Run("a key with an expiry can be rotated", func(t *testing.T) {
+		expiresAt := time.Now().Add(time.Hour)
+		body, _ := json.Marshal(map[string]interface{}{"name": "expiring", "scopes": []string{"logs:write"}, "expires_at": expiresAt})
+		req, _ := http.NewRequest(http.MethodPost, "/api-keys", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", adminToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		var created struct {
+			Data struct {
+				KeyID uint   `json:"key_id"`
+				Key   string `json:"key"`
+			} `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+
+		req, _ = http.NewRequest(http.MethodPost, "/api-keys/"+itoa(created.Data.KeyID)+"/rotate", bytes.NewBufferString(`{"overlap":"24h"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", adminToken)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var rotated struct {
+			Data struct {
+				KeyID uint   `json:"key_id"`
+				Key   string `json:"key"`
+			} `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &rotated))
+		assert.Equal(t, http.StatusOK, postLog(rotated.Data.Key))
+
+		var old, replacement models.APIKey
+		db.First(&old, created.Data.KeyID)
+		db.First(&replacement, rotated.Data.KeyID)
+		if assert.NotNil(t, old.ExpiresAt) && assert.NotNil(t, replacement.ExpiresAt) {
+			assert.WithinDuration(t, expiresAt, *old.ExpiresAt, time.Second, "the overlap ends at the old expiry")
+			assert.WithinDuration(t, time.Now().Add(time.Hour), *replacement.ExpiresAt, 5*time.Second)
+		}
+	})
+
+	t.Run("a key has to name the player it acts for", func(t *testing.T) {
+		key := createKey([]string{"logs:write"})
+		send := func(path string, payload interface{}) int {
+			body, _ := json.Marshal(payload)
+			req, _ := http.NewRequest(http.MethodPost, path, bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-API-Key", key)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w.Code
+		}
+
+		assert.Equal(t, http.StatusBadRequest, send("/logs", models.GameLog{Action: "登入"}))
+		assert.Equal(t, http.StatusBadRequest, send("/friendships", map[string]interface{}{"player_id": player.ID}))
+		assert.Equal(t, http.StatusBadRequest, send("/players/"+itoa(player.ID)+"/block", nil))
+
+		var count int64
+		db.Model(&models.Friendship{}).Count(&count)
+		assert.Zero(t, count)
+	})
+
+	t.Run("invalid key is rejected", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, postLog("oxo_nothing_here"))
+	})
+}
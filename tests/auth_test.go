@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"oxo-game-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupTestDB()
+	router := SetupTestRouter(db)
+
+	body, _ := json.Marshal(map[string]string{"name": "Login Player", "password": "secret-pass"})
+	req, _ := http.NewRequest(http.MethodPost, "/players", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	t.Run("successful login", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPost, "/auth/login", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Data struct {
+				Token string `json:"token"`
+				Role  string `json:"role"`
+			} `json:"data"`
+		}
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, response.Data.Token)
+		assert.Equal(t, models.RolePlayer, response.Data.Role)
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		wrong, _ := json.Marshal(map[string]string{"name": "Login Player", "password": "not-the-pass"})
+		req, _ := http.NewRequest(http.MethodPost, "/auth/login", bytes.NewBuffer(wrong))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+func TestPermissionChecks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupTestDB()
+	router := SetupTestRouter(db)
+
+	player, playerToken := IssueToken(db, "Regular", models.RolePlayer)
+	other, _ := IssueToken(db, "Someone Else", models.RolePlayer)
+
+	t.Run("anonymous request is rejected", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/rooms", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("player cannot create levels", func(t *testing.T) {
+		body, _ := json.Marshal(map[string]interface{}{"name": "Expert", "description": "Top", "min_exp": 201, "max_exp": 300})
+		req, _ := http.NewRequest(http.MethodPost, "/levels", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", playerToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		var response struct {
+			Code    int               `json:"code"`
+			Message string            `json:"message"`
+			Data    map[string]string `json:"data"`
+		}
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, 403, response.Code)
+		assert.Equal(t, "Permission denied", response.Message)
+		assert.Equal(t, "levels:write", response.Data["required_permission"])
+	})
+
+	t.Run("player reads own profile only", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/players/"+itoa(player.ID), nil)
+		req.Header.Set("Authorization", playerToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		req, _ = http.NewRequest(http.MethodGet, "/players/"+itoa(other.ID), nil)
+		req.Header.Set("Authorization", playerToken)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
@@ -0,0 +1,149 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"oxo-game-api/internal/models"
+	"oxo-game-api/internal/scheduling"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoomAvailability(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupTestDB()
+	router := SetupTestRouter(db)
+	player, token := IssueToken(db, "Booker", models.RolePlayer)
+	_, adminToken := IssueToken(db, "Admin", models.RoleAdmin)
+
+	studio := models.Room{Name: "Studio", Description: "Daytime room", Status: "available", OpensAt: "09:00", ClosesAt: "18:00", Timezone: "UTC"}
+	hall := models.Room{Name: "Hall", Description: "Evening room", Status: "available", OpensAt: "18:00", ClosesAt: "23:00", Timezone: "UTC"}
+	closed := models.Room{Name: "Closed", Description: "Not taking bookings", Status: "closed"}
+	db.Create(&studio)
+	db.Create(&hall)
+	db.Create(&closed)
+
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1)
+	at := func(hour int) time.Time {
+		return time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), hour, 0, 0, 0, time.UTC)
+	}
+	db.Create(&models.Reservation{RoomID: studio.ID, PlayerID: player.ID, StartsAt: at(10), EndsAt: at(11)})
+
+	send := func(method, path, auth string, payload interface{}) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(payload)
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", auth)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	query := func(from, to time.Time, slot string) string {
+		values := url.Values{"from": {from.Format(time.RFC3339)}, "to": {to.Format(time.RFC3339)}}
+		if slot != "" {
+			values.Set("slot", slot)
+		}
+		return "?" + values.Encode()
+	}
+
+	t.Run("free time skips reservations and closed hours", func(t *testing.T) {
+		w := send(http.MethodGet, "/rooms/"+itoa(studio.ID)+"/availability"+query(at(0), at(23), ""), token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var body struct {
+			Data []scheduling.Interval `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		if assert.Len(t, body.Data, 2) {
+			assert.True(t, at(9).Equal(body.Data[0].Start))
+			assert.True(t, at(10).Equal(body.Data[0].End))
+			assert.True(t, at(11).Equal(body.Data[1].Start))
+			assert.True(t, at(18).Equal(body.Data[1].End))
+		}
+	})
+
+	t.Run("slots cut the free time", func(t *testing.T) {
+		w := send(http.MethodGet, "/rooms/"+itoa(studio.ID)+"/availability"+query(at(0), at(23), "120"), token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var body struct {
+			Data []scheduling.Interval `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Len(t, body.Data, 3)
+	})
+
+	t.Run("maintenance blocks availability and bookings", func(t *testing.T) {
+		w := send(http.MethodPost, "/rooms/"+itoa(studio.ID)+"/maintenance", token, gin.H{"starts_at": at(12), "ends_at": at(18)})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = send(http.MethodPost, "/rooms/"+itoa(studio.ID)+"/maintenance", adminToken, gin.H{"starts_at": at(12), "ends_at": at(18), "reason": "Repainting"})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = send(http.MethodGet, "/rooms/"+itoa(studio.ID)+"/availability"+query(at(11), at(18), ""), token, nil)
+		var body struct {
+			Data []scheduling.Interval `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		if assert.Len(t, body.Data, 1) {
+			assert.True(t, at(12).Equal(body.Data[0].End))
+		}
+
+		w = send(http.MethodPost, "/reservations", token, gin.H{"room_id": studio.ID, "starts_at": at(13), "duration_minutes": 60})
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("which rooms are free in the evening", func(t *testing.T) {
+		w := send(http.MethodGet, "/rooms/availability"+query(at(20), at(21), "60"), token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var body struct {
+			Data []scheduling.RoomAvailability `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		if assert.Len(t, body.Data, 1) {
+			assert.Equal(t, hall.ID, body.Data[0].RoomID)
+		}
+	})
+
+	t.Run("range must be valid", func(t *testing.T) {
+		w := send(http.MethodGet, "/rooms/availability"+query(at(21), at(20), ""), token, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = send(http.MethodGet, "/rooms/availability?from=tomorrow", token, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestOpeningHoursOnDaylightSavingDays(t *testing.T) {
+	hours, err := scheduling.ParseHours("09:00", "18:00", "Europe/Berlin")
+	assert.NoError(t, err)
+	loc := hours.Location
+
+	for _, tc := range []struct {
+		name  string
+		month time.Month
+		day   int
+	}{
+		{"clocks go forward", time.March, 30},
+		{"clocks go back", time.October, 26},
+	} {
+		month, day := tc.month, tc.day
+		t.Run(tc.name, func(t *testing.T) {
+			at := func(hour, minute int) time.Time {
+				return time.Date(2025, month, day, hour, minute, 0, 0, loc)
+			}
+			assert.True(t, hours.Contains(scheduling.Interval{Start: at(9, 0), End: at(10, 0)}))
+			assert.True(t, hours.Contains(scheduling.Interval{Start: at(17, 0), End: at(18, 0)}))
+			assert.False(t, hours.Contains(scheduling.Interval{Start: at(8, 30), End: at(9, 30)}))
+			assert.False(t, hours.Contains(scheduling.Interval{Start: at(17, 30), End: at(18, 30)}))
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"oxo-game-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalendarFeeds(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupTestDB()
+	router := SetupTestRouter(db)
+	host, hostToken := IssueToken(db, "Host", models.RolePlayer)
+	other, otherToken := IssueToken(db, "Other", models.RolePlayer)
+
+	room := models.Room{Name: "Calendar Room", Description: "Room with a feed", Status: "available"}
+	db.Create(&room)
+
+	send := func(method, path, auth string, payload interface{}) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(payload)
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	start := time.Now().Add(48 * time.Hour).Truncate(time.Hour)
+	w := send(http.MethodPost, "/reservations", hostToken, gin.H{"room_id": room.ID, "starts_at": start, "duration_minutes": 60})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var created struct {
+		Data struct {
+			ReservationID uint `json:"reservation_id"`
+		} `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	uid := "UID:reservation-" + itoa(created.Data.ReservationID) + "@oxo-game-api"
+
+	feedURL := func(path, auth string) string {
+		w := send(http.MethodPost, path, auth, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var feed struct {
+			Data struct {
+				Token string `json:"token"`
+			} `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &feed)
+		assert.NotEmpty(t, feed.Data.Token)
+		return feed.Data.Token
+	}
+
+	t.Run("player feed lists the reservation", func(t *testing.T) {
+		w := send(http.MethodGet, "/players/"+itoa(host.ID)+"/calendar.ics", hostToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "text/calendar"))
+		assert.True(t, strings.HasPrefix(w.Body.String(), "BEGIN:VCALENDAR\r\n"))
+		assert.Contains(t, w.Body.String(), uid)
+		assert.Contains(t, w.Body.String(), "STATUS:CONFIRMED")
+		assert.Contains(t, w.Body.String(), "DTSTART:"+start.UTC().Format("20060102T150405Z"))
+
+		w = send(http.MethodGet, "/players/"+itoa(host.ID)+"/calendar.ics", otherToken, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = send(http.MethodGet, "/players/"+itoa(host.ID)+"/calendar.ics", "", nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("token in the URL replaces the login", func(t *testing.T) {
+		token := feedURL("/players/"+itoa(host.ID)+"/calendar-feed", hostToken)
+
+		w := send(http.MethodGet, "/players/"+itoa(host.ID)+"/calendar.ics?token="+token, "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), uid)
+
+		w = send(http.MethodGet, "/players/"+itoa(other.ID)+"/calendar.ics?token="+token, "", nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = send(http.MethodPost, "/players/"+itoa(host.ID)+"/calendar-feed", otherToken, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		rotated := feedURL("/players/"+itoa(host.ID)+"/calendar-feed", hostToken)
+		w = send(http.MethodGet, "/players/"+itoa(host.ID)+"/calendar.ics?token="+token, "", nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = send(http.MethodDelete, "/players/"+itoa(host.ID)+"/calendar-feed", hostToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = send(http.MethodGet, "/players/"+itoa(host.ID)+"/calendar.ics?token="+rotated, "", nil)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("room feed shows updates and cancellations", func(t *testing.T) {
+		token := feedURL("/rooms/"+itoa(room.ID)+"/calendar-feed", otherToken)
+
+		w := send(http.MethodGet, "/rooms/"+itoa(room.ID)+"/calendar.ics?token="+token, "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), uid)
+		assert.Contains(t, w.Body.String(), "SEQUENCE:0")
+		assert.NotContains(t, w.Body.String(), "Host")
+
+		w = send(http.MethodPost, "/reservations/"+itoa(created.Data.ReservationID)+"/cancel", hostToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = send(http.MethodGet, "/rooms/"+itoa(room.ID)+"/calendar.ics?token="+token, "", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), uid)
+		assert.Contains(t, w.Body.String(), "SEQUENCE:1")
+		assert.Contains(t, w.Body.String(), "STATUS:CANCELLED")
+	})
+
+	t.Run("api key without a player gets no feed", func(t *testing.T) {
+		_, adminToken := IssueToken(db, "Calendar Admin", models.RoleAdmin)
+		w := send(http.MethodPost, "/api-keys", adminToken, gin.H{"name": "calendar-sync", "scopes": []string{"logs:write"}})
+		assert.Equal(t, http.StatusOK, w.Code)
+		var key struct {
+			Data struct {
+				Key string `json:"key"`
+			} `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &key)
+
+		req, _ := http.NewRequest(http.MethodPost, "/rooms/"+itoa(room.ID)+"/calendar-feed", nil)
+		req.Header.Set("X-API-Key", key.Data.Key)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		var feeds int64
+		db.Model(&models.CalendarFeed{}).Where("created_by = 0").Count(&feeds)
+		assert.Zero(t, feeds)
+	})
+}
@@ -17,6 +17,7 @@ func TestJoinChallenge(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	db := SetupTestDB()
 	router := SetupTestRouter(db)
+	_, token := IssueToken(db, "Admin", models.RoleAdmin)
 
 	t.Run("successful join", func(t *testing.T) {
 		challenge := models.Challenge{PlayerID: 2}
@@ -24,6 +25,7 @@ func TestJoinChallenge(t *testing.T) {
 
 		req, _ := http.NewRequest(http.MethodPost, "/challenges", bytes.NewBuffer(body))
 		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", token)
 		w := httptest.NewRecorder()
 		router.ServeHTTP(w, req)
 
@@ -35,15 +37,56 @@ func TestJoinChallenge(t *testing.T) {
 		}
 		assert.Equal(t, 2, createdChallenge.PlayerID)
 	})
+
+	t.Run("the entry fee is set by the server", func(t *testing.T) {
+		player, playerToken := IssueToken(db, "Payer", models.RolePlayer)
+		db.Model(&player).Update("balance", 100)
+
+		body, _ := json.Marshal(map[string]interface{}{"amount": 1e9})
+		req, _ := http.NewRequest(http.MethodPost, "/challenges", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", playerToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var joined models.Challenge
+		db.Where("player_id = ?", player.ID).First(&joined)
+		assert.Equal(t, 20.01, joined.Amount)
+
+		db.First(&player, player.ID)
+		assert.InDelta(t, 79.99, player.Balance, 0.001)
+	})
+
+	t.Run("a player who cannot pay is neither charged nor entered", func(t *testing.T) {
+		player, playerToken := IssueToken(db, "Broke", models.RolePlayer)
+		db.Model(&player).Update("balance", 20)
+
+		req, _ := http.NewRequest(http.MethodPost, "/challenges", bytes.NewBufferString(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", playerToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "insufficient balance")
+
+		var count int64
+		db.Model(&models.Challenge{}).Where("player_id = ?", player.ID).Count(&count)
+		assert.Zero(t, count)
+		db.First(&player, player.ID)
+		assert.Equal(t, float64(20), player.Balance)
+	})
 }
 
 func TestGetChallengeResults(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	db := SetupTestDB()
 	router := SetupTestRouter(db)
+	_, token := IssueToken(db, "Player", models.RolePlayer)
 
 	t.Run("successful fetch", func(t *testing.T) {
 		req, _ := http.NewRequest(http.MethodGet, "/challenges/results", nil)
+		req.Header.Set("Authorization", token)
 		w := httptest.NewRecorder()
 		router.ServeHTTP(w, req)
 
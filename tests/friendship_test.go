@@ -0,0 +1,136 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"oxo-game-api/internal/models"
+	"oxo-game-api/internal/notify"
+	"oxo-game-api/internal/outbox"
+	"oxo-game-api/internal/social"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFriendships(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupTestDB()
+	router := SetupTestRouter(db)
+	alice, aliceToken := IssueToken(db, "Alice", models.RolePlayer)
+	bob, bobToken := IssueToken(db, "Bob", models.RolePlayer)
+	carol, carolToken := IssueToken(db, "Carol", models.RolePlayer)
+
+	room := models.Room{Name: "Lobby", Description: "Main room", Status: "available"}
+	db.Create(&room)
+
+	send := func(method, path, auth string, payload interface{}) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(payload)
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", auth)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("request and accept", func(t *testing.T) {
+		w := send(http.MethodPost, "/friendships", aliceToken, map[string]uint{"player_id": bob.ID})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Data models.Friendship `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+		w = send(http.MethodPost, "/friendships/"+itoa(response.Data.ID)+"/accept", aliceToken, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = send(http.MethodPost, "/friendships/"+itoa(response.Data.ID)+"/accept", bobToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = send(http.MethodPost, "/friendships", bobToken, map[string]uint{"player_id": alice.ID})
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("friend list with presence", func(t *testing.T) {
+		w := send(http.MethodPost, "/logs", bobToken, map[string]interface{}{"action": models.ActionEnterRoom, "room_id": room.ID})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = send(http.MethodGet, "/players/"+itoa(alice.ID)+"/friends", bobToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Data []social.Friend `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Len(t, response.Data, 1)
+		assert.Equal(t, bob.ID, response.Data[0].PlayerID)
+		if assert.NotNil(t, response.Data[0].RoomID) {
+			assert.Equal(t, room.ID, *response.Data[0].RoomID)
+		}
+	})
+
+	t.Run("friend list hidden from strangers", func(t *testing.T) {
+		w := send(http.MethodGet, "/players/"+itoa(alice.ID)+"/friends", carolToken, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = send(http.MethodPut, "/players/"+itoa(alice.ID)+"/privacy", aliceToken, map[string]string{"friend_list_visibility": models.VisibilityPublic})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = send(http.MethodGet, "/players/"+itoa(alice.ID)+"/friends", carolToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "room_id")
+	})
+
+	t.Run("blocked players cannot send requests", func(t *testing.T) {
+		w := send(http.MethodPost, "/players/"+itoa(alice.ID)+"/block", carolToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = send(http.MethodPost, "/friendships", aliceToken, map[string]uint{"player_id": carol.ID})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("reservation invitations target friends", func(t *testing.T) {
+		start := time.Now().Add(24 * time.Hour).Truncate(time.Hour)
+		reservation := models.Reservation{RoomID: room.ID, PlayerID: alice.ID, StartsAt: start, EndsAt: start.Add(time.Hour)}
+		db.Create(&reservation)
+
+		w := send(http.MethodPost, "/reservations/"+itoa(reservation.ID)+"/invitations", aliceToken, map[string][]uint{"player_ids": {bob.ID}})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = send(http.MethodPost, "/reservations/"+itoa(reservation.ID)+"/invitations", aliceToken, map[string][]uint{"player_ids": {carol.ID}})
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+
+	t.Run("friend requests notify the addressee unless turned off", func(t *testing.T) {
+		dave, _ := IssueToken(db, "Dave", models.RolePlayer)
+		erin, erinToken := IssueToken(db, "Erin", models.RolePlayer)
+		w := send(http.MethodPatch, "/players/"+itoa(erin.ID)+"/profile", erinToken, map[string]interface{}{"notifications": map[string]bool{"friend_requests": false}})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = send(http.MethodPost, "/friendships", aliceToken, map[string]uint{"player_id": dave.ID})
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = send(http.MethodPost, "/friendships", aliceToken, map[string]uint{"player_id": erin.ID})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		dispatcher := notify.NewDispatcher(db, notify.NewInboxChannel(db))
+		_, err := outbox.Relay(db, dispatcher.Deliver, time.Now())
+		assert.NoError(t, err)
+
+		var notifications []models.Notification
+		db.Where("player_id = ?", dave.ID).Find(&notifications)
+		if assert.Len(t, notifications, 1) {
+			assert.Equal(t, social.EventFriendRequest, notifications[0].Type)
+			assert.Contains(t, notifications[0].Body, "Alice")
+		}
+
+		var muted int64
+		db.Model(&models.Notification{}).Where("player_id = ?", erin.ID).Count(&muted)
+		assert.Zero(t, muted)
+	})
+}
@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"oxo-game-api/internal/models"
+	"oxo-game-api/migrations"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReferentialIntegrity(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupTestDB()
+	router := SetupTestRouter(db)
+	player, _ := IssueToken(db, "Player", models.RolePlayer)
+	_, adminToken := IssueToken(db, "Admin", models.RoleAdmin)
+
+	room := models.Room{Name: "Integrity", Status: models.RoomAvailable, Capacity: 4, OpensAt: "00:00", ClosesAt: "24:00", Timezone: "UTC"}
+	db.Create(&room)
+
+	send := func(method, path string, payload interface{}) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(payload)
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", adminToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("the database rejects dangling references", func(t *testing.T) {
+		err := db.Create(&models.GameLog{PlayerID: 9999, Action: "login", Timestamp: time.Now()}).Error
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "23503")
+
+		missingRoom := uint(9999)
+		err = db.Create(&models.GameLog{PlayerID: player.ID, RoomID: &missingRoom, Action: "login", Timestamp: time.Now()}).Error
+		assert.Error(t, err)
+	})
+
+	t.Run("a reservation for a missing player is rejected", func(t *testing.T) {
+		start := time.Now().UTC().Add(24 * time.Hour).Truncate(time.Hour)
+		w := send(http.MethodPost, "/reservations", gin.H{"room_id": room.ID, "player_id": 9999, "starts_at": start, "ends_at": start.Add(time.Hour)})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = send(http.MethodPost, "/reservations", gin.H{"room_id": 9999, "starts_at": start, "ends_at": start.Add(time.Hour)})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("a payment for a missing player is rejected", func(t *testing.T) {
+		w := send(http.MethodPost, "/payments", gin.H{"player_id": 9999, "amount": 10, "method": models.MethodCreditCard})
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		var count int64
+		db.Model(&models.Payment{}).Count(&count)
+		assert.Equal(t, int64(0), count)
+	})
+
+	t.Run("deletes follow the foreign key policies", func(t *testing.T) {
+		log := models.GameLog{PlayerID: player.ID, RoomID: &room.ID, Action: models.ActionEnterRoom, Timestamp: time.Now()}
+		assert.NoError(t, db.Create(&log).Error)
+
+		other := models.Room{Name: "Scratch", Status: models.RoomAvailable, Capacity: 4, OpensAt: "00:00", ClosesAt: "24:00", Timezone: "UTC"}
+		db.Create(&other)
+		logged := models.GameLog{PlayerID: player.ID, RoomID: &other.ID, Action: models.ActionEnterRoom, Timestamp: time.Now()}
+		assert.NoError(t, db.Create(&logged).Error)
+		assert.NoError(t, db.Unscoped().Delete(&other).Error)
+		assert.NoError(t, db.First(&logged, logged.ID).Error)
+		assert.Nil(t, logged.RoomID)
+
+		challenge := models.Challenge{PlayerID: player.ID, Amount: 20.01}
+		db.Create(&challenge)
+		db.Create(&models.ChallengeResult{ChallengeID: challenge.ID, PlayerID: player.ID, Won: true, Prize: 10})
+		assert.Error(t, db.Unscoped().Delete(&models.Player{}, player.ID).Error)
+
+		assert.NoError(t, db.Delete(&challenge).Error)
+		var results int64
+		db.Model(&models.ChallengeResult{}).Where("challenge_id = ?", challenge.ID).Count(&results)
+		assert.Equal(t, int64(0), results)
+	})
+
+	t.Run("the scan reports orphans from before the keys", func(t *testing.T) {
+		orphans, err := migrations.FindOrphans(db)
+		assert.NoError(t, err)
+		assert.Empty(t, orphans)
+
+		db.Exec("ALTER TABLE payments DROP CONSTRAINT fk_payments_player")
+		orphan := models.Payment{PlayerID: 9999, Amount: 5, Method: models.MethodCreditCard, Status: models.StatusSuccess, TransactionID: "tx-orphan"}
+		assert.NoError(t, db.Create(&orphan).Error)
+		assert.NoError(t, migrations.CreateConstraints(db))
+
+		orphans, err = migrations.FindOrphans(db)
+		assert.NoError(t, err)
+		if assert.Len(t, orphans, 1) {
+			assert.Equal(t, "fk_payments_player", orphans[0].Name)
+			assert.Equal(t, []uint{orphan.ID}, orphans[0].IDs)
+		}
+
+		for _, fk := range migrations.ForeignKeys {
+			if fk.Name == "fk_payments_player" {
+				assert.Error(t, migrations.ValidateForeignKey(db, fk))
+				db.Delete(&orphan)
+				assert.NoError(t, migrations.ValidateForeignKey(db, fk))
+			}
+		}
+	})
+}
@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"oxo-game-api/internal/leaderboard"
+	"oxo-game-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeaderboards(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupTestDB()
+	router := SetupTestRouter(db)
+
+	me, token := IssueToken(db, "Me", models.RolePlayer)
+	rival := models.Player{Name: "Rival"}
+	db.Create(&rival)
+
+	now := time.Now()
+	leaderboard.Increment(db, leaderboard.KindWins, rival.ID, 3, now)
+	leaderboard.Increment(db, leaderboard.KindWins, me.ID, 1, now)
+	leaderboard.Increment(db, leaderboard.KindWins, me.ID, 5, now.AddDate(0, 0, -30))
+
+	t.Run("daily board only counts today", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/leaderboards/wins?window=daily", nil)
+		req.Header.Set("Authorization", token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Data struct {
+				Items []leaderboard.Entry `json:"items"`
+				Total int64               `json:"total"`
+			} `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, int64(2), response.Data.Total)
+		assert.Equal(t, rival.ID, response.Data.Items[0].PlayerID)
+		assert.Equal(t, float64(3), response.Data.Items[0].Score)
+	})
+
+	t.Run("all time board sums every period", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/leaderboards/wins/me", nil)
+		req.Header.Set("Authorization", token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Data leaderboard.Entry `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, int64(1), response.Data.Rank)
+		assert.Equal(t, float64(6), response.Data.Score)
+	})
+
+	t.Run("unknown kind", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/leaderboards/losses", nil)
+		req.Header.Set("Authorization", token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
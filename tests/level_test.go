@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"oxo-game-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLevelLadder(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupTestDB()
+	router := SetupTestRouter(db)
+	_, adminToken := IssueToken(db, "Admin", models.RoleAdmin)
+
+	send := func(method, path string, payload interface{}) *httptest.ResponseRecorder {
+		var body []byte
+		if payload != nil {
+			body, _ = json.Marshal(payload)
+		}
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", adminToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	level := func(name string, min, max int) map[string]interface{} {
+		return map[string]interface{}{"name": name, "description": name, "min_exp": min, "max_exp": max}
+	}
+
+	t.Run("first level may start at zero", func(t *testing.T) {
+		w := send(http.MethodPost, "/levels", level("Beginner", 0, 100))
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("min greater than max is rejected", func(t *testing.T) {
+		w := send(http.MethodPost, "/levels", level("Broken", 300, 200))
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+
+	t.Run("overlap is rejected", func(t *testing.T) {
+		w := send(http.MethodPost, "/levels", level("Overlap", 50, 150))
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+
+	t.Run("gap is rejected", func(t *testing.T) {
+		w := send(http.MethodPost, "/levels", level("Gap", 150, 200))
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+
+	t.Run("contiguous level is accepted", func(t *testing.T) {
+		w := send(http.MethodPost, "/levels", level("Intermediate", 101, 200))
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("delete refuses levels in use without reassignment", func(t *testing.T) {
+		var intermediate models.Level
+		db.Where("name = ?", "Intermediate").First(&intermediate)
+		db.Create(&models.Player{Name: "Stuck", LevelID: intermediate.ID, Experience: 150})
+
+		w := send(http.MethodDelete, "/levels/"+itoa(intermediate.ID), nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		var beginner models.Level
+		db.Where("name = ?", "Beginner").First(&beginner)
+		w = send(http.MethodDelete, "/levels/"+itoa(intermediate.ID)+"?reassign=true", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		db.First(&beginner, beginner.ID)
+		assert.Equal(t, uint(200), beginner.MaxExp)
+
+		var stuck models.Player
+		db.Where("name = ?", "Stuck").First(&stuck)
+		assert.Equal(t, beginner.ID, stuck.LevelID)
+		assert.Equal(t, uint(150), stuck.Experience)
+	})
+}
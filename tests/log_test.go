@@ -17,6 +17,7 @@ func TestCreateLog(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	db := SetupTestDB()
 	router := SetupTestRouter(db)
+	_, token := IssueToken(db, "Token Owner", models.RolePlayer)
 
 	t.Run("successful log creation", func(t *testing.T) {
 		log := models.GameLog{Details: "Test Log"}
@@ -24,6 +25,7 @@ func TestCreateLog(t *testing.T) {
 
 		req, _ := http.NewRequest(http.MethodPost, "/logs", bytes.NewBuffer(body))
 		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", token)
 		w := httptest.NewRecorder()
 		router.ServeHTTP(w, req)
 
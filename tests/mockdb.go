@@ -4,9 +4,14 @@ import (
 	"fmt"
 	"os"
 	"testing"
+	"time"
 
+	"oxo-game-api/config"
 	"oxo-game-api/internal/api/handlers"
+	"oxo-game-api/internal/api/middleware"
 	"oxo-game-api/internal/models"
+	"oxo-game-api/internal/presence"
+	"oxo-game-api/migrations"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -44,73 +49,233 @@ func SetupTestDB() *gorm.DB {
 		&models.GameLog{},
 		&models.Payment{},
 		&models.Reservation{},
-		&models.Room{})
+		&models.Room{},
+		&models.AuthToken{},
+		&models.APIKey{},
+		&models.LevelChange{},
+		&models.LeaderboardEntry{},
+		&models.ErasureJob{},
+		&models.Sanction{},
+		&models.Friendship{},
+		&models.PrivacySettings{},
+		&models.ReservationParticipant{},
+		&models.PlayerProfile{},
+		&models.MaintenanceWindow{},
+		&models.ReservationTransition{},
+		&models.WaitlistEntry{},
+		&models.OutboxEvent{},
+		&models.ReservationSeries{},
+		&models.CalendarFeed{},
+		&models.Notification{})
+	migrations.CreateConstraints(db)
+	migrations.ApplyNamePolicy(db, config.NamePolicyReserve)
 
-	db.Exec("TRUNCATE TABLE players, challenges, levels, logs, payments, reservations, rooms RESTART IDENTITY CASCADE")
+	db.Exec("TRUNCATE TABLE players, challenges, challenge_results, levels, game_logs, payments, reservations, rooms, auth_tokens, api_keys, level_changes, leaderboard_entries, erasure_jobs, sanctions, friendships, privacy_settings, reservation_participants, player_profiles, maintenance_windows, reservation_transitions, waitlist_entries, outbox_events, reservation_series, calendar_feeds, notifications RESTART IDENTITY CASCADE")
 	return db
 }
 
+// Tracker is the room presence of the last router set up
+var Tracker *presence.Tracker
+
 func SetupTestRouter(db *gorm.DB) *gin.Engine {
 	gin.SetMode(gin.TestMode)
 
 	router := gin.Default()
-	playerHandler := handlers.NewPlayerHandler(db)
+	authCfg := &config.AuthConfig{TokenTTL: time.Hour, APIKeyRotationOverlap: time.Hour}
+	retentionCfg := &config.RetentionConfig{DeletedNamePolicy: config.NamePolicyReserve}
+	reservationCfg := &config.ReservationConfig{CancellationWindow: 2 * time.Hour, CheckInOpens: 15 * time.Minute, NoShowGrace: 15 * time.Minute, WaitlistConfirmWindow: 30 * time.Minute}
+	Tracker = presence.NewTracker(db, presence.NewMemoryStore(), 5*time.Minute)
+	authHandler := handlers.NewAuthHandler(db, authCfg)
+	apiKeyHandler := handlers.NewAPIKeyHandler(db, authCfg)
+	playerHandler := handlers.NewPlayerHandler(db, retentionCfg)
 	levelHandler := handlers.NewLevelHandler(db)
 	roomHandler := handlers.NewRoomHandler(db)
-	reservationHandler := handlers.NewReservationHandler(db)
+	reservationHandler := handlers.NewReservationHandler(db, reservationCfg)
 	challengeHandler := handlers.NewChallengeHandler(db)
-	logHandler := handlers.NewLogHandler(db)
+	logHandler := handlers.NewLogHandler(db, Tracker)
 	paymentHandler := handlers.NewPaymentHandler(db)
-	challenges := router.Group("/challenges")
+	leaderboardHandler := handlers.NewLeaderboardHandler(db)
+	privacyHandler := handlers.NewPrivacyHandler(db)
+	moderationHandler := handlers.NewModerationHandler(db)
+	friendshipHandler := handlers.NewFriendshipHandler(db)
+	profileHandler := handlers.NewProfileHandler(db)
+	notificationHandler := handlers.NewNotificationHandler(db)
+	calendarHandler := handlers.NewCalendarHandler(db)
+	presenceHandler := handlers.NewPresenceHandler(db, Tracker)
+
+	router.POST("/auth/login", authHandler.Login)
+	router.POST("/players", playerHandler.CreatePlayer)
+	router.GET("/rooms/:id/calendar.ics", middleware.AuthenticateFeed(db, models.FeedRoom), calendarHandler.GetRoomCalendar)
+	router.GET("/players/:id/calendar.ics", middleware.AuthenticateFeed(db, models.FeedPlayer), middleware.RequireSelfOrPermission("id", middleware.PermReservationsRead), calendarHandler.GetPlayerCalendar)
+
+	api := router.Group("", middleware.Authenticate(db))
+
+	api.POST("/auth/logout", authHandler.Logout)
+
+	challenges := api.Group("/challenges")
 	{
 		challenges.GET("/results", challengeHandler.GetChallengeResults)
 		challenges.POST("", challengeHandler.JoinChallenge)
 	}
 
-	players := router.Group("/players")
+	players := api.Group("/players")
 	{
-		players.GET("", playerHandler.GetPlayers)
-		players.POST("", playerHandler.CreatePlayer)
-		players.GET("/:id", playerHandler.GetPlayerByID)
-		players.PUT("/:id", playerHandler.UpdatePlayerByID)
-		players.DELETE("/:id", playerHandler.DeletePlayerByID)
+		players.GET("", middleware.RequirePermission(middleware.PermPlayersRead), playerHandler.GetPlayers)
+		players.GET("/deleted", middleware.RequirePermission(middleware.PermRecordsRecover), playerHandler.GetDeletedPlayers)
+		players.GET("/:id", middleware.RequireSelfOrPermission("id", middleware.PermPlayersRead), playerHandler.GetPlayerByID)
+		players.PUT("/:id", middleware.RequireSelfOrPermission("id", middleware.PermPlayersWrite), playerHandler.UpdatePlayerByID)
+		players.PATCH("/:id", middleware.RequireSelfOrPermission("id", middleware.PermPlayersWrite), playerHandler.UpdatePlayerByID)
+		players.DELETE("/:id", middleware.RequirePermission(middleware.PermPlayersDelete), playerHandler.DeletePlayerByID)
+		players.PUT("/:id/role", middleware.RequirePermission(middleware.PermRolesWrite), playerHandler.UpdatePlayerRole)
+		players.POST("/:id/experience", middleware.RequirePermission(middleware.PermExperienceWrite), playerHandler.AwardExperience)
+		players.GET("/:id/level-history", middleware.RequireSelfOrPermission("id", middleware.PermPlayersRead), playerHandler.GetLevelHistory)
+		players.POST("/:id/restore", middleware.RequirePermission(middleware.PermRecordsRecover), playerHandler.RestorePlayer)
+		players.DELETE("/:id/purge", middleware.RequirePermission(middleware.PermRecordsRecover), playerHandler.PurgePlayer)
+		players.GET("/:id/export", middleware.RequireSelfOrPermission("id", middleware.PermPrivacyManage), privacyHandler.ExportPlayerData)
+		players.POST("/:id/erasure", middleware.RequireSelfOrPermission("id", middleware.PermPrivacyManage), privacyHandler.RequestErasure)
+		players.GET("/:id/sanctions", middleware.RequireSelfOrPermission("id", middleware.PermPlayersModerate), moderationHandler.GetSanctions)
+		players.POST("/:id/sanctions", middleware.RequirePermission(middleware.PermPlayersModerate), moderationHandler.IssueSanction)
+		players.GET("/:id/profile", profileHandler.GetProfile)
+		players.PATCH("/:id/profile", middleware.RequireSelfOrPermission("id", middleware.PermPlayersWrite), profileHandler.UpdateProfile)
+		players.GET("/:id/friends", friendshipHandler.GetFriends)
+		players.GET("/:id/friend-requests", middleware.RequireSelfOrPermission("id", middleware.PermPlayersRead), friendshipHandler.GetFriendRequests)
+		players.POST("/:id/block", friendshipHandler.BlockPlayer)
+		players.GET("/:id/privacy", middleware.RequireSelfOrPermission("id", middleware.PermPlayersRead), friendshipHandler.GetPrivacySettings)
+		players.PUT("/:id/privacy", middleware.RequireSelfOrPermission("id", middleware.PermPlayersWrite), friendshipHandler.UpdatePrivacySettings)
+		players.POST("/:id/calendar-feed", middleware.RequireSelfOrPermission("id", middleware.PermReservationsRead), calendarHandler.CreatePlayerCalendarFeed)
+		players.DELETE("/:id/calendar-feed", middleware.RequireSelfOrPermission("id", middleware.PermReservationsRead), calendarHandler.RevokePlayerCalendarFeed)
+		players.GET("/:id/notifications", middleware.RequireSelfOrPermission("id", middleware.PermPlayersRead), notificationHandler.GetNotifications)
+		players.POST("/:id/notifications/read-all", middleware.RequireSelfOrPermission("id", middleware.PermPlayersWrite), notificationHandler.MarkAllNotificationsRead)
+		players.POST("/:id/notifications/:notification_id/read", middleware.RequireSelfOrPermission("id", middleware.PermPlayersWrite), notificationHandler.MarkNotificationRead)
 	}
 
-	payments := router.Group("/payments")
+	payments := api.Group("/payments")
 	{
 		payments.GET("/:id", paymentHandler.GetPayment)
 		payments.POST("", paymentHandler.ProcessPayment)
 	}
 
-	levels := router.Group("/levels")
+	api.POST("/players:action", middleware.RequirePermission(middleware.PermPlayersWrite), handlers.CustomMethod(map[string]gin.HandlerFunc{
+		"bulk": playerHandler.BulkImportPlayers,
+	}))
+	api.GET("/players:action", middleware.RequirePermission(middleware.PermPlayersRead), handlers.CustomMethod(map[string]gin.HandlerFunc{
+		"export": playerHandler.ExportPlayers,
+	}))
+
+	levels := api.Group("/levels")
 	{
 		levels.GET("", levelHandler.GetLevels)
-		levels.POST("", levelHandler.CreateLevel)
+		levels.GET("/:id", levelHandler.GetLevelByID)
+		levels.POST("", middleware.RequirePermission(middleware.PermLevelsWrite), levelHandler.CreateLevel)
+		levels.PUT("/:id", middleware.RequirePermission(middleware.PermLevelsWrite), levelHandler.UpdateLevelByID)
+		levels.PATCH("/:id", middleware.RequirePermission(middleware.PermLevelsWrite), levelHandler.UpdateLevelByID)
+		levels.DELETE("/:id", middleware.RequirePermission(middleware.PermLevelsWrite), levelHandler.DeleteLevelByID)
 	}
 
-	rooms := router.Group("/rooms")
+	rooms := api.Group("/rooms")
 	{
 		rooms.GET("", roomHandler.GetRooms)
-		rooms.POST("", roomHandler.CreateRoom)
+		rooms.GET("/deleted", middleware.RequirePermission(middleware.PermRecordsRecover), roomHandler.GetDeletedRooms)
+		rooms.GET("/availability", roomHandler.GetRoomsAvailability)
+		rooms.POST("", middleware.RequirePermission(middleware.PermRoomsWrite), roomHandler.CreateRoom)
 		rooms.GET("/:id", roomHandler.GetRoomByID)
-		rooms.PUT("/:id", roomHandler.UpdateRoomByID)
-		rooms.DELETE("/:id", roomHandler.DeleteRoomByID)
+		rooms.PUT("/:id", middleware.RequirePermission(middleware.PermRoomsWrite), roomHandler.UpdateRoomByID)
+		rooms.PATCH("/:id", middleware.RequirePermission(middleware.PermRoomsWrite), roomHandler.UpdateRoomByID)
+		rooms.DELETE("/:id", middleware.RequirePermission(middleware.PermRoomsDelete), roomHandler.DeleteRoomByID)
+		rooms.POST("/:id/restore", middleware.RequirePermission(middleware.PermRecordsRecover), roomHandler.RestoreRoom)
+		rooms.DELETE("/:id/purge", middleware.RequirePermission(middleware.PermRecordsRecover), roomHandler.PurgeRoom)
+		rooms.GET("/:id/availability", roomHandler.GetRoomAvailability)
+		rooms.GET("/:id/maintenance", roomHandler.GetMaintenanceWindows)
+		rooms.POST("/:id/maintenance", middleware.RequirePermission(middleware.PermRoomsWrite), roomHandler.CreateMaintenanceWindow)
+		rooms.DELETE("/:id/maintenance/:window_id", middleware.RequirePermission(middleware.PermRoomsWrite), roomHandler.DeleteMaintenanceWindow)
+		rooms.POST("/:id/calendar-feed", calendarHandler.CreateRoomCalendarFeed)
+		rooms.DELETE("/:id/calendar-feed", calendarHandler.RevokeRoomCalendarFeed)
+		rooms.GET("/:id/occupants", presenceHandler.GetRoomOccupants)
+		rooms.POST("/:id/enter", presenceHandler.EnterRoom)
+		rooms.POST("/:id/heartbeat", presenceHandler.RoomHeartbeat)
+		rooms.POST("/:id/leave", presenceHandler.LeaveRoom)
 	}
 
-	reservations := router.Group("/reservations")
+	reservations := api.Group("/reservations")
 	{
 		reservations.GET("", reservationHandler.GetReservations)
 		reservations.POST("", reservationHandler.CreateReservation)
+		reservations.GET("/waitlist", reservationHandler.GetWaitlist)
+		reservations.POST("/waitlist/:id/confirm", reservationHandler.ConfirmWaitlistOffer)
+		reservations.DELETE("/waitlist/:id", reservationHandler.LeaveWaitlist)
+		reservations.GET("/series/:id", reservationHandler.GetReservationSeries)
+		reservations.PATCH("/series/:id", reservationHandler.UpdateReservationSeries)
+		reservations.POST("/series/:id/cancel", reservationHandler.CancelReservationSeries)
+		reservations.GET("/:id", reservationHandler.GetReservationByID)
+		reservations.PATCH("/:id", reservationHandler.UpdateReservationByID)
+		reservations.POST("/:id/invitations", reservationHandler.InviteFriends)
+		reservations.POST("/:id/accept", reservationHandler.AcceptInvitation)
+		reservations.POST("/:id/decline", reservationHandler.DeclineInvitation)
+		reservations.POST("/:id/cancel", reservationHandler.CancelReservation)
+		reservations.POST("/:id/check-in", reservationHandler.CheckInReservation)
+		reservations.GET("/:id/transitions", reservationHandler.GetReservationTransitions)
 	}
 
-	logs := router.Group("/logs")
+	logs := api.Group("/logs")
 	{
 		logs.GET("", logHandler.GetLogs)
 		logs.POST("", logHandler.CreateLog)
 	}
+
+	leaderboards := api.Group("/leaderboards")
+	{
+		leaderboards.GET("/:kind", leaderboardHandler.GetLeaderboard)
+		leaderboards.GET("/:kind/me", leaderboardHandler.GetMyRank)
+		leaderboards.POST("/rebuild", middleware.RequirePermission(middleware.PermLeaderboardsAdmin), leaderboardHandler.RebuildLeaderboards)
+	}
+
+	apiKeys := api.Group("/api-keys", middleware.RequirePermission(middleware.PermAPIKeysManage))
+	{
+		apiKeys.GET("", apiKeyHandler.GetAPIKeys)
+		apiKeys.POST("", apiKeyHandler.CreateAPIKey)
+		apiKeys.POST("/:id/rotate", apiKeyHandler.RotateAPIKey)
+		apiKeys.DELETE("/:id", apiKeyHandler.RevokeAPIKey)
+	}
+
+	friendships := api.Group("/friendships")
+	{
+		friendships.POST("", friendshipHandler.SendFriendRequest)
+		friendships.POST("/:id/accept", friendshipHandler.AcceptFriendRequest)
+		friendships.POST("/:id/decline", friendshipHandler.DeclineFriendRequest)
+		friendships.DELETE("/:id", friendshipHandler.DeleteFriendship)
+	}
+
+	sanctions := api.Group("/sanctions")
+	{
+		sanctions.POST("/:id/revoke", middleware.RequirePermission(middleware.PermPlayersModerate), moderationHandler.RevokeSanction)
+		sanctions.POST("/:id/appeal", moderationHandler.AppealSanction)
+		sanctions.POST("/:id/appeal/decision", middleware.RequirePermission(middleware.PermPlayersModerate), moderationHandler.DecideAppeal)
+	}
+
+	api.GET("/erasure-jobs/:id", privacyHandler.GetErasureJob)
 	return router
 }
 
+// IssueToken creates a player with the given role and returns it with a bearer header value
+func IssueToken(db *gorm.DB, name, role string) (models.Player, string) {
+	player := models.Player{Name: name, Role: role}
+	if err := db.Create(&player).Error; err != nil {
+		panic("Failed to create token owner: " + err.Error())
+	}
+
+	raw := fmt.Sprintf("test-token-%d", player.ID)
+	token := models.AuthToken{
+		PlayerID:  player.ID,
+		TokenHash: middleware.HashToken(raw),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	if err := db.Create(&token).Error; err != nil {
+		panic("Failed to create token: " + err.Error())
+	}
+
+	return player, "Bearer " + raw
+}
+
 func CheckDatabaseConnection(db *gorm.DB) error {
 	var count int64
 	query := "SELECT COUNT(*) FROM players"
@@ -132,3 +297,7 @@ func TestDatabaseConnection(t *testing.T) {
 		t.Fatalf("Database connection test failed: %v", err)
 	}
 }
+
+func itoa(id uint) string {
+	return fmt.Sprintf("%d", id)
+}
@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"oxo-game-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanctions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupTestDB()
+	router := SetupTestRouter(db)
+	_, operatorToken := IssueToken(db, "Operator", models.RoleOperator)
+
+	send := func(method, path, auth string, payload interface{}) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(payload)
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	credentials := map[string]string{"name": "Cheater", "password": "secret-pass"}
+	w := send(http.MethodPost, "/players", "", credentials)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var cheater models.Player
+	db.Where("name = ?", "Cheater").First(&cheater)
+	db.Model(&cheater).Update("balance", 100)
+
+	var sanction models.Sanction
+	var appealToken string
+
+	t.Run("suspensions need an expiry", func(t *testing.T) {
+		w := send(http.MethodPost, "/players/"+itoa(cheater.ID)+"/sanctions", operatorToken,
+			map[string]string{"kind": models.SanctionSuspension, "reason": "aimbot"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("suspend", func(t *testing.T) {
+		w := send(http.MethodPost, "/players/"+itoa(cheater.ID)+"/sanctions", operatorToken, map[string]interface{}{
+			"kind":       models.SanctionSuspension,
+			"reason":     "aimbot",
+			"expires_at": time.Now().Add(24 * time.Hour),
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Data models.Sanction `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		sanction = response.Data
+		assert.Equal(t, models.AppealNone, sanction.AppealStatus)
+
+		var logs int64
+		db.Model(&models.GameLog{}).Where("player_id = ? AND action = ?", cheater.ID, models.ActionSanctionIssued).Count(&logs)
+		assert.Equal(t, int64(1), logs)
+	})
+
+	t.Run("login refused", func(t *testing.T) {
+		w := send(http.MethodPost, "/auth/login", "", credentials)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "aimbot")
+
+		var refused struct {
+			Data struct {
+				AppealSession struct {
+					Token string `json:"token"`
+				} `json:"appeal_session"`
+			} `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &refused))
+		assert.NotEmpty(t, refused.Data.AppealSession.Token)
+		appealToken = "Bearer " + refused.Data.AppealSession.Token
+	})
+
+	t.Run("the appeal session only reaches sanctions", func(t *testing.T) {
+		w := send(http.MethodGet, "/players/"+itoa(cheater.ID), appealToken, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		w = send(http.MethodPost, "/challenges", appealToken, map[string]interface{}{})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = send(http.MethodGet, "/players/"+itoa(cheater.ID)+"/sanctions", appealToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("challenge refused", func(t *testing.T) {
+		w := send(http.MethodPost, "/challenges", operatorToken, map[string]interface{}{"player_id": cheater.ID, "amount": 20.01})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("the player appeals and a moderator accepts", func(t *testing.T) {
+		w := send(http.MethodPost, "/sanctions/"+itoa(sanction.ID)+"/appeal", appealToken, map[string]string{"note": "it was lag"})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = send(http.MethodPost, "/sanctions/"+itoa(sanction.ID)+"/appeal", operatorToken, map[string]string{"note": "again"})
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w = send(http.MethodPost, "/sanctions/"+itoa(sanction.ID)+"/appeal/decision", operatorToken, map[string]string{"status": models.AppealAccepted})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = send(http.MethodPost, "/auth/login", "", credentials)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("history visible to the player", func(t *testing.T) {
+		var login struct {
+			Data struct {
+				Token string `json:"token"`
+			} `json:"data"`
+		}
+		w := send(http.MethodPost, "/auth/login", "", credentials)
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &login))
+
+		w = send(http.MethodGet, "/players/"+itoa(cheater.ID)+"/sanctions", "Bearer "+login.Data.Token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Data []models.Sanction `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Len(t, response.Data, 1)
+		assert.NotNil(t, response.Data[0].RevokedAt)
+		assert.Equal(t, models.AppealAccepted, response.Data[0].AppealStatus)
+	})
+}
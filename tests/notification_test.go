@@ -0,0 +1,241 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"oxo-game-api/internal/models"
+	"oxo-game-api/internal/notify"
+	"oxo-game-api/internal/outbox"
+	"oxo-game-api/internal/scheduling"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotifications(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupTestDB()
+	router := SetupTestRouter(db)
+	alice, aliceToken := IssueToken(db, "Alice", models.RolePlayer)
+	bob, bobToken := IssueToken(db, "Bob", models.RolePlayer)
+
+	send := func(method, path, token string, payload interface{}) *httptest.ResponseRecorder {
+		var body []byte
+		if payload != nil {
+			body, _ = json.Marshal(payload)
+		}
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	inbox := func(playerID uint, token, query string) []models.Notification {
+		w := send(http.MethodGet, "/players/"+itoa(playerID)+"/notifications"+query, token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var body struct {
+			Data struct {
+				Items []models.Notification `json:"items"`
+			} `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body.Data.Items
+	}
+
+	var mu sync.Mutex
+	var hooks []notify.Message
+	failHook := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, "sha256="+notify.Sign("secret", body), r.Header.Get(notify.HeaderSignature))
+		mu.Lock()
+		defer mu.Unlock()
+		if failHook {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var msg notify.Message
+		assert.NoError(t, json.Unmarshal(body, &msg))
+		assert.Equal(t, itoa(msg.EventID), r.Header.Get(notify.HeaderEventID))
+		hooks = append(hooks, msg)
+	}))
+	defer server.Close()
+
+	mailer := notify.NewFakeMailer()
+	dispatcher := notify.NewDispatcher(db,
+		notify.NewInboxChannel(db),
+		notify.NewWebhookChannel(server.URL, "secret"),
+		notify.NewEmailChannel(mailer, "no-reply@example.com"),
+	)
+
+	w := send(http.MethodPatch, "/players/"+itoa(alice.ID)+"/profile", aliceToken, gin.H{"email": "alice@example.com", "timezone": "Asia/Taipei"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = send(http.MethodPatch, "/players/"+itoa(bob.ID)+"/profile", bobToken, gin.H{"notifications": gin.H{"payment_updates": false}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = send(http.MethodPatch, "/players/"+itoa(bob.ID)+"/profile", bobToken, gin.H{"email": "not an email"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	room := models.Room{Name: "Lounge", Status: models.RoomAvailable, Capacity: 4, OpensAt: "00:00", ClosesAt: "24:00", Timezone: "UTC"}
+	db.Create(&room)
+	now := time.Now()
+	reservation := models.Reservation{RoomID: room.ID, PlayerID: alice.ID, StartsAt: now.Add(30 * time.Minute), EndsAt: now.Add(90 * time.Minute), Status: models.ReservationBooked}
+	db.Create(&reservation)
+	db.Create(&models.ReservationParticipant{ReservationID: reservation.ID, PlayerID: alice.ID, Role: models.ParticipantHost, InvitedBy: alice.ID, Status: models.InvitationAccepted})
+	later := models.Reservation{RoomID: room.ID, PlayerID: alice.ID, StartsAt: now.Add(5 * time.Hour), EndsAt: now.Add(6 * time.Hour), Status: models.ReservationBooked}
+	db.Create(&later)
+	db.Create(&models.ReservationParticipant{ReservationID: later.ID, PlayerID: alice.ID, Role: models.ParticipantHost, InvitedBy: alice.ID, Status: models.InvitationAccepted})
+
+	t.Run("reservations starting soon are reminded once", func(t *testing.T) {
+		sent, err := scheduling.SendReminders(db, time.Hour, now)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, sent)
+
+		sent, err = scheduling.SendReminders(db, time.Hour, now.Add(time.Minute))
+		assert.NoError(t, err)
+		assert.Zero(t, sent)
+
+		db.Model(&reservation).Update("starts_at", now.Add(45*time.Minute))
+		sent, err = scheduling.SendReminders(db, time.Hour, now.Add(time.Minute))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, sent)
+	})
+
+	t.Run("payments notify about their status", func(t *testing.T) {
+		w := send(http.MethodPost, "/payments", aliceToken, gin.H{"amount": 10, "method": models.MethodCreditCard})
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = send(http.MethodPost, "/payments", bobToken, gin.H{"amount": 10, "method": models.MethodThirdParty})
+		assert.Equal(t, http.StatusPaymentRequired, w.Code)
+
+		var count int64
+		db.Model(&models.OutboxEvent{}).Where("type = ?", notify.EventPaymentStatus).Count(&count)
+		assert.Equal(t, int64(2), count)
+	})
+
+	t.Run("the relay delivers to every channel", func(t *testing.T) {
+		delivered, err := outbox.Relay(db, dispatcher.Deliver, time.Now())
+		assert.NoError(t, err)
+		assert.Equal(t, 4, delivered)
+
+		items := inbox(alice.ID, aliceToken, "")
+		if assert.Len(t, items, 3) {
+			assert.Equal(t, notify.EventPaymentStatus, items[0].Type)
+			assert.Equal(t, scheduling.EventReservationReminder, items[2].Type)
+			assert.Contains(t, items[2].Body, "Lounge")
+			assert.Contains(t, items[2].Body, "CST")
+		}
+		assert.Len(t, hooks, 3)
+
+		mails := mailer.Sent()
+		if assert.Len(t, mails, 3) {
+			assert.Equal(t, "alice@example.com", mails[0].To)
+			assert.Equal(t, "Your reservation starts soon", mails[0].Subject)
+		}
+	})
+
+	t.Run("opted out players get nothing", func(t *testing.T) {
+		assert.Empty(t, inbox(bob.ID, bobToken, ""))
+
+		var event models.OutboxEvent
+		db.Where("player_id = ?", bob.ID).First(&event)
+		assert.NotNil(t, event.PublishedAt)
+	})
+
+	t.Run("only the failing channel is retried", func(t *testing.T) {
+		mu.Lock()
+		failHook = true
+		mu.Unlock()
+		mailed := len(mailer.Sent())
+		w := send(http.MethodPost, "/payments", aliceToken, gin.H{"amount": 5, "method": models.MethodBankTransfer})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		now := time.Now()
+		delivered, err := outbox.Relay(db, dispatcher.Deliver, now)
+		assert.NoError(t, err)
+		assert.Zero(t, delivered)
+		var event models.OutboxEvent
+		db.Order("id desc").First(&event)
+		assert.Nil(t, event.PublishedAt)
+		assert.Contains(t, event.LastError, "webhook")
+		assert.Equal(t, "inbox,email", event.Delivered)
+		assert.Equal(t, 1, event.Attempts)
+		assert.True(t, event.NextAttemptAt.After(now))
+		assert.Len(t, mailer.Sent(), mailed+1)
+
+		delivered, err = outbox.Relay(db, dispatcher.Deliver, now)
+		assert.NoError(t, err)
+		assert.Zero(t, delivered, "the retry waits for the backoff")
+
+		mu.Lock()
+		failHook = false
+		mu.Unlock()
+		delivered, err = outbox.Relay(db, dispatcher.Deliver, now.Add(time.Minute))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, delivered)
+		assert.Len(t, inbox(alice.ID, aliceToken, ""), 4)
+		assert.Len(t, mailer.Sent(), mailed+1)
+		assert.Len(t, hooks, 4)
+	})
+
+	t.Run("players read their own inbox", func(t *testing.T) {
+		w := send(http.MethodGet, "/players/"+itoa(alice.ID)+"/notifications", bobToken, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		items := inbox(alice.ID, aliceToken, "?unread=true")
+		assert.Len(t, items, 4)
+
+		w = send(http.MethodPost, "/players/"+itoa(alice.ID)+"/notifications/"+itoa(items[0].ID)+"/read", aliceToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Len(t, inbox(alice.ID, aliceToken, "?unread=true"), 3)
+
+		w = send(http.MethodPost, "/players/"+itoa(bob.ID)+"/notifications/"+itoa(items[0].ID)+"/read", bobToken, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = send(http.MethodPost, "/players/"+itoa(alice.ID)+"/notifications/read-all", aliceToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, inbox(alice.ID, aliceToken, "?unread=true"))
+		assert.Len(t, inbox(alice.ID, aliceToken, ""), 4)
+	})
+
+	t.Run("an event failing every attempt is given up", func(t *testing.T) {
+		mu.Lock()
+		failHook = true
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			failHook = false
+			mu.Unlock()
+		}()
+		mailed := len(mailer.Sent())
+		w := send(http.MethodPost, "/payments", aliceToken, gin.H{"amount": 7, "method": models.MethodBankTransfer})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		at := time.Now()
+		for i := 0; i < outbox.MaxAttempts; i++ {
+			delivered, err := outbox.Relay(db, dispatcher.Deliver, at)
+			assert.NoError(t, err)
+			assert.Zero(t, delivered)
+			at = at.Add(2 * time.Hour)
+		}
+
+		var event models.OutboxEvent
+		db.Order("id desc").First(&event)
+		assert.NotNil(t, event.DeadAt)
+		assert.Nil(t, event.PublishedAt)
+		assert.Equal(t, outbox.MaxAttempts, event.Attempts)
+		assert.Len(t, mailer.Sent(), mailed+1)
+
+		delivered, err := outbox.Relay(db, dispatcher.Deliver, at)
+		assert.NoError(t, err)
+		assert.Zero(t, delivered)
+		db.First(&event, event.ID)
+		assert.Equal(t, outbox.MaxAttempts, event.Attempts)
+	})
+}
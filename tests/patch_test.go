@@ -0,0 +1,142 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"oxo-game-api/internal/models"
+	"oxo-game-api/pkg/utils/mergepatch"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergePatchApply(t *testing.T) {
+	cases := []struct {
+		name, doc, patch, want string
+	}{
+		{"replace member", `{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{"add member", `{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{"remove member", `{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{"nested merge", `{"a":{"b":"c","d":"e"}}`, `{"a":{"d":null,"f":"g"}}`, `{"a":{"b":"c","f":"g"}}`},
+		{"arrays are replaced", `{"a":[1,2]}`, `{"a":[3]}`, `{"a":[3]}`},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := mergepatch.Apply([]byte(tc.doc), []byte(tc.patch))
+			assert.NoError(t, err)
+			assert.JSONEq(t, tc.want, string(got))
+		})
+	}
+
+	_, err := mergepatch.Apply([]byte(`{}`), []byte(`["not an object"]`))
+	assert.ErrorIs(t, err, mergepatch.ErrInvalidPatch)
+}
+
+func TestRoomPatchAndETag(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupTestDB()
+	router := SetupTestRouter(db)
+	_, adminToken := IssueToken(db, "Admin", models.RoleAdmin)
+
+	room := models.Room{Name: "Patchable", Description: "Before", Status: "available"}
+	db.Create(&room)
+	path := "/rooms/" + itoa(room.ID)
+
+	send := func(method, ifMatch string, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", mergepatch.ContentType)
+		req.Header.Set("Authorization", adminToken)
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send(http.MethodGet, "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	tag := w.Header().Get("ETag")
+	assert.NotEmpty(t, tag)
+
+	t.Run("patch keeps other fields", func(t *testing.T) {
+		w := send(http.MethodPatch, tag, `{"description":"After"}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotEqual(t, tag, w.Header().Get("ETag"))
+
+		var response struct {
+			Data models.Room `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "Patchable", response.Data.Name)
+		assert.Equal(t, "After", response.Data.Description)
+	})
+
+	t.Run("stale etag is rejected", func(t *testing.T) {
+		w := send(http.MethodPatch, tag, `{"description":"Lost update"}`)
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+		w = send(http.MethodDelete, tag, "")
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	})
+
+	t.Run("removing a required field fails validation", func(t *testing.T) {
+		w := send(http.MethodPatch, "", `{"name":null}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("put still needs every field", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPut, path, bytes.NewBufferString(`{"description":"Only this"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", adminToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestPlayerPatchETag(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupTestDB()
+	router := SetupTestRouter(db)
+	db.Create(&models.Level{Name: "Beginner", MinExp: 0, MaxExp: 1000})
+	_, adminToken := IssueToken(db, "Admin", models.RoleAdmin)
+	player, _ := IssueToken(db, "Patched", models.RolePlayer)
+	db.Model(&player).Updates(map[string]interface{}{"experience": 150, "balance": 42})
+
+	patch := func(ifMatch, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodPatch, "/players/"+itoa(player.ID), bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", mergepatch.ContentType)
+		req.Header.Set("Authorization", adminToken)
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := patch("", `{"name":"Patched Once"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	tag := w.Header().Get("ETag")
+	assert.NotEmpty(t, tag)
+
+	t.Run("the etag of a patch response allows the next patch", func(t *testing.T) {
+		w := patch(tag, `{"name":"Patched Twice"}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotEqual(t, tag, w.Header().Get("ETag"))
+	})
+
+	t.Run("a patch leaves the columns it does not control alone", func(t *testing.T) {
+		var stored models.Player
+		db.First(&stored, player.ID)
+		assert.Equal(t, "Patched Twice", stored.Name)
+		assert.Equal(t, uint(150), stored.Experience)
+		assert.Equal(t, float64(42), stored.Balance)
+	})
+}
@@ -17,6 +17,7 @@ func TestCreatePayment(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	db := SetupTestDB()
 	router := SetupTestRouter(db)
+	_, token := IssueToken(db, "Token Owner", models.RolePlayer)
 
 	t.Run("successful payment creation", func(t *testing.T) {
 		payment := models.Payment{Amount: 100.0, Method: "Credit Card"}
@@ -24,6 +25,7 @@ func TestCreatePayment(t *testing.T) {
 
 		req, _ := http.NewRequest(http.MethodPost, "/payments", bytes.NewBuffer(body))
 		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", token)
 		w := httptest.NewRecorder()
 		router.ServeHTTP(w, req)
 
@@ -0,0 +1,134 @@
+package tests
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"oxo-game-api/internal/models"
+	"oxo-game-api/pkg/utils/bulk"
+	"oxo-game-api/pkg/utils/response"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBulkPlayerImport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupTestDB()
+	router := SetupTestRouter(db)
+	_, adminToken := IssueToken(db, "Admin", models.RoleAdmin)
+
+	send := func(mode, contentType, body string) (int, response.BulkImportResponse) {
+		req, _ := http.NewRequest(http.MethodPost, "/players:bulk?mode="+mode, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Authorization", adminToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var result struct {
+			Data response.BulkImportResponse `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &result)
+		return w.Code, result.Data
+	}
+
+	countPlayers := func() int64 {
+		var count int64
+		db.Model(&models.Player{}).Count(&count)
+		return count
+	}
+
+	t.Run("all or nothing rolls back on one bad row", func(t *testing.T) {
+		before := countPlayers()
+		code, result := send("all_or_nothing", bulk.MIMECSV, "name,level_id\nCsv One,\nCsv Two,\n,\n")
+		assert.Equal(t, http.StatusUnprocessableEntity, code)
+		assert.Equal(t, 1, result.Failed)
+		assert.Equal(t, 4, result.Rows[2].Line)
+		assert.Equal(t, "skipped", result.Rows[0].Status)
+		assert.Equal(t, before, countPlayers())
+	})
+
+	t.Run("csv import", func(t *testing.T) {
+		code, result := send("all_or_nothing", bulk.MIMECSV, "name,password\nCsv One,secret-pass\nCsv Two,\n")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, 2, result.Created)
+		assert.NotZero(t, result.Rows[0].PlayerID)
+	})
+
+	t.Run("best effort ndjson import", func(t *testing.T) {
+		body := `{"name":"Json One"}` + "\n" + `{"name":"Csv One"}` + "\n" + `{"name":"Json One"}` + "\n" + `{"nickname":"x"}` + "\n"
+		code, result := send("best_effort", bulk.MIMENDJSON, body)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, 1, result.Created)
+		assert.Equal(t, 3, result.Failed)
+		assert.Equal(t, "Player name already exists", result.Rows[1].Error)
+		assert.Equal(t, "duplicate name in import", result.Rows[2].Error)
+	})
+
+	t.Run("imported players start at the bottom of their level", func(t *testing.T) {
+		beginner := models.Level{Name: "Beginner", MinExp: 0, MaxExp: 100}
+		expert := models.Level{Name: "Expert", MinExp: 101, MaxExp: 1000}
+		db.Create(&beginner)
+		db.Create(&expert)
+
+		body := `{"name":"Imported Expert","level_id":` + itoa(expert.ID) + `}` + "\n" + `{"name":"Imported Rookie"}` + "\n"
+		code, result := send("all_or_nothing", bulk.MIMENDJSON, body)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, 2, result.Created)
+
+		var imported, rookie models.Player
+		db.Where("name = ?", "Imported Expert").First(&imported)
+		assert.Equal(t, expert.ID, imported.LevelID)
+		assert.Equal(t, uint(101), imported.Experience)
+		db.Where("name = ?", "Imported Rookie").First(&rookie)
+		assert.Equal(t, beginner.ID, rookie.LevelID)
+		assert.Zero(t, rookie.Experience)
+	})
+
+	t.Run("unsupported content type", func(t *testing.T) {
+		code, _ := send("best_effort", "application/xml", "<players/>")
+		assert.Equal(t, http.StatusUnsupportedMediaType, code)
+	})
+}
+
+func TestPlayerExport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupTestDB()
+	router := SetupTestRouter(db)
+	_, operatorToken := IssueToken(db, "Operator", models.RoleOperator)
+	db.Create(&models.Player{Name: "Exported", Balance: 12.5})
+
+	export := func(format string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, "/players:export?format="+format, nil)
+		req.Header.Set("Authorization", operatorToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("ndjson", func(t *testing.T) {
+		w := export("ndjson")
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		lines := 0
+		scanner := bufio.NewScanner(w.Body)
+		for scanner.Scan() {
+			var player models.Player
+			assert.NoError(t, json.Unmarshal(scanner.Bytes(), &player))
+			lines++
+		}
+		assert.Equal(t, 2, lines)
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		w := export("csv")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "text/csv")
+		assert.True(t, strings.HasPrefix(w.Body.String(), "id,name,level_id,balance"))
+		assert.Contains(t, w.Body.String(), "Exported,0,12.5")
+	})
+}
@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"bytes"
 	"encoding/json"
 
 	"net/http"
@@ -17,6 +18,8 @@ func TestGetPlayers(t *testing.T) {
 	db := SetupTestDB()
 	router := SetupTestRouter(db)
 
+	_, token := IssueToken(db, "Operator", models.RoleOperator)
+
 	testPlayers := []models.Player{
 		{Name: "Player 1", Balance: 0},
 		{Name: "Player 2", Balance: 0},
@@ -37,13 +40,17 @@ func TestGetPlayers(t *testing.T) {
 
 	w := httptest.NewRecorder()
 	req := httptest.NewRequest("GET", "/players", nil)
+	req.Header.Set("Authorization", token)
 	router.ServeHTTP(w, req)
 
 	t.Log("Response Status:", w.Code)
 	t.Log("Response Body:", w.Body.String())
 
 	var response struct {
-		Data []models.Player `json:"data"`
+		Data struct {
+			Items []models.Player `json:"items"`
+			Total int64           `json:"total"`
+		} `json:"data"`
 	}
 	err := json.NewDecoder(w.Body).Decode(&response)
 	if err != nil {
@@ -52,9 +59,61 @@ func TestGetPlayers(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.NoError(t, err)
-	assert.Equal(t, len(testPlayers), len(response.Data))
-	assert.Equal(t, testPlayers[0].Name, response.Data[0].Name)
-	assert.Equal(t, testPlayers[1].Name, response.Data[1].Name)
+	assert.Equal(t, int64(len(testPlayers)+1), response.Data.Total)
+	assert.Equal(t, testPlayers[0].Name, response.Data.Items[1].Name)
+	assert.Equal(t, testPlayers[1].Name, response.Data.Items[2].Name)
+}
+
+func TestSearchPlayers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupTestDB()
+	router := SetupTestRouter(db)
+	_, token := IssueToken(db, "Operator", models.RoleOperator)
+
+	db.Create(&models.Player{Name: "Alice", Balance: 50})
+	db.Create(&models.Player{Name: "Alfred", Balance: 150})
+	db.Create(&models.Player{Name: "Bob", Balance: 300})
+
+	search := func(query string) (int, []models.Player, int64) {
+		req, _ := http.NewRequest(http.MethodGet, "/players?"+query, nil)
+		req.Header.Set("Authorization", token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var response struct {
+			Data struct {
+				Items []models.Player `json:"items"`
+				Total int64           `json:"total"`
+			} `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response.Data.Items, response.Data.Total
+	}
+
+	t.Run("prefix search with balance range", func(t *testing.T) {
+		code, players, total := search("name_prefix=al&min_balance=100")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, int64(1), total)
+		assert.Equal(t, "Alfred", players[0].Name)
+	})
+
+	t.Run("sorted and paginated", func(t *testing.T) {
+		code, players, total := search("sort=-balance&limit=2")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, int64(4), total)
+		assert.Len(t, players, 2)
+		assert.Equal(t, "Bob", players[0].Name)
+	})
+
+	t.Run("unknown sort field", func(t *testing.T) {
+		code, _, _ := search("sort=password_hash")
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("unknown query parameter", func(t *testing.T) {
+		code, _, _ := search("role=admin")
+		assert.Equal(t, http.StatusBadRequest, code)
+	})
 }
 
 func TestGetPlayerByID(t *testing.T) {
@@ -64,9 +123,11 @@ func TestGetPlayerByID(t *testing.T) {
 
 	player := models.Player{Name: "Player 8"}
 	db.Create(&player)
+	_, token := IssueToken(db, "Operator", models.RoleOperator)
 
 	t.Run("successful fetch of player by ID", func(t *testing.T) {
 		req, _ := http.NewRequest(http.MethodGet, "/players/1", nil)
+		req.Header.Set("Authorization", token)
 		w := httptest.NewRecorder()
 		router.ServeHTTP(w, req)
 
@@ -80,6 +141,7 @@ func TestGetPlayerByID(t *testing.T) {
 
 	t.Run("player not found", func(t *testing.T) {
 		req, _ := http.NewRequest(http.MethodGet, "/players/999", nil)
+		req.Header.Set("Authorization", token)
 		w := httptest.NewRecorder()
 		router.ServeHTTP(w, req)
 
@@ -99,3 +161,25 @@ func TestGetPlayerByID(t *testing.T) {
 		assert.Nil(t, response.Data)
 	})
 }
+
+func TestCreatePlayerStartsOnTheLowestLevel(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupTestDB()
+	router := SetupTestRouter(db)
+	beginner := models.Level{Name: "Beginner", MinExp: 0, MaxExp: 100}
+	expert := models.Level{Name: "Expert", MinExp: 101, MaxExp: 1000}
+	db.Create(&beginner)
+	db.Create(&expert)
+
+	body, _ := json.Marshal(map[string]interface{}{"name": "Climber", "level_id": expert.ID})
+	req := httptest.NewRequest(http.MethodPost, "/players", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var player models.Player
+	db.Where("name = ?", "Climber").First(&player)
+	assert.Equal(t, beginner.ID, player.LevelID)
+	assert.Zero(t, player.Experience)
+}
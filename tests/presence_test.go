@@ -0,0 +1,177 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"oxo-game-api/internal/api/handlers"
+	"oxo-game-api/internal/models"
+	"oxo-game-api/internal/presence"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoomPresence(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupTestDB()
+	router := SetupTestRouter(db)
+	alice, aliceToken := IssueToken(db, "Alice", models.RolePlayer)
+	bob, bobToken := IssueToken(db, "Bob", models.RolePlayer)
+	_, carolToken := IssueToken(db, "Carol", models.RolePlayer)
+	_, operatorToken := IssueToken(db, "Operator", models.RoleOperator)
+
+	room := models.Room{Name: "Lounge", Status: models.RoomAvailable, Capacity: 2, OpensAt: "00:00", ClosesAt: "24:00", Timezone: "UTC"}
+	db.Create(&room)
+	other := models.Room{Name: "Library", Status: models.RoomAvailable, Capacity: 4, OpensAt: "00:00", ClosesAt: "24:00", Timezone: "UTC"}
+	db.Create(&other)
+	shut := models.Room{Name: "Workshop", Status: models.RoomMaintenance, Capacity: 4, OpensAt: "00:00", ClosesAt: "24:00", Timezone: "UTC"}
+	db.Create(&shut)
+
+	send := func(method, path, token string, payload interface{}) *httptest.ResponseRecorder {
+		var body []byte
+		if payload != nil {
+			body, _ = json.Marshal(payload)
+		}
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	occupants := func(roomID uint, token string) handlers.Occupancy {
+		w := send(http.MethodGet, "/rooms/"+itoa(roomID)+"/occupants", token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var body struct {
+			Data handlers.Occupancy `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body.Data
+	}
+	status := func(roomID uint) string {
+		var current models.Room
+		db.Unscoped().First(&current, roomID)
+		return current.Status
+	}
+	logs := func(playerID uint, action string) int64 {
+		var count int64
+		db.Model(&models.GameLog{}).Where("player_id = ? AND action = ?", playerID, action).Count(&count)
+		return count
+	}
+
+	t.Run("entering logs it and occupies the room", func(t *testing.T) {
+		tag := send(http.MethodGet, "/rooms/"+itoa(room.ID), operatorToken, nil).Header().Get("ETag")
+		w := send(http.MethodPost, "/rooms/"+itoa(room.ID)+"/enter", aliceToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, models.RoomOccupied, status(room.ID))
+		assert.Equal(t, tag, send(http.MethodGet, "/rooms/"+itoa(room.ID), operatorToken, nil).Header().Get("ETag"), "occupancy does not change the room's ETag")
+		assert.Equal(t, int64(1), logs(alice.ID, models.ActionEnterRoom))
+
+		w = send(http.MethodPost, "/rooms/"+itoa(room.ID)+"/enter", aliceToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, int64(1), logs(alice.ID, models.ActionEnterRoom))
+
+		w = send(http.MethodPost, "/rooms/"+itoa(room.ID)+"/enter", bobToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		occupancy := occupants(room.ID, operatorToken)
+		assert.Equal(t, 2, occupancy.Count)
+		if assert.Len(t, occupancy.Occupants, 2) {
+			assert.Equal(t, alice.ID, occupancy.Occupants[0].PlayerID)
+			assert.Equal(t, "Alice", occupancy.Occupants[0].Name)
+		}
+	})
+
+	t.Run("a full or closed room cannot be entered", func(t *testing.T) {
+		w := send(http.MethodPost, "/rooms/"+itoa(room.ID)+"/enter", carolToken, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w = send(http.MethodPost, "/rooms/"+itoa(shut.ID)+"/enter", carolToken, nil)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+		w = send(http.MethodPost, "/rooms/9999/enter", carolToken, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("players cannot move others", func(t *testing.T) {
+		w := send(http.MethodPost, "/rooms/"+itoa(other.ID)+"/enter", carolToken, gin.H{"player_id": alice.ID})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("hidden players are counted but not listed", func(t *testing.T) {
+		occupancy := occupants(room.ID, carolToken)
+		assert.Equal(t, 2, occupancy.Count)
+		assert.Empty(t, occupancy.Occupants)
+
+		w := send(http.MethodPut, "/players/"+itoa(bob.ID)+"/privacy", bobToken, map[string]string{"presence_visibility": models.VisibilityPublic})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		occupancy = occupants(room.ID, carolToken)
+		if assert.Len(t, occupancy.Occupants, 1) {
+			assert.Equal(t, bob.ID, occupancy.Occupants[0].PlayerID)
+		}
+	})
+
+	t.Run("entering another room leaves the current one", func(t *testing.T) {
+		w := send(http.MethodPost, "/rooms/"+itoa(other.ID)+"/enter", bobToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, int64(1), logs(bob.ID, models.ActionLeaveRoom))
+		assert.Equal(t, 1, occupants(room.ID, operatorToken).Count)
+		assert.Equal(t, models.RoomOccupied, status(other.ID))
+	})
+
+	t.Run("the last player out frees the room", func(t *testing.T) {
+		w := send(http.MethodPost, "/rooms/"+itoa(room.ID)+"/leave", aliceToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, models.RoomAvailable, status(room.ID))
+		assert.Equal(t, int64(1), logs(alice.ID, models.ActionLeaveRoom))
+
+		w = send(http.MethodPost, "/rooms/"+itoa(room.ID)+"/leave", aliceToken, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("game logs for entering go through presence", func(t *testing.T) {
+		w := send(http.MethodPost, "/logs", aliceToken, gin.H{"action": models.ActionEnterRoom, "room_id": room.ID, "details": "from lobby"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, models.RoomOccupied, status(room.ID))
+		assert.Equal(t, 1, occupants(room.ID, operatorToken).Count)
+
+		var entered models.GameLog
+		db.Where("player_id = ? AND action = ?", alice.ID, models.ActionEnterRoom).Order("id desc").First(&entered)
+		assert.Equal(t, "from lobby", entered.Details)
+	})
+
+	t.Run("sessions without a heartbeat time out", func(t *testing.T) {
+		later := time.Now().Add(3 * time.Minute)
+		_, err := Tracker.Heartbeat(bob.ID, other.ID, later)
+		assert.NoError(t, err)
+
+		ended, err := Tracker.Sweep(later.Add(4 * time.Minute))
+		assert.NoError(t, err)
+		if assert.Len(t, ended, 1) {
+			assert.Equal(t, alice.ID, ended[0].PlayerID)
+		}
+		assert.Equal(t, models.RoomAvailable, status(room.ID))
+		assert.Equal(t, models.RoomOccupied, status(other.ID))
+
+		var left models.GameLog
+		db.Where("player_id = ? AND action = ?", alice.ID, models.ActionLeaveRoom).Order("id desc").First(&left)
+		assert.Equal(t, presence.ReasonTimedOut, left.Details)
+	})
+
+	t.Run("sessions are restored from the logs", func(t *testing.T) {
+		restored := presence.NewTracker(db, presence.NewMemoryStore(), 5*time.Minute)
+		assert.NoError(t, restored.Restore(time.Now()))
+
+		sessions := restored.Occupants(other.ID)
+		if assert.Len(t, sessions, 1) {
+			assert.Equal(t, bob.ID, sessions[0].PlayerID)
+		}
+		assert.Empty(t, restored.Occupants(room.ID))
+	})
+}
@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"oxo-game-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlayerDataExport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupTestDB()
+	router := SetupTestRouter(db)
+	player, token := IssueToken(db, "Exporter", models.RolePlayer)
+	_, otherToken := IssueToken(db, "Other", models.RolePlayer)
+
+	db.Create(&models.GameLog{PlayerID: player.ID, Action: "login", Details: "from home"})
+	db.Create(&models.Payment{PlayerID: player.ID, Method: models.MethodCreditCard, Amount: 10, Status: "success", TransactionID: "tx-1"})
+
+	send := func(auth string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodGet, "/players/"+itoa(player.ID)+"/export", nil)
+		req.Header.Set("Authorization", auth)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("own data", func(t *testing.T) {
+		w := send(token)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Disposition"), "attachment")
+
+		var response struct {
+			Data struct {
+				Player   models.Player    `json:"player"`
+				GameLogs []models.GameLog `json:"game_logs"`
+				Payments []models.Payment `json:"payments"`
+			} `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "Exporter", response.Data.Player.Name)
+		assert.Len(t, response.Data.GameLogs, 1)
+		assert.Len(t, response.Data.Payments, 1)
+	})
+
+	t.Run("someone else's data", func(t *testing.T) {
+		w := send(otherToken)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
+
+func TestPlayerErasure(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupTestDB()
+	router := SetupTestRouter(db)
+	admin, adminToken := IssueToken(db, "Admin", models.RoleAdmin)
+	player, _ := IssueToken(db, "Forgetme", models.RolePlayer)
+
+	db.Create(&models.GameLog{PlayerID: player.ID, Action: "login", Details: "from home"})
+	payment := models.Payment{PlayerID: player.ID, Method: models.MethodCreditCard, Amount: 10, Details: "card 4111 1111 1111 1111", Status: "success", TransactionID: "tx-1"}
+	db.Create(&payment)
+	sanction := models.Sanction{PlayerID: player.ID, Kind: models.SanctionSuspension, Reason: "spam", IssuedBy: admin.ID, AppealStatus: models.AppealPending, AppealNote: "my brother used my account"}
+	db.Create(&sanction)
+	room := models.Room{Name: "Erasure Room", Status: models.RoomAvailable}
+	db.Create(&room)
+	start := time.Now().Add(24 * time.Hour)
+	reservation := models.Reservation{RoomID: room.ID, PlayerID: player.ID, StartsAt: start, EndsAt: start.Add(time.Hour), Status: models.ReservationCancelled, CancelReason: "I am in hospital"}
+	db.Create(&reservation)
+	transition := models.ReservationTransition{ReservationID: reservation.ID, FromStatus: models.ReservationBooked, ToStatus: models.ReservationCancelled, ActorID: &player.ID, Reason: "I am in hospital"}
+	db.Create(&transition)
+
+	send := func(method, path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(nil))
+		req.Header.Set("Authorization", adminToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send(http.MethodPost, "/players/"+itoa(player.ID)+"/erasure")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Data models.ErasureJob `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	jobID := response.Data.ID
+
+	assert.Eventually(t, func() bool {
+		var job models.ErasureJob
+		db.First(&job, jobID)
+		return job.Status == models.ErasureCompleted
+	}, 5*time.Second, 50*time.Millisecond)
+
+	t.Run("job status", func(t *testing.T) {
+		w := send(http.MethodGet, "/erasure-jobs/"+itoa(jobID))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), models.ErasureCompleted)
+	})
+
+	t.Run("personal data removed", func(t *testing.T) {
+		var erased models.Player
+		db.Unscoped().First(&erased, player.ID)
+		assert.Equal(t, "erased-"+itoa(player.ID), erased.Name)
+		assert.Empty(t, erased.PasswordHash)
+		assert.True(t, erased.DeletedAt.Valid)
+
+		var tokens int64
+		db.Model(&models.AuthToken{}).Where("player_id = ?", player.ID).Count(&tokens)
+		assert.Zero(t, tokens)
+
+		var logEntry models.GameLog
+		db.Where("player_id = ?", player.ID).First(&logEntry)
+		assert.Empty(t, logEntry.Details)
+
+		var appealed models.Sanction
+		db.First(&appealed, sanction.ID)
+		assert.Empty(t, appealed.AppealNote)
+		assert.Equal(t, "spam", appealed.Reason)
+
+		var cancelled models.Reservation
+		db.First(&cancelled, reservation.ID)
+		assert.Empty(t, cancelled.CancelReason)
+
+		var transitioned models.ReservationTransition
+		db.First(&transitioned, transition.ID)
+		assert.Empty(t, transitioned.Reason)
+	})
+
+	t.Run("financial records kept", func(t *testing.T) {
+		var kept models.Payment
+		assert.NoError(t, db.First(&kept, payment.ID).Error)
+		assert.Equal(t, player.ID, kept.PlayerID)
+		assert.Equal(t, 10.0, kept.Amount)
+		assert.Empty(t, kept.Details)
+	})
+}
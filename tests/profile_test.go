@@ -0,0 +1,84 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"oxo-game-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlayerProfile(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupTestDB()
+	router := SetupTestRouter(db)
+	player, token := IssueToken(db, "handle", models.RolePlayer)
+	_, otherToken := IssueToken(db, "Other", models.RolePlayer)
+
+	send := func(method, auth string, payload interface{}) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(payload)
+		req, _ := http.NewRequest(method, "/players/"+itoa(player.ID)+"/profile", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", auth)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	decode := func(w *httptest.ResponseRecorder) models.PlayerProfile {
+		var response struct {
+			Data models.PlayerProfile `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response.Data
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		w := send(http.MethodGet, token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		profile := decode(w)
+		assert.Equal(t, "handle", profile.DisplayName)
+		assert.Equal(t, models.DefaultTimezone, profile.Timezone)
+		assert.True(t, profile.Notifications.ChallengeResults)
+	})
+
+	t.Run("partial update", func(t *testing.T) {
+		w := send(http.MethodPatch, token, map[string]interface{}{
+			"display_name":  "The Handle",
+			"timezone":      "Asia/Taipei",
+			"notifications": map[string]bool{"challenge_results": false},
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		profile := decode(w)
+		assert.Equal(t, "The Handle", profile.DisplayName)
+		assert.Equal(t, "Asia/Taipei", profile.Timezone)
+		assert.Equal(t, models.DefaultLocale, profile.Locale)
+		assert.False(t, profile.Notifications.ChallengeResults)
+		assert.True(t, profile.Notifications.PaymentUpdates)
+	})
+
+	t.Run("invalid values", func(t *testing.T) {
+		w := send(http.MethodPatch, token, map[string]string{"timezone": "Mars/Olympus"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = send(http.MethodPatch, token, map[string]string{"avatar_url": "not a url"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("other players see the public part", func(t *testing.T) {
+		w := send(http.MethodGet, otherToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "The Handle")
+		assert.NotContains(t, w.Body.String(), "timezone")
+
+		w = send(http.MethodPatch, otherToken, map[string]string{"display_name": "Hacked"})
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
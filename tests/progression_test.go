@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"oxo-game-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAwardExperience(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupTestDB()
+	router := SetupTestRouter(db)
+
+	beginner := models.Level{Name: "Beginner", MinExp: 0, MaxExp: 100}
+	intermediate := models.Level{Name: "Intermediate", MinExp: 101, MaxExp: 200}
+	db.Create(&beginner)
+	db.Create(&intermediate)
+
+	_, adminToken := IssueToken(db, "Admin", models.RoleAdmin)
+	player := models.Player{Name: "Climber", LevelID: beginner.ID}
+	db.Create(&player)
+
+	award := func(amount int) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]interface{}{"amount": amount, "reason": "test"})
+		req, _ := http.NewRequest(http.MethodPost, "/players/"+itoa(player.ID)+"/experience", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", adminToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("promotion when crossing max exp", func(t *testing.T) {
+		w := award(150)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var updated models.Player
+		db.First(&updated, player.ID)
+		assert.Equal(t, uint(150), updated.Experience)
+		assert.Equal(t, intermediate.ID, updated.LevelID)
+	})
+
+	t.Run("demotion when dropping below min exp", func(t *testing.T) {
+		w := award(-100)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var updated models.Player
+		db.First(&updated, player.ID)
+		assert.Equal(t, uint(50), updated.Experience)
+		assert.Equal(t, beginner.ID, updated.LevelID)
+	})
+
+	t.Run("history records each change", func(t *testing.T) {
+		var changes []models.LevelChange
+		db.Where("player_id = ?", player.ID).Order("id").Find(&changes)
+		assert.Len(t, changes, 2)
+		assert.Equal(t, intermediate.ID, changes[0].ToLevelID)
+		assert.Equal(t, beginner.ID, changes[1].ToLevelID)
+	})
+
+	t.Run("unknown player", func(t *testing.T) {
+		body, _ := json.Marshal(map[string]interface{}{"amount": 10, "reason": "test"})
+		req, _ := http.NewRequest(http.MethodPost, "/players/9999/experience", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", adminToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"oxo-game-api/internal/models"
+	"oxo-game-api/pkg/utils/validator"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlayerRecovery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupTestDB()
+	router := SetupTestRouter(db)
+	_, adminToken := IssueToken(db, "Admin", models.RoleAdmin)
+
+	send := func(method, path string, payload interface{}) *httptest.ResponseRecorder {
+		var body []byte
+		if payload != nil {
+			body, _ = json.Marshal(payload)
+		}
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", adminToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	player := models.Player{Name: "Gone"}
+	db.Create(&player)
+	db.Delete(&player)
+
+	t.Run("deleted players are listed", func(t *testing.T) {
+		w := send(http.MethodGet, "/players/deleted", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Data struct {
+				Items []models.Player `json:"items"`
+			} `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Len(t, response.Data.Items, 1)
+		assert.Equal(t, "Gone", response.Data.Items[0].Name)
+	})
+
+	t.Run("deleted names stay reserved", func(t *testing.T) {
+		w := send(http.MethodPost, "/players", map[string]string{"name": "Gone"})
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		err := db.Create(&models.Player{Name: "Gone"}).Error
+		assert.True(t, validator.IsUniqueViolation(err))
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		w := send(http.MethodPost, "/players/"+itoa(player.ID)+"/restore", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var restored models.Player
+		assert.NoError(t, db.First(&restored, player.ID).Error)
+	})
+
+	t.Run("purge only deleted players", func(t *testing.T) {
+		w := send(http.MethodDelete, "/players/"+itoa(player.ID)+"/purge", nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		db.Delete(&player)
+		w = send(http.MethodDelete, "/players/"+itoa(player.ID)+"/purge", nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var count int64
+		db.Unscoped().Model(&models.Player{}).Where("id = ?", player.ID).Count(&count)
+		assert.Equal(t, int64(0), count)
+	})
+}
@@ -6,8 +6,11 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"oxo-game-api/internal/models" // Adjust the import path
+	"oxo-game-api/internal/outbox"
+	"oxo-game-api/internal/scheduling"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -17,23 +20,548 @@ func TestCreateReserv(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	db := SetupTestDB()
 	router := SetupTestRouter(db)
+	_, token := IssueToken(db, "Token Owner", models.RolePlayer)
 
-	t.Run("successful reservation creation", func(t *testing.T) {
-		reservation := models.Reservation{RoomID: 1, Date: "2023-01-01"}
-		body, _ := json.Marshal(reservation)
+	room := models.Room{Name: "Studio", Description: "Daytime room", Status: "available", OpensAt: "09:00", ClosesAt: "18:00", Timezone: "UTC"}
+	db.Create(&room)
+
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1)
+	at := func(hour, minute int) time.Time {
+		return time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), hour, minute, 0, 0, time.UTC)
+	}
 
-		req, _ := http.NewRequest(http.MethodPost, "/reservations", bytes.NewBuffer(body))
+	send := func(method, path string, payload interface{}) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(payload)
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(body))
 		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", token)
 		w := httptest.NewRecorder()
 		router.ServeHTTP(w, req)
+		return w
+	}
+
+	var firstID uint
 
+	t.Run("successful reservation creation", func(t *testing.T) {
+		w := send(http.MethodPost, "/reservations", gin.H{"room_id": room.ID, "starts_at": at(10, 0), "ends_at": at(11, 0)})
 		assert.Equal(t, http.StatusOK, w.Code)
 		t.Log("Response Body:", w.Body.String())
 
-		var createdReservation models.Reservation
-		err := json.Unmarshal(w.Body.Bytes(), &createdReservation)
+		var body struct {
+			Data struct {
+				ReservationID uint `json:"reservation_id"`
+			} `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		firstID = body.Data.ReservationID
+
+		var created models.Reservation
+		assert.NoError(t, db.First(&created, firstID).Error)
+		assert.True(t, at(10, 0).Equal(created.StartsAt))
+		assert.True(t, at(11, 0).Equal(created.EndsAt))
+	})
+
+	t.Run("duration sets the end", func(t *testing.T) {
+		w := send(http.MethodPost, "/reservations", gin.H{"room_id": room.ID, "starts_at": at(11, 0), "duration_minutes": 30})
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("overlapping reservation is rejected", func(t *testing.T) {
+		w := send(http.MethodPost, "/reservations", gin.H{"room_id": room.ID, "starts_at": at(10, 30), "duration_minutes": 60})
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), "conflicting_reservation_id")
+	})
+
+	t.Run("database rejects overlaps the API did not see", func(t *testing.T) {
+		err := db.Create(&models.Reservation{RoomID: room.ID, PlayerID: 1, StartsAt: at(10, 15), EndsAt: at(10, 45)}).Error
+		assert.Error(t, err)
+	})
+
+	t.Run("past start is rejected", func(t *testing.T) {
+		start := time.Now().Add(-2 * time.Hour)
+		w := send(http.MethodPost, "/reservations", gin.H{"room_id": room.ID, "starts_at": start, "duration_minutes": 30})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("end and duration together are rejected", func(t *testing.T) {
+		w := send(http.MethodPost, "/reservations", gin.H{"room_id": room.ID, "starts_at": at(14, 0), "ends_at": at(15, 0), "duration_minutes": 60})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("outside opening hours is rejected", func(t *testing.T) {
+		w := send(http.MethodPost, "/reservations", gin.H{"room_id": room.ID, "starts_at": at(17, 30), "duration_minutes": 60})
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+
+	t.Run("moving a reservation checks the new slot", func(t *testing.T) {
+		w := send(http.MethodPatch, "/reservations/"+itoa(firstID), gin.H{"starts_at": at(11, 15), "ends_at": at(12, 0)})
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w = send(http.MethodPatch, "/reservations/"+itoa(firstID), gin.H{"starts_at": at(13, 0), "duration_minutes": 90})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var moved models.Reservation
+		assert.NoError(t, db.First(&moved, firstID).Error)
+		assert.True(t, at(14, 30).Equal(moved.EndsAt))
+	})
+}
+
+func TestReservationLifecycle(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupTestDB()
+	router := SetupTestRouter(db)
+	player, token := IssueToken(db, "Booker", models.RolePlayer)
+	_, operatorToken := IssueToken(db, "Operator", models.RoleOperator)
+
+	room := models.Room{Name: "Studio", Description: "Open all day", Status: "available"}
+	db.Create(&room)
+
+	book := func(start time.Time) models.Reservation {
+		reservation := models.Reservation{RoomID: room.ID, PlayerID: player.ID, StartsAt: start, EndsAt: start.Add(time.Hour), Status: models.ReservationBooked}
+		db.Create(&reservation)
+		return reservation
+	}
+	send := func(method, path, auth string, payload interface{}) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(payload)
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", auth)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("cancelling frees the slot", func(t *testing.T) {
+		start := time.Now().Add(48 * time.Hour).Truncate(time.Hour)
+		reservation := book(start)
+
+		w := send(http.MethodPost, "/reservations/"+itoa(reservation.ID)+"/cancel", token, gin.H{"reason": "Plans changed"})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), models.ReservationCancelled)
+
+		w = send(http.MethodPost, "/reservations/"+itoa(reservation.ID)+"/cancel", token, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w = send(http.MethodPost, "/reservations", token, gin.H{"room_id": room.ID, "starts_at": start, "duration_minutes": 60})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = send(http.MethodGet, "/reservations/"+itoa(reservation.ID)+"/transitions", token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Plans changed")
+	})
+
+	t.Run("players cannot cancel inside the window", func(t *testing.T) {
+		reservation := book(time.Now().Add(time.Hour).Truncate(time.Minute))
+
+		w := send(http.MethodPost, "/reservations/"+itoa(reservation.ID)+"/cancel", token, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w = send(http.MethodPatch, "/reservations/"+itoa(reservation.ID), token, gin.H{"duration_minutes": 30})
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w = send(http.MethodPost, "/reservations/"+itoa(reservation.ID)+"/cancel", operatorToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("check-in opens shortly before the start", func(t *testing.T) {
+		later := book(time.Now().Add(5 * time.Hour).Truncate(time.Minute))
+		w := send(http.MethodPost, "/reservations/"+itoa(later.ID)+"/check-in", token, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		soon := book(time.Now().Add(10 * time.Minute).Truncate(time.Minute))
+		w = send(http.MethodPost, "/reservations/"+itoa(soon.ID)+"/check-in", token, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), models.ReservationCheckedIn)
+	})
+
+	t.Run("sweeper marks no-shows and completes finished reservations", func(t *testing.T) {
+		missed := book(time.Now().Add(-90 * time.Minute).Truncate(time.Minute))
+		finished := book(time.Now().Add(-3 * time.Hour).Truncate(time.Minute))
+		db.Model(&finished).Update("status", models.ReservationCheckedIn)
+
+		_, err := scheduling.Sweep(db, 15*time.Minute, time.Now())
 		assert.NoError(t, err)
-		assert.Equal(t, reservation.RoomID, createdReservation.RoomID)
-		assert.Equal(t, reservation.Date, createdReservation.Date)
+
+		db.First(&missed, missed.ID)
+		db.First(&finished, finished.ID)
+		assert.Equal(t, models.ReservationNoShow, missed.Status)
+		assert.Equal(t, models.ReservationCompleted, finished.Status)
+
+		var transition models.ReservationTransition
+		assert.NoError(t, db.Where("reservation_id = ?", missed.ID).Last(&transition).Error)
+		assert.Equal(t, models.ReservationNoShow, transition.ToStatus)
+		assert.Nil(t, transition.ActorID)
+	})
+}
+
+func TestReservationParticipants(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupTestDB()
+	router := SetupTestRouter(db)
+	host, hostToken := IssueToken(db, "Host", models.RolePlayer)
+	bob, bobToken := IssueToken(db, "Bob", models.RolePlayer)
+	carol, carolToken := IssueToken(db, "Carol", models.RolePlayer)
+	_, daveToken := IssueToken(db, "Dave", models.RolePlayer)
+
+	for _, friend := range []models.Player{bob, carol} {
+		db.Create(&models.Friendship{RequesterID: host.ID, AddresseeID: friend.ID, Status: models.FriendshipAccepted})
+	}
+
+	room := models.Room{Name: "Booth", Description: "Room for two", Status: "available", Capacity: 2}
+	db.Create(&room)
+
+	send := func(method, path, auth string, payload interface{}) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(payload)
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", auth)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	start := time.Now().Add(48 * time.Hour).Truncate(time.Hour)
+	w := send(http.MethodPost, "/reservations", hostToken, gin.H{"room_id": room.ID, "starts_at": start, "duration_minutes": 60})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var created struct {
+		Data struct {
+			ReservationID uint `json:"reservation_id"`
+		} `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	id := itoa(created.Data.ReservationID)
+
+	t.Run("host invites friends", func(t *testing.T) {
+		w := send(http.MethodPost, "/reservations/"+id+"/invitations", hostToken, gin.H{"player_ids": []uint{bob.ID, carol.ID}})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var body struct {
+			Data []models.ReservationParticipant `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		if assert.Len(t, body.Data, 3) {
+			assert.Equal(t, models.ParticipantHost, body.Data[0].Role)
+			assert.Equal(t, models.InvitationPending, body.Data[1].Status)
+		}
+	})
+
+	t.Run("invitees see the reservation", func(t *testing.T) {
+		w := send(http.MethodGet, "/reservations/"+id, bobToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = send(http.MethodGet, "/reservations/"+id, daveToken, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("accepting is limited by capacity", func(t *testing.T) {
+		w := send(http.MethodPost, "/reservations/"+id+"/accept", bobToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = send(http.MethodPost, "/reservations/"+id+"/accept", carolToken, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w = send(http.MethodPost, "/reservations/"+id+"/decline", carolToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = send(http.MethodPost, "/reservations/"+id+"/accept", daveToken, nil)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("list returns the participants", func(t *testing.T) {
+		w := send(http.MethodGet, "/reservations", bobToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var body struct {
+			Data []models.Reservation `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		if assert.Len(t, body.Data, 1) {
+			assert.Len(t, body.Data[0].Participants, 3)
+		}
+	})
+
+	t.Run("room cannot shrink below the players who joined", func(t *testing.T) {
+		_, adminToken := IssueToken(db, "Admin", models.RoleAdmin)
+		req, _ := http.NewRequest(http.MethodPatch, "/rooms/"+itoa(room.ID), bytes.NewBufferString(`{"capacity": 1}`))
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		req.Header.Set("Authorization", adminToken)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+}
+
+func TestReservationWaitlist(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupTestDB()
+	router := SetupTestRouter(db)
+	_, hostToken := IssueToken(db, "Host", models.RolePlayer)
+	second, secondToken := IssueToken(db, "Second", models.RolePlayer)
+	third, thirdToken := IssueToken(db, "Third", models.RolePlayer)
+
+	room := models.Room{Name: "Arena", Description: "Popular room", Status: "available"}
+	db.Create(&room)
+
+	send := func(method, path, auth string, payload interface{}) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(payload)
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", auth)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	start := time.Now().Add(48 * time.Hour).Truncate(time.Hour)
+	slot := gin.H{"room_id": room.ID, "starts_at": start, "duration_minutes": 60}
+	withWaitlist := gin.H{"room_id": room.ID, "starts_at": start, "duration_minutes": 60, "waitlist": true}
+
+	w := send(http.MethodPost, "/reservations", hostToken, slot)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var created struct {
+		Data struct {
+			ReservationID uint `json:"reservation_id"`
+		} `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+
+	t.Run("taken slot joins the waitlist", func(t *testing.T) {
+		w := send(http.MethodPost, "/reservations", secondToken, slot)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w = send(http.MethodPost, "/reservations", secondToken, withWaitlist)
+		assert.Equal(t, http.StatusAccepted, w.Code)
+
+		w = send(http.MethodPost, "/reservations", secondToken, withWaitlist)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w = send(http.MethodPost, "/reservations", thirdToken, withWaitlist)
+		assert.Equal(t, http.StatusAccepted, w.Code)
+	})
+
+	var entry models.WaitlistEntry
+
+	t.Run("cancellation promotes the first in line", func(t *testing.T) {
+		w := send(http.MethodPost, "/reservations/"+itoa(created.Data.ReservationID)+"/cancel", hostToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		assert.NoError(t, db.Where("player_id = ?", second.ID).First(&entry).Error)
+		assert.Equal(t, models.WaitlistOffered, entry.Status)
+		assert.NotNil(t, entry.ReservationID)
+		assert.NotNil(t, entry.OfferExpiresAt)
+
+		var waiting models.WaitlistEntry
+		db.Where("player_id = ?", third.ID).First(&waiting)
+		assert.Equal(t, models.WaitlistWaiting, waiting.Status)
+
+		var event models.OutboxEvent
+		assert.NoError(t, db.Where("player_id = ? AND type = ?", second.ID, scheduling.EventWaitlistPromoted).First(&event).Error)
+
+		w = send(http.MethodPost, "/reservations/waitlist/"+itoa(entry.ID)+"/confirm", thirdToken, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		w = send(http.MethodPost, "/reservations/waitlist/"+itoa(entry.ID)+"/confirm", secondToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("unconfirmed offers expire and pass the slot on", func(t *testing.T) {
+		w := send(http.MethodPost, "/reservations/"+itoa(*entry.ReservationID)+"/cancel", secondToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var offered models.WaitlistEntry
+		db.Where("player_id = ?", third.ID).First(&offered)
+		assert.Equal(t, models.WaitlistOffered, offered.Status)
+
+		expired, err := scheduling.ExpireOffers(db, 30*time.Minute, time.Now().Add(time.Hour))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, expired)
+
+		db.First(&offered, offered.ID)
+		assert.Equal(t, models.WaitlistExpired, offered.Status)
+
+		var reservation models.Reservation
+		db.First(&reservation, *offered.ReservationID)
+		assert.Equal(t, models.ReservationCancelled, reservation.Status)
+	})
+
+	t.Run("relay delivers pending events once", func(t *testing.T) {
+		var delivered []models.OutboxEvent
+		deliver := func(event models.OutboxEvent) ([]string, error) {
+			delivered = append(delivered, event)
+			return nil, nil
+		}
+
+		count, err := outbox.Relay(db, deliver, time.Now())
+		assert.NoError(t, err)
+		assert.Equal(t, len(delivered), count)
+		assert.NotZero(t, count)
+
+		count, err = outbox.Relay(db, deliver, time.Now())
+		assert.NoError(t, err)
+		assert.Zero(t, count)
+	})
+}
+
+func TestRecurringReservations(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupTestDB()
+	router := SetupTestRouter(db)
+	_, hostToken := IssueToken(db, "Host", models.RolePlayer)
+	_, otherToken := IssueToken(db, "Other", models.RolePlayer)
+
+	room := models.Room{Name: "League", Description: "Weekly games", Status: "available"}
+	other := models.Room{Name: "Annex", Description: "Spare room", Status: "available"}
+	db.Create(&room)
+	db.Create(&other)
+
+	send := func(method, path, auth string, payload interface{}) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(payload)
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", auth)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	start := time.Date(time.Now().Year()+1, time.January, 6, 20, 0, 0, 0, time.UTC)
+	blocker := start.AddDate(0, 0, 14)
+
+	w := send(http.MethodPost, "/reservations", otherToken, gin.H{"room_id": room.ID, "starts_at": blocker, "duration_minutes": 60})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var blocking struct {
+		Data struct {
+			ReservationID uint `json:"reservation_id"`
+		} `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &blocking)
+
+	weekly := gin.H{"room_id": room.ID, "starts_at": start, "duration_minutes": 60, "rrule": "FREQ=WEEKLY;COUNT=4"}
+
+	t.Run("invalid rules are rejected", func(t *testing.T) {
+		for _, rule := range []string{"FREQ=WEEKLY", "FREQ=MONTHLY;COUNT=2", "FREQ=DAILY;COUNT=500"} {
+			w := send(http.MethodPost, "/reservations", hostToken, gin.H{"room_id": room.ID, "starts_at": start, "duration_minutes": 60, "rrule": rule})
+			assert.Equal(t, http.StatusBadRequest, w.Code, rule)
+		}
+	})
+
+	t.Run("a conflicting occurrence books nothing", func(t *testing.T) {
+		w := send(http.MethodPost, "/reservations", hostToken, weekly)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		var body struct {
+			Data struct {
+				Conflicts []scheduling.SeriesConflict `json:"conflicts"`
+			} `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		assert.Len(t, body.Data.Conflicts, 1)
+		assert.Equal(t, blocking.Data.ReservationID, body.Data.Conflicts[0].ReservationID)
+
+		var count int64
+		db.Model(&models.ReservationSeries{}).Count(&count)
+		assert.Zero(t, count)
+		db.Model(&models.Reservation{}).Where("series_id IS NOT NULL").Count(&count)
+		assert.Zero(t, count)
+	})
+
+	send(http.MethodPost, "/reservations/"+itoa(blocking.Data.ReservationID)+"/cancel", otherToken, nil)
+
+	var created struct {
+		Data struct {
+			SeriesID       uint   `json:"series_id"`
+			ReservationIDs []uint `json:"reservation_ids"`
+		} `json:"data"`
+	}
+
+	t.Run("every occurrence is booked", func(t *testing.T) {
+		w := send(http.MethodPost, "/reservations", hostToken, weekly)
+		assert.Equal(t, http.StatusOK, w.Code)
+		json.Unmarshal(w.Body.Bytes(), &created)
+		assert.Len(t, created.Data.ReservationIDs, 4)
+
+		var last models.Reservation
+		db.First(&last, created.Data.ReservationIDs[3])
+		assert.True(t, last.StartsAt.Equal(start.AddDate(0, 0, 21)))
+		assert.Equal(t, created.Data.SeriesID, *last.SeriesID)
+
+		w = send(http.MethodGet, "/reservations/series/"+itoa(created.Data.SeriesID), otherToken, nil)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("this and following occurrences move to a new series", func(t *testing.T) {
+		w := send(http.MethodPatch, "/reservations/series/"+itoa(created.Data.SeriesID), hostToken, gin.H{
+			"start_time":          "18:00",
+			"room_id":             other.ID,
+			"from_reservation_id": created.Data.ReservationIDs[2],
+		})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var split struct {
+			Data models.ReservationSeries `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &split)
+		assert.NotEqual(t, created.Data.SeriesID, split.Data.ID)
+		assert.Equal(t, "FREQ=WEEKLY;COUNT=2", split.Data.RRule)
+		assert.Len(t, split.Data.Reservations, 2)
+
+		var moved models.Reservation
+		db.First(&moved, created.Data.ReservationIDs[2])
+		assert.Equal(t, other.ID, moved.RoomID)
+		assert.Equal(t, 18, moved.StartsAt.UTC().Hour())
+
+		var kept models.Reservation
+		db.First(&kept, created.Data.ReservationIDs[1])
+		assert.Equal(t, room.ID, kept.RoomID)
+		assert.Equal(t, created.Data.SeriesID, *kept.SeriesID)
+
+		var original models.ReservationSeries
+		db.First(&original, created.Data.SeriesID)
+		assert.Contains(t, original.RRule, "UNTIL=")
+	})
+
+	t.Run("a longer series lists the occurrences that no longer fit", func(t *testing.T) {
+		w := send(http.MethodPost, "/reservations", otherToken, gin.H{"room_id": room.ID, "starts_at": start.AddDate(0, 0, 7).Add(90 * time.Minute), "duration_minutes": 30})
+		assert.Equal(t, http.StatusOK, w.Code)
+		var late struct {
+			Data struct {
+				ReservationID uint `json:"reservation_id"`
+			} `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &late)
+
+		w = send(http.MethodPatch, "/reservations/series/"+itoa(created.Data.SeriesID), hostToken, gin.H{"duration_minutes": 120})
+		assert.Equal(t, http.StatusConflict, w.Code)
+		var body struct {
+			Data struct {
+				Conflicts []scheduling.SeriesConflict `json:"conflicts"`
+			} `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		if assert.Len(t, body.Data.Conflicts, 1) {
+			assert.Equal(t, late.Data.ReservationID, body.Data.Conflicts[0].ReservationID)
+		}
+
+		w = send(http.MethodPatch, "/reservations/series/"+itoa(created.Data.SeriesID), hostToken, gin.H{"duration_minutes": 90})
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("cancelling the series frees every slot", func(t *testing.T) {
+		w := send(http.MethodPost, "/reservations/series/"+itoa(created.Data.SeriesID)+"/cancel", hostToken, gin.H{"reason": "League ended"})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var body struct {
+			Data struct {
+				Cancelled []uint `json:"cancelled_reservation_ids"`
+			} `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		assert.Equal(t, created.Data.ReservationIDs[:2], body.Data.Cancelled)
+
+		w = send(http.MethodPost, "/reservations/series/"+itoa(created.Data.SeriesID)+"/cancel", hostToken, nil)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w = send(http.MethodPost, "/reservations", otherToken, gin.H{"room_id": room.ID, "starts_at": start, "duration_minutes": 60})
+		assert.Equal(t, http.StatusOK, w.Code)
 	})
 }
@@ -6,8 +6,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"oxo-game-api/internal/models"
+	"oxo-game-api/internal/scheduling"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -17,6 +19,7 @@ func TestCreateRoom(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	db := SetupTestDB()
 	router := SetupTestRouter(db)
+	_, token := IssueToken(db, "Token Owner", models.RoleOperator)
 
 	t.Run("successful room creation", func(t *testing.T) {
 		room := models.Room{Name: "Test Room", Description: "A room for testing"}
@@ -24,6 +27,7 @@ func TestCreateRoom(t *testing.T) {
 
 		req, _ := http.NewRequest(http.MethodPost, "/rooms", bytes.NewBuffer(body))
 		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", token)
 		w := httptest.NewRecorder()
 		router.ServeHTTP(w, req)
 
@@ -36,3 +40,189 @@ func TestCreateRoom(t *testing.T) {
 		assert.Equal(t, room.Description, createdRoom.Description)
 	})
 }
+
+func TestRoomStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupTestDB()
+	router := SetupTestRouter(db)
+	_, adminToken := IssueToken(db, "Admin", models.RoleAdmin)
+	player, playerToken := IssueToken(db, "Player", models.RolePlayer)
+
+	send := func(method, path, auth string, payload interface{}) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(payload)
+		req, _ := http.NewRequest(method, path, bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", auth)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	room := models.Room{Name: "Main Hall", Description: "Big room"}
+	spare := models.Room{Name: "Side Room", Description: "Small room"}
+	db.Create(&room)
+	db.Create(&spare)
+	assert.Equal(t, models.RoomAvailable, room.Status)
+
+	start := time.Now().Add(48 * time.Hour).Truncate(time.Hour)
+
+	t.Run("status changes follow the state machine", func(t *testing.T) {
+		w := send(http.MethodPatch, "/rooms/"+itoa(room.ID), adminToken, gin.H{"status": "Active"})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = send(http.MethodPatch, "/rooms/"+itoa(room.ID), adminToken, gin.H{"status": models.RoomMaintenance})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = send(http.MethodPost, "/reservations", playerToken, gin.H{"room_id": room.ID, "starts_at": start, "duration_minutes": 60})
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+		w = send(http.MethodPatch, "/rooms/"+itoa(room.ID), adminToken, gin.H{"status": models.RoomOccupied})
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w = send(http.MethodPatch, "/rooms/"+itoa(room.ID), adminToken, gin.H{"status": models.RoomAvailable})
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("deleting a room moves or cancels its reservations", func(t *testing.T) {
+		var ids []uint
+		for _, offset := range []time.Duration{0, 2 * time.Hour} {
+			w := send(http.MethodPost, "/reservations", playerToken, gin.H{"room_id": room.ID, "starts_at": start.Add(offset), "duration_minutes": 60})
+			assert.Equal(t, http.StatusOK, w.Code)
+			var created struct {
+				Data struct {
+					ReservationID uint `json:"reservation_id"`
+				} `json:"data"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &created)
+			ids = append(ids, created.Data.ReservationID)
+		}
+
+		w := send(http.MethodPost, "/reservations", adminToken, gin.H{"room_id": spare.ID, "player_id": player.ID, "starts_at": start.Add(2 * time.Hour), "duration_minutes": 60})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		w = send(http.MethodDelete, "/rooms/"+itoa(room.ID)+"?reassign_to="+itoa(room.ID), adminToken, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = send(http.MethodDelete, "/rooms/"+itoa(room.ID)+"?reassign_to="+itoa(spare.ID), adminToken, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var body struct {
+			Data struct {
+				Moved     []uint `json:"moved_reservation_ids"`
+				Cancelled []uint `json:"cancelled_reservation_ids"`
+			} `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		assert.Equal(t, []uint{ids[0]}, body.Data.Moved)
+		assert.Equal(t, []uint{ids[1]}, body.Data.Cancelled)
+
+		var moved, cancelled models.Reservation
+		db.First(&moved, ids[0])
+		db.First(&cancelled, ids[1])
+		assert.Equal(t, spare.ID, moved.RoomID)
+		assert.Equal(t, models.ReservationCancelled, cancelled.Status)
+
+		var events int64
+		db.Model(&models.OutboxEvent{}).Where("player_id = ? AND type IN ?", player.ID,
+			[]string{scheduling.EventReservationMoved, scheduling.EventReservationCancelled}).Count(&events)
+		assert.Equal(t, int64(2), events)
+
+		var deleted models.Room
+		db.Unscoped().First(&deleted, room.ID)
+		assert.Equal(t, models.RoomClosed, deleted.Status)
+	})
+}
+
+func TestSearchRooms(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := SetupTestDB()
+	router := SetupTestRouter(db)
+	player, token := IssueToken(db, "Searcher", models.RolePlayer)
+
+	get := func(query string) (*httptest.ResponseRecorder, []models.Room, int64) {
+		req, _ := http.NewRequest(http.MethodGet, "/rooms"+query, nil)
+		req.Header.Set("Authorization", token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var body struct {
+			Data struct {
+				Items []models.Room `json:"items"`
+				Total int64         `json:"total"`
+			} `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		return w, body.Data.Items, body.Data.Total
+	}
+	names := func(rooms []models.Room) []string {
+		result := make([]string, 0, len(rooms))
+		for _, room := range rooms {
+			result = append(result, room.Name)
+		}
+		return result
+	}
+
+	t.Run("no rooms is an empty list", func(t *testing.T) {
+		w, rooms, total := get("")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"items":[]`)
+		assert.Empty(t, rooms)
+		assert.Equal(t, int64(0), total)
+	})
+
+	for _, room := range []models.Room{
+		{Name: "Alpha Hall", Status: models.RoomAvailable, Capacity: 2, OpensAt: "00:00", ClosesAt: "24:00", Timezone: "UTC"},
+		{Name: "Beta Hall", Status: models.RoomAvailable, Capacity: 6, OpensAt: "00:00", ClosesAt: "24:00", Timezone: "UTC"},
+		{Name: "Gamma Den", Status: models.RoomMaintenance, Capacity: 8, OpensAt: "00:00", ClosesAt: "24:00", Timezone: "UTC"},
+		{Name: "Delta Den", Status: models.RoomAvailable, Capacity: 10, OpensAt: "09:00", ClosesAt: "10:00", Timezone: "UTC"},
+	} {
+		db.Create(&room)
+	}
+
+	t.Run("filters by status, capacity and name", func(t *testing.T) {
+		_, rooms, total := get("?status=available")
+		assert.Equal(t, int64(3), total)
+		assert.Equal(t, []string{"Alpha Hall", "Beta Hall", "Delta Den"}, names(rooms))
+
+		_, rooms, _ = get("?min_capacity=5&max_capacity=8")
+		assert.Equal(t, []string{"Beta Hall", "Gamma Den"}, names(rooms))
+
+		_, rooms, _ = get("?name_contains=hall")
+		assert.Equal(t, []string{"Alpha Hall", "Beta Hall"}, names(rooms))
+
+		_, rooms, _ = get("?name_prefix=de")
+		assert.Equal(t, []string{"Delta Den"}, names(rooms))
+
+		w, _, _ := get("?status=open")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("sorts and paginates", func(t *testing.T) {
+		_, rooms, total := get("?sort=-capacity&limit=2&offset=1")
+		assert.Equal(t, int64(4), total)
+		assert.Equal(t, []string{"Gamma Den", "Beta Hall"}, names(rooms))
+
+		w, _, _ := get("?sort=description")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("finds the rooms free for a slot", func(t *testing.T) {
+		var alpha models.Room
+		db.Where("name = ?", "Alpha Hall").First(&alpha)
+
+		tomorrow := time.Now().UTC().AddDate(0, 0, 1)
+		start := time.Date(tomorrow.Year(), tomorrow.Month(), tomorrow.Day(), 9, 0, 0, 0, time.UTC)
+		db.Create(&models.Reservation{RoomID: alpha.ID, PlayerID: player.ID, StartsAt: start.Add(30 * time.Minute), EndsAt: start.Add(90 * time.Minute), Status: models.ReservationBooked})
+
+		window := "?free_from=" + start.Format(time.RFC3339) + "&free_to=" + start.Add(time.Hour).Format(time.RFC3339)
+		_, rooms, total := get(window)
+		assert.Equal(t, int64(2), total)
+		assert.Equal(t, []string{"Beta Hall", "Delta Den"}, names(rooms))
+
+		window = "?free_from=" + start.Add(2*time.Hour).Format(time.RFC3339) + "&free_to=" + start.Add(3*time.Hour).Format(time.RFC3339)
+		_, rooms, _ = get(window + "&sort=-name")
+		assert.Equal(t, []string{"Beta Hall", "Alpha Hall"}, names(rooms))
+
+		w, _, _ := get("?free_from=" + start.Format(time.RFC3339))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
@@ -0,0 +1,47 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"oxo-game-api/pkg/utils/rrule"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRRuleExpansion(t *testing.T) {
+	monday := time.Date(2024, time.January, 1, 20, 0, 0, 0, time.UTC)
+
+	expand := func(value string) ([]time.Time, error) {
+		rule, err := rrule.Parse(value, time.UTC)
+		if !assert.NoError(t, err, value) {
+			return nil, err
+		}
+		return rule.All(monday, 52)
+	}
+
+	t.Run("daily rules step over days outside BYDAY", func(t *testing.T) {
+		starts, err := expand("FREQ=DAILY;INTERVAL=2;BYDAY=MO,WE;COUNT=3")
+		assert.NoError(t, err)
+		assert.Equal(t, []time.Time{monday, monday.AddDate(0, 0, 2), monday.AddDate(0, 0, 14)}, starts)
+	})
+
+	t.Run("a daily rule that never falls on BYDAY fails", func(t *testing.T) {
+		for _, value := range []string{
+			"FREQ=DAILY;INTERVAL=7;BYDAY=TU;COUNT=3",
+			"FREQ=DAILY;INTERVAL=14;BYDAY=WE,FR;UNTIL=20241231",
+		} {
+			done := make(chan error, 1)
+			go func() {
+				_, err := expand(value)
+				done <- err
+			}()
+			select {
+			case err := <-done:
+				assert.ErrorIs(t, err, rrule.ErrNever, value)
+			case <-time.After(time.Second):
+				t.Fatalf("%s does not terminate", value)
+			}
+		}
+	})
+}